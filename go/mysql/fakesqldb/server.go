@@ -33,6 +33,7 @@ import (
 
 	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/sync2"
 
 	"vitess.io/vitess/go/vt/dbconfigs"
 	querypb "vitess.io/vitess/go/vt/proto/query"
@@ -66,6 +67,11 @@ type DB struct {
 	// orderMatters is set when the query order matters.
 	orderMatters bool
 
+	// warningCount is the warning count reported for every query.
+	// It's not protected by mu because it's read while HandleQuery
+	// holds it. Use SetWarningCount() to change.
+	warningCount sync2.AtomicInt32
+
 	// Fields set at runtime.
 
 	// mu protects all the following fields.
@@ -326,7 +332,7 @@ func (db *DB) ComQuery(c *mysql.Conn, query string, callback func(*sqltypes.Resu
 
 // WarningCount is part of the mysql.Handler interface.
 func (db *DB) WarningCount(c *mysql.Conn) uint16 {
-	return 0
+	return uint16(db.warningCount.Get())
 }
 
 // HandleQuery is the default implementation of the QueryHandler interface
@@ -579,6 +585,11 @@ func (db *DB) SetConnDelay(d time.Duration) {
 	db.connDelay = d
 }
 
+// SetWarningCount sets the warning count reported for every query.
+func (db *DB) SetWarningCount(count uint16) {
+	db.warningCount.Set(int32(count))
+}
+
 // EnableShouldClose closes the connection when processing the next query.
 func (db *DB) EnableShouldClose() {
 	db.mu.Lock()
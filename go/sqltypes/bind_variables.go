@@ -303,9 +303,19 @@ func CopyBindVariables(bindVariables map[string]*querypb.BindVariable) map[strin
 // If asJson is true, then the resulting string is a valid JSON
 // representation, otherwise it is the golang printed map representation.
 func FormatBindVariables(bindVariables map[string]*querypb.BindVariable, full, asJSON bool) string {
+	return FormatBindVariablesWithMask(bindVariables, full, asJSON, nil)
+}
+
+// FormatBindVariablesWithMask is like FormatBindVariables, but the values
+// of the keys for which masked returns true are replaced with "***".
+// A nil masked doesn't mask any value.
+func FormatBindVariablesWithMask(bindVariables map[string]*querypb.BindVariable, full, asJSON bool, masked func(key string) bool) string {
 	var out map[string]*querypb.BindVariable
 	if full {
 		out = bindVariables
+		if masked != nil {
+			out = CopyBindVariables(bindVariables)
+		}
 	} else {
 		// NOTE(szopa): I am getting rid of potentially large bind
 		// variables.
@@ -320,6 +330,13 @@ func FormatBindVariables(bindVariables map[string]*querypb.BindVariable, full, a
 			}
 		}
 	}
+	if masked != nil {
+		for k := range out {
+			if masked(k) {
+				out[k] = StringBindVariable("***")
+			}
+		}
+	}
 
 	if asJSON {
 		var buf bytes.Buffer
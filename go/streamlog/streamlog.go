@@ -18,6 +18,7 @@ limitations under the License.
 package streamlog
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
@@ -38,8 +39,8 @@ var (
 	// RedactDebugUIQueries controls whether full queries and bind variables are suppressed from debug UIs.
 	RedactDebugUIQueries = flag.Bool("redact-debug-ui-queries", false, "redact full queries and bind variables from debug UI")
 
-	// QueryLogFormat controls the format of the query log (either text or json)
-	QueryLogFormat = flag.String("querylog-format", "text", "format for query logs (\"text\" or \"json\")")
+	// QueryLogFormat controls the format of the query log (text, json or json2)
+	QueryLogFormat = flag.String("querylog-format", "text", "format for query logs (\"text\" or \"json\", vttablet also supports \"json2\")")
 
 	// QueryLogFilterTag contains an optional string that must be present in the query for it to be logged
 	QueryLogFilterTag = flag.String("querylog-filter-tag", "", "string that must be present in the query for it to be logged")
@@ -61,6 +62,10 @@ const (
 
 	// QueryLogFormatJSON is the format specifier for json querylog output
 	QueryLogFormatJSON = "json"
+
+	// QueryLogFormatJSON2 is the format specifier for json querylog output
+	// with typed fields and durations in nanoseconds.
+	QueryLogFormatJSON2 = "json2"
 )
 
 // StreamLogger is a non-blocking broadcaster of messages.
@@ -161,6 +166,20 @@ func (logger *StreamLogger) ServeLogs(url string, logf LogFormatter) {
 // Returns the channel used for the subscription which can be used to close
 // it.
 func (logger *StreamLogger) LogToFile(path string, logf LogFormatter) (chan interface{}, error) {
+	return logger.LogToFileWithWrapper(path, logf, nil)
+}
+
+// WriterWrapper wraps the file a StreamLogger writes to, e.g. to compress
+// the output. Closing the returned writer must flush any buffered data, but
+// must not close the underlying file.
+type WriterWrapper func(io.Writer) io.WriteCloser
+
+// LogToFileWithWrapper is like LogToFile, but writes the records through
+// the writer returned by wrap, if not nil. Each record is formatted in full
+// before being written, so the wrapper only ever sees complete records.
+// The wrapper is closed and recreated when the file is reopened, and
+// closed when the returned channel is closed.
+func (logger *StreamLogger) LogToFileWithWrapper(path string, logf LogFormatter, wrap WriterWrapper) (chan interface{}, error) {
 	rotateChan := make(chan os.Signal, 1)
 	signal.Notify(rotateChan, syscall.SIGUSR2)
 
@@ -172,14 +191,40 @@ func (logger *StreamLogger) LogToFile(path string, logf LogFormatter) (chan inte
 		return nil, err
 	}
 
+	var w io.Writer
+	var wc io.WriteCloser
+	open := func() {
+		w = f
+		if wrap != nil {
+			wc = wrap(f)
+			w = wc
+		}
+	}
+	closeFile := func() {
+		if wc != nil {
+			wc.Close()
+		}
+		f.Close()
+	}
+	open()
+
 	go func() {
+		var buf bytes.Buffer
 		for {
 			select {
-			case record := <-logChan:
-				logf(f, formatParams, record)
+			case record, ok := <-logChan:
+				if !ok {
+					closeFile()
+					signal.Stop(rotateChan)
+					return
+				}
+				buf.Reset()
+				logf(&buf, formatParams, record)
+				w.Write(buf.Bytes())
 			case <-rotateChan:
-				f.Close()
+				closeFile()
 				f, _ = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+				open()
 			}
 		}
 	}()
@@ -18,6 +18,7 @@ package streamlog
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -255,3 +256,54 @@ func TestFile(t *testing.T) {
 		t.Errorf("streamlog file: want %q got %q", want, got)
 	}
 }
+
+func TestFileWithWrapper(t *testing.T) {
+	logger := New("logger", 10)
+
+	dir, err := ioutil.TempDir("", "streamlog_file")
+	if err != nil {
+		t.Fatalf("error getting tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	logPath := path.Join(dir, "test.log")
+	wrap := func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}
+	logChan, err := logger.LogToFileWithWrapper(logPath, testLogf, wrap)
+	if err != nil {
+		t.Fatalf("error enabling file logger: %v", err)
+	}
+
+	logger.Send(&logMessage{"test 1"})
+	time.Sleep(10 * time.Millisecond)
+
+	// Reopening the file closes the wrapper, and a new one is
+	// appended to the same file.
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	time.Sleep(10 * time.Millisecond)
+
+	logger.Send(&logMessage{"test 2"})
+	time.Sleep(10 * time.Millisecond)
+	logger.Unsubscribe(logChan)
+	close(logChan)
+	time.Sleep(10 * time.Millisecond)
+
+	want := "test 1\ntest 2\n"
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatalf("error opening log file: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("error reading gzip header: %v", err)
+	}
+	contents, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Errorf("error decompressing log file: %v", err)
+	}
+	if got := string(contents); want != got {
+		t.Errorf("streamlog file: want %q got %q", want, got)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package sync2
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -65,3 +66,26 @@ func TestSemaTryAcquire(t *testing.T) {
 		t.Errorf("TryAcquire: false, want true")
 	}
 }
+
+func TestSemaAcquireContext(t *testing.T) {
+	s := NewSemaphore(1, 0)
+	if !s.AcquireContext(context.Background()) {
+		t.Errorf("AcquireContext: false, want true")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if s.AcquireContext(ctx) {
+		t.Errorf("AcquireContext: true, want false")
+	}
+	s.Release()
+	if !s.AcquireContext(context.Background()) {
+		t.Errorf("AcquireContext: false, want true")
+	}
+	s.Release()
+
+	s = NewSemaphore(1, 5*time.Millisecond)
+	s.Acquire()
+	if s.AcquireContext(context.Background()) {
+		t.Errorf("AcquireContext: true, want false")
+	}
+}
@@ -52,6 +52,16 @@ func (dbc *DBConnection) ExecuteFetch(query string, maxrows int, wantfields bool
 	return mqr, nil
 }
 
+// ExecuteFetchWithWarningCount overwrites mysql.Conn.ExecuteFetchWithWarningCount.
+func (dbc *DBConnection) ExecuteFetchWithWarningCount(query string, maxrows int, wantfields bool) (*sqltypes.Result, uint16, error) {
+	mqr, warnings, err := dbc.Conn.ExecuteFetchWithWarningCount(query, maxrows, wantfields)
+	if err != nil {
+		dbc.handleError(err)
+		return nil, 0, err
+	}
+	return mqr, warnings, nil
+}
+
 // ExecuteStreamFetch overwrites mysql.Conn.ExecuteStreamFetch.
 func (dbc *DBConnection) ExecuteStreamFetch(query string, callback func(*sqltypes.Result) error, streamBufferSize int) error {
 
@@ -4240,6 +4240,217 @@ func (m *TransactionMetadata) GetParticipants() []*Target {
 	return nil
 }
 
+// QueryLogRecord is the structured form of a tabletserver query log entry.
+// Times are in nanoseconds since the epoch, and durations are in nanoseconds.
+type QueryLogRecord struct {
+	Method      string  `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	Target      *Target `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	PlanType    string  `protobuf:"bytes,3,opt,name=plan_type,json=planType,proto3" json:"plan_type,omitempty"`
+	OriginalSql string  `protobuf:"bytes,4,opt,name=original_sql,json=originalSql,proto3" json:"original_sql,omitempty"`
+	// bind_variables is not set if the query log is redacted.
+	BindVariables        map[string]*BindVariable `protobuf:"bytes,5,rep,name=bind_variables,json=bindVariables,proto3" json:"bind_variables,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	RewrittenSql         string                   `protobuf:"bytes,6,opt,name=rewritten_sql,json=rewrittenSql,proto3" json:"rewritten_sql,omitempty"`
+	NumberOfQueries      int64                    `protobuf:"varint,7,opt,name=number_of_queries,json=numberOfQueries,proto3" json:"number_of_queries,omitempty"`
+	StartTime            int64                    `protobuf:"varint,8,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime              int64                    `protobuf:"varint,9,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	TotalTime            int64                    `protobuf:"varint,10,opt,name=total_time,json=totalTime,proto3" json:"total_time,omitempty"`
+	MysqlTime            int64                    `protobuf:"varint,11,opt,name=mysql_time,json=mysqlTime,proto3" json:"mysql_time,omitempty"`
+	ConnWaitTime         int64                    `protobuf:"varint,12,opt,name=conn_wait_time,json=connWaitTime,proto3" json:"conn_wait_time,omitempty"`
+	ConsolidatorWaitTime int64                    `protobuf:"varint,13,opt,name=consolidator_wait_time,json=consolidatorWaitTime,proto3" json:"consolidator_wait_time,omitempty"`
+	// query_sources is a bitmask of the tabletserver query sources.
+	QuerySources         uint32   `protobuf:"varint,14,opt,name=query_sources,json=querySources,proto3" json:"query_sources,omitempty"`
+	RowsAffected         int64    `protobuf:"varint,15,opt,name=rows_affected,json=rowsAffected,proto3" json:"rows_affected,omitempty"`
+	RowsReturned         int64    `protobuf:"varint,16,opt,name=rows_returned,json=rowsReturned,proto3" json:"rows_returned,omitempty"`
+	ResponseSize         int64    `protobuf:"varint,17,opt,name=response_size,json=responseSize,proto3" json:"response_size,omitempty"`
+	TransactionId        int64    `protobuf:"varint,18,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	ReservedId           int64    `protobuf:"varint,19,opt,name=reserved_id,json=reservedId,proto3" json:"reserved_id,omitempty"`
+	Error                string   `protobuf:"bytes,20,opt,name=error,proto3" json:"error,omitempty"`
+	ImmediateCaller      string   `protobuf:"bytes,21,opt,name=immediate_caller,json=immediateCaller,proto3" json:"immediate_caller,omitempty"`
+	EffectiveCaller      string   `protobuf:"bytes,22,opt,name=effective_caller,json=effectiveCaller,proto3" json:"effective_caller,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *QueryLogRecord) Reset()         { *m = QueryLogRecord{} }
+func (m *QueryLogRecord) String() string { return proto.CompactTextString(m) }
+func (*QueryLogRecord) ProtoMessage()    {}
+func (*QueryLogRecord) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5c6ac9b241082464, []int{60}
+}
+
+func (m *QueryLogRecord) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_QueryLogRecord.Unmarshal(m, b)
+}
+func (m *QueryLogRecord) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_QueryLogRecord.Marshal(b, m, deterministic)
+}
+func (m *QueryLogRecord) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryLogRecord.Merge(m, src)
+}
+func (m *QueryLogRecord) XXX_Size() int {
+	return xxx_messageInfo_QueryLogRecord.Size(m)
+}
+func (m *QueryLogRecord) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryLogRecord.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryLogRecord proto.InternalMessageInfo
+
+func (m *QueryLogRecord) GetMethod() string {
+	if m != nil {
+		return m.Method
+	}
+	return ""
+}
+
+func (m *QueryLogRecord) GetTarget() *Target {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+func (m *QueryLogRecord) GetPlanType() string {
+	if m != nil {
+		return m.PlanType
+	}
+	return ""
+}
+
+func (m *QueryLogRecord) GetOriginalSql() string {
+	if m != nil {
+		return m.OriginalSql
+	}
+	return ""
+}
+
+func (m *QueryLogRecord) GetBindVariables() map[string]*BindVariable {
+	if m != nil {
+		return m.BindVariables
+	}
+	return nil
+}
+
+func (m *QueryLogRecord) GetRewrittenSql() string {
+	if m != nil {
+		return m.RewrittenSql
+	}
+	return ""
+}
+
+func (m *QueryLogRecord) GetNumberOfQueries() int64 {
+	if m != nil {
+		return m.NumberOfQueries
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetStartTime() int64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetEndTime() int64 {
+	if m != nil {
+		return m.EndTime
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetTotalTime() int64 {
+	if m != nil {
+		return m.TotalTime
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetMysqlTime() int64 {
+	if m != nil {
+		return m.MysqlTime
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetConnWaitTime() int64 {
+	if m != nil {
+		return m.ConnWaitTime
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetConsolidatorWaitTime() int64 {
+	if m != nil {
+		return m.ConsolidatorWaitTime
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetQuerySources() uint32 {
+	if m != nil {
+		return m.QuerySources
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetRowsAffected() int64 {
+	if m != nil {
+		return m.RowsAffected
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetRowsReturned() int64 {
+	if m != nil {
+		return m.RowsReturned
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetResponseSize() int64 {
+	if m != nil {
+		return m.ResponseSize
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetTransactionId() int64 {
+	if m != nil {
+		return m.TransactionId
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetReservedId() int64 {
+	if m != nil {
+		return m.ReservedId
+	}
+	return 0
+}
+
+func (m *QueryLogRecord) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *QueryLogRecord) GetImmediateCaller() string {
+	if m != nil {
+		return m.ImmediateCaller
+	}
+	return ""
+}
+
+func (m *QueryLogRecord) GetEffectiveCaller() string {
+	if m != nil {
+		return m.EffectiveCaller
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterEnum("query.MySqlFlag", MySqlFlag_name, MySqlFlag_value)
 	proto.RegisterEnum("query.Flag", Flag_name, Flag_value)
@@ -4311,207 +4522,229 @@ func init() {
 	proto.RegisterType((*AggregateStats)(nil), "query.AggregateStats")
 	proto.RegisterType((*StreamHealthResponse)(nil), "query.StreamHealthResponse")
 	proto.RegisterType((*TransactionMetadata)(nil), "query.TransactionMetadata")
+	proto.RegisterType((*QueryLogRecord)(nil), "query.QueryLogRecord")
+	proto.RegisterMapType((map[string]*BindVariable)(nil), "query.QueryLogRecord.BindVariablesEntry")
 }
 
 func init() { proto.RegisterFile("query.proto", fileDescriptor_5c6ac9b241082464) }
 
 var fileDescriptor_5c6ac9b241082464 = []byte{
-	// 3145 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xec, 0x5a, 0x4b, 0x70, 0x1b, 0x47,
-	0x7a, 0xd6, 0xe0, 0x45, 0xe0, 0x07, 0x01, 0x36, 0x9b, 0xa4, 0x04, 0x51, 0x7e, 0xd0, 0x63, 0xcb,
-	0x66, 0x98, 0x84, 0x92, 0x28, 0x59, 0x51, 0x6c, 0x27, 0xd1, 0x10, 0x1c, 0xca, 0x90, 0x80, 0x01,
-	0xd4, 0x18, 0x48, 0x96, 0x2a, 0x55, 0x53, 0x43, 0xa0, 0x05, 0x4e, 0x71, 0x80, 0x81, 0x66, 0x86,
-	0x94, 0x78, 0x53, 0xe2, 0x38, 0xce, 0x3b, 0xce, 0xd3, 0x71, 0x5c, 0x71, 0xa5, 0x2a, 0x87, 0x54,
-	0x2e, 0x39, 0xef, 0x79, 0x0f, 0x3e, 0xec, 0x61, 0xab, 0xf6, 0xb8, 0xbb, 0x87, 0xdd, 0x3d, 0x6c,
-	0xed, 0x9e, 0x5c, 0x5b, 0x7b, 0xd8, 0xc3, 0x1e, 0xb6, 0xb6, 0xfa, 0x31, 0x03, 0x80, 0x84, 0x25,
-	0x5a, 0x5e, 0xd7, 0x96, 0x64, 0xdd, 0xfa, 0x7f, 0xf4, 0xe3, 0xfb, 0xfa, 0x9f, 0xbf, 0x1f, 0xd3,
-	0x90, 0xbf, 0xbb, 0x4b, 0xfd, 0xfd, 0xd5, 0x81, 0xef, 0x85, 0x1e, 0x4e, 0x73, 0x61, 0xb1, 0x18,
-	0x7a, 0x03, 0xaf, 0x63, 0x87, 0xb6, 0x50, 0x2f, 0xe6, 0xf7, 0x42, 0x7f, 0xd0, 0x16, 0x82, 0xfa,
-	0x9e, 0x02, 0x19, 0xd3, 0xf6, 0xbb, 0x34, 0xc4, 0x8b, 0x90, 0xdd, 0xa1, 0xfb, 0xc1, 0xc0, 0x6e,
-	0xd3, 0x92, 0xb2, 0xa4, 0x2c, 0xe7, 0x48, 0x2c, 0xe3, 0x79, 0x48, 0x07, 0xdb, 0xb6, 0xdf, 0x29,
-	0x25, 0xb8, 0x41, 0x08, 0xf8, 0x75, 0xc8, 0x87, 0xf6, 0x96, 0x4b, 0x43, 0x2b, 0xdc, 0x1f, 0xd0,
-	0x52, 0x72, 0x49, 0x59, 0x2e, 0xae, 0xcd, 0xaf, 0xc6, 0xfd, 0x99, 0xdc, 0x68, 0xee, 0x0f, 0x28,
-	0x81, 0x30, 0x2e, 0x63, 0x0c, 0xa9, 0x36, 0x75, 0xdd, 0x52, 0x8a, 0xb7, 0xc5, 0xcb, 0xea, 0x06,
-	0x14, 0x6f, 0x98, 0x57, 0xec, 0x90, 0x96, 0x6d, 0xd7, 0xa5, 0x7e, 0x65, 0x83, 0x0d, 0x67, 0x37,
-	0xa0, 0x7e, 0xdf, 0xee, 0xc5, 0xc3, 0x89, 0x64, 0x7c, 0x1c, 0x32, 0x5d, 0xdf, 0xdb, 0x1d, 0x04,
-	0xa5, 0xc4, 0x52, 0x72, 0x39, 0x47, 0xa4, 0xa4, 0xfe, 0x29, 0x80, 0xbe, 0x47, 0xfb, 0xa1, 0xe9,
-	0xed, 0xd0, 0x3e, 0x7e, 0x0e, 0x72, 0xa1, 0xd3, 0xa3, 0x41, 0x68, 0xf7, 0x06, 0xbc, 0x89, 0x24,
-	0x19, 0x2a, 0x3e, 0x07, 0xd2, 0x22, 0x64, 0x07, 0x5e, 0xe0, 0x84, 0x8e, 0xd7, 0xe7, 0x78, 0x72,
-	0x24, 0x96, 0xd5, 0x3f, 0x86, 0xf4, 0x0d, 0xdb, 0xdd, 0xa5, 0xf8, 0x45, 0x48, 0x71, 0xc0, 0x0a,
-	0x07, 0x9c, 0x5f, 0x15, 0xa4, 0x73, 0x9c, 0xdc, 0xc0, 0xda, 0xde, 0x63, 0x9e, 0xbc, 0xed, 0x69,
-	0x22, 0x04, 0x75, 0x07, 0xa6, 0xd7, 0x9d, 0x7e, 0xe7, 0x86, 0xed, 0x3b, 0x8c, 0x8c, 0xc7, 0x6c,
-	0x06, 0xbf, 0x02, 0x19, 0x5e, 0x08, 0x4a, 0xc9, 0xa5, 0xe4, 0x72, 0x7e, 0x6d, 0x5a, 0x56, 0xe4,
-	0x63, 0x23, 0xd2, 0xa6, 0x7e, 0x53, 0x01, 0x58, 0xf7, 0x76, 0xfb, 0x9d, 0xeb, 0xcc, 0x88, 0x11,
-	0x24, 0x83, 0xbb, 0xae, 0x24, 0x92, 0x15, 0xf1, 0x35, 0x28, 0x6e, 0x39, 0xfd, 0x8e, 0xb5, 0x27,
-	0x87, 0x23, 0xb8, 0xcc, 0xaf, 0xbd, 0x22, 0x9b, 0x1b, 0x56, 0x5e, 0x1d, 0x1d, 0x75, 0xa0, 0xf7,
-	0x43, 0x7f, 0x9f, 0x14, 0xb6, 0x46, 0x75, 0x8b, 0x2d, 0xc0, 0x87, 0x9d, 0x58, 0xa7, 0x3b, 0x74,
-	0x3f, 0xea, 0x74, 0x87, 0xee, 0xe3, 0xdf, 0x19, 0x45, 0x94, 0x5f, 0x9b, 0x8b, 0xfa, 0x1a, 0xa9,
-	0x2b, 0x61, 0xbe, 0x91, 0xb8, 0xa4, 0xa8, 0xdf, 0x48, 0x43, 0x51, 0xbf, 0x4f, 0xdb, 0xbb, 0x21,
-	0xad, 0x0f, 0xd8, 0x1c, 0x04, 0xb8, 0x06, 0x33, 0x4e, 0xbf, 0xed, 0xee, 0x76, 0x68, 0xc7, 0xba,
-	0xe3, 0x50, 0xb7, 0x13, 0xf0, 0x38, 0x2a, 0xc6, 0xe3, 0x1e, 0xf7, 0x5f, 0xad, 0x48, 0xe7, 0x4d,
-	0xee, 0x4b, 0x8a, 0xce, 0x98, 0x8c, 0x57, 0x60, 0xb6, 0xed, 0x3a, 0xb4, 0x1f, 0x5a, 0x77, 0x18,
-	0x5e, 0xcb, 0xf7, 0xee, 0x05, 0xa5, 0xf4, 0x92, 0xb2, 0x9c, 0x25, 0x33, 0xc2, 0xb0, 0xc9, 0xf4,
-	0xc4, 0xbb, 0x17, 0xe0, 0x37, 0x20, 0x7b, 0xcf, 0xf3, 0x77, 0x5c, 0xcf, 0xee, 0x94, 0x32, 0xbc,
-	0xcf, 0x17, 0x26, 0xf7, 0x79, 0x53, 0x7a, 0x91, 0xd8, 0x1f, 0x2f, 0x03, 0x0a, 0xee, 0xba, 0x56,
-	0x40, 0x5d, 0xda, 0x0e, 0x2d, 0xd7, 0xe9, 0x39, 0x61, 0x29, 0xcb, 0x43, 0xb2, 0x18, 0xdc, 0x75,
-	0x9b, 0x5c, 0x5d, 0x65, 0x5a, 0x6c, 0xc1, 0x42, 0xe8, 0xdb, 0xfd, 0xc0, 0x6e, 0xb3, 0xc6, 0x2c,
-	0x27, 0xf0, 0x5c, 0x9b, 0x87, 0x63, 0x8e, 0x77, 0xb9, 0x32, 0xb9, 0x4b, 0x73, 0x58, 0xa5, 0x12,
-	0xd5, 0x20, 0xf3, 0xe1, 0x04, 0x2d, 0x3e, 0x07, 0x0b, 0xc1, 0x8e, 0x33, 0xb0, 0x78, 0x3b, 0xd6,
-	0xc0, 0xb5, 0xfb, 0x56, 0xdb, 0x6e, 0x6f, 0xd3, 0x12, 0x70, 0xd8, 0x98, 0x19, 0xf9, 0xbc, 0x37,
-	0x5c, 0xbb, 0x5f, 0x66, 0x16, 0xf5, 0x4d, 0x28, 0x8e, 0xf3, 0x88, 0x67, 0xa1, 0x60, 0xde, 0x6a,
-	0xe8, 0x96, 0x66, 0x6c, 0x58, 0x86, 0x56, 0xd3, 0xd1, 0x31, 0x5c, 0x80, 0x1c, 0x57, 0xd5, 0x8d,
-	0xea, 0x2d, 0xa4, 0xe0, 0x29, 0x48, 0x6a, 0xd5, 0x2a, 0x4a, 0xa8, 0x97, 0x20, 0x1b, 0x11, 0x82,
-	0x67, 0x20, 0xdf, 0x32, 0x9a, 0x0d, 0xbd, 0x5c, 0xd9, 0xac, 0xe8, 0x1b, 0xe8, 0x18, 0xce, 0x42,
-	0xaa, 0x5e, 0x35, 0x1b, 0x48, 0x11, 0x25, 0xad, 0x81, 0x12, 0xac, 0xe6, 0xc6, 0xba, 0x86, 0x92,
-	0xea, 0xff, 0x2a, 0x30, 0x3f, 0x09, 0x18, 0xce, 0xc3, 0xd4, 0x86, 0xbe, 0xa9, 0xb5, 0xaa, 0x26,
-	0x3a, 0x86, 0xe7, 0x60, 0x86, 0xe8, 0x0d, 0x5d, 0x33, 0xb5, 0xf5, 0xaa, 0x6e, 0x11, 0x5d, 0xdb,
-	0x40, 0x0a, 0xc6, 0x50, 0x64, 0x25, 0xab, 0x5c, 0xaf, 0xd5, 0x2a, 0xa6, 0xa9, 0x6f, 0xa0, 0x04,
-	0x9e, 0x07, 0xc4, 0x75, 0x2d, 0x63, 0xa8, 0x4d, 0x62, 0x04, 0xd3, 0x4d, 0x9d, 0x54, 0xb4, 0x6a,
-	0xe5, 0x36, 0x6b, 0x00, 0xa5, 0xf0, 0x4b, 0xf0, 0x7c, 0xb9, 0x6e, 0x34, 0x2b, 0x4d, 0x53, 0x37,
-	0x4c, 0xab, 0x69, 0x68, 0x8d, 0xe6, 0xdb, 0x75, 0x93, 0xb7, 0x2c, 0xc0, 0xa5, 0x71, 0x11, 0x40,
-	0x6b, 0x99, 0x75, 0xd1, 0x0e, 0xca, 0x5c, 0x4d, 0x65, 0x15, 0x94, 0xb8, 0x9a, 0xca, 0x26, 0x50,
-	0xf2, 0x6a, 0x2a, 0x9b, 0x44, 0x29, 0xf5, 0xc3, 0x04, 0xa4, 0x39, 0x57, 0x2c, 0xdd, 0x8d, 0x24,
-	0x31, 0x5e, 0x8e, 0x3f, 0xfd, 0xc4, 0x43, 0x3e, 0x7d, 0x9e, 0x31, 0x65, 0x12, 0x12, 0x02, 0x3e,
-	0x05, 0x39, 0xcf, 0xef, 0x5a, 0xc2, 0x22, 0xd2, 0x67, 0xd6, 0xf3, 0xbb, 0x3c, 0xcf, 0xb2, 0xd4,
-	0xc5, 0xb2, 0xee, 0x96, 0x1d, 0x50, 0x1e, 0xc1, 0x39, 0x12, 0xcb, 0xf8, 0x24, 0x30, 0x3f, 0x8b,
-	0x8f, 0x23, 0xc3, 0x6d, 0x53, 0x9e, 0xdf, 0x35, 0xd8, 0x50, 0x5e, 0x86, 0x42, 0xdb, 0x73, 0x77,
-	0x7b, 0x7d, 0xcb, 0xa5, 0xfd, 0x6e, 0xb8, 0x5d, 0x9a, 0x5a, 0x52, 0x96, 0x0b, 0x64, 0x5a, 0x28,
-	0xab, 0x5c, 0x87, 0x4b, 0x30, 0xd5, 0xde, 0xb6, 0xfd, 0x80, 0x8a, 0xa8, 0x2d, 0x90, 0x48, 0xe4,
-	0xbd, 0xd2, 0xb6, 0xd3, 0xb3, 0xdd, 0x80, 0x47, 0x68, 0x81, 0xc4, 0x32, 0x03, 0x71, 0xc7, 0xb5,
-	0xbb, 0x01, 0x8f, 0xac, 0x02, 0x11, 0x82, 0xfa, 0x07, 0x90, 0x24, 0xde, 0x3d, 0xd6, 0xa4, 0xe8,
-	0x30, 0x28, 0x29, 0x4b, 0xc9, 0x65, 0x4c, 0x22, 0x91, 0x65, 0x77, 0x99, 0xe0, 0x44, 0xde, 0x8b,
-	0x52, 0xda, 0xc7, 0x0a, 0xe4, 0x79, 0x60, 0x12, 0x1a, 0xec, 0xba, 0x21, 0x4b, 0x84, 0x32, 0x03,
-	0x28, 0x63, 0x89, 0x90, 0xd3, 0x4e, 0xa4, 0x8d, 0xe1, 0x63, 0x1f, 0xb5, 0x65, 0xdf, 0xb9, 0x43,
-	0xdb, 0x21, 0x15, 0xf9, 0x3e, 0x45, 0xa6, 0x99, 0x52, 0x93, 0x3a, 0x46, 0xac, 0xd3, 0x0f, 0xa8,
-	0x1f, 0x5a, 0x4e, 0x87, 0x53, 0x9e, 0x22, 0x59, 0xa1, 0xa8, 0x74, 0xf0, 0x0b, 0x90, 0xe2, 0x69,
-	0x21, 0xc5, 0x7b, 0x01, 0xd9, 0x0b, 0xf1, 0xee, 0x11, 0xae, 0xbf, 0x9a, 0xca, 0xa6, 0x51, 0x46,
-	0x7d, 0x0b, 0xa6, 0xf9, 0xe0, 0x6e, 0xda, 0x7e, 0xdf, 0xe9, 0x77, 0xf9, 0x2a, 0xe7, 0x75, 0xc4,
-	0xb4, 0x17, 0x08, 0x2f, 0x33, 0xcc, 0x3d, 0x1a, 0x04, 0x76, 0x97, 0xca, 0x55, 0x27, 0x12, 0xd5,
-	0xff, 0x4e, 0x42, 0xbe, 0x19, 0xfa, 0xd4, 0xee, 0xf1, 0x05, 0x0c, 0xbf, 0x05, 0x10, 0x84, 0x76,
-	0x48, 0x7b, 0xb4, 0x1f, 0x46, 0xf8, 0x9e, 0x93, 0x3d, 0x8f, 0xf8, 0xad, 0x36, 0x23, 0x27, 0x32,
-	0xe2, 0x8f, 0xd7, 0x20, 0x4f, 0x99, 0xd9, 0x0a, 0xd9, 0x42, 0x28, 0x93, 0xed, 0x6c, 0x94, 0x39,
-	0xe2, 0x15, 0x92, 0x00, 0x8d, 0xcb, 0x8b, 0x9f, 0x24, 0x20, 0x17, 0xb7, 0x86, 0x35, 0xc8, 0xb6,
-	0xed, 0x90, 0x76, 0x3d, 0x7f, 0x5f, 0xae, 0x4f, 0xa7, 0x1f, 0xd6, 0xfb, 0x6a, 0x59, 0x3a, 0x93,
-	0xb8, 0x1a, 0x7e, 0x1e, 0xc4, 0xa2, 0x2f, 0xa2, 0x4e, 0xe0, 0xcd, 0x71, 0x0d, 0x8f, 0xbb, 0x37,
-	0x00, 0x0f, 0x7c, 0xa7, 0x67, 0xfb, 0xfb, 0xd6, 0x0e, 0xdd, 0x8f, 0x72, 0x79, 0x72, 0xc2, 0x4c,
-	0x22, 0xe9, 0x77, 0x8d, 0xee, 0xcb, 0xec, 0x73, 0x69, 0xbc, 0xae, 0x8c, 0x96, 0xc3, 0xf3, 0x33,
-	0x52, 0x93, 0xaf, 0x8e, 0x41, 0xb4, 0x0e, 0xa6, 0x79, 0x60, 0xb1, 0xa2, 0xfa, 0x1a, 0x64, 0xa3,
-	0xc1, 0xe3, 0x1c, 0xa4, 0x75, 0xdf, 0xf7, 0x7c, 0x74, 0x8c, 0x27, 0xa1, 0x5a, 0x55, 0xe4, 0xb1,
-	0x8d, 0x0d, 0x96, 0xc7, 0x7e, 0x94, 0x88, 0x17, 0x23, 0x42, 0xef, 0xee, 0xd2, 0x20, 0xc4, 0x7f,
-	0x02, 0x73, 0x94, 0x87, 0x90, 0xb3, 0x47, 0xad, 0x36, 0xdf, 0xb9, 0xb0, 0x00, 0x52, 0x38, 0xdf,
-	0x33, 0xab, 0x62, 0xa3, 0x15, 0xed, 0x68, 0xc8, 0x6c, 0xec, 0x2b, 0x55, 0x1d, 0xac, 0xc3, 0x9c,
-	0xd3, 0xeb, 0xd1, 0x8e, 0x63, 0x87, 0xa3, 0x0d, 0x88, 0x09, 0x5b, 0x88, 0x16, 0xf6, 0xb1, 0x8d,
-	0x11, 0x99, 0x8d, 0x6b, 0xc4, 0xcd, 0x9c, 0x86, 0x4c, 0xc8, 0x37, 0x71, 0x3c, 0x76, 0xf3, 0x6b,
-	0x85, 0x28, 0xa1, 0x70, 0x25, 0x91, 0x46, 0xfc, 0x1a, 0x88, 0x2d, 0x21, 0x4f, 0x1d, 0xc3, 0x80,
-	0x18, 0xae, 0xf4, 0x44, 0xd8, 0xf1, 0x69, 0x28, 0x8e, 0xad, 0x41, 0x1d, 0x4e, 0x58, 0x92, 0x14,
-	0x46, 0x17, 0x94, 0x0e, 0x3e, 0x03, 0x53, 0x9e, 0x58, 0x7f, 0x78, 0x52, 0x19, 0x8e, 0x78, 0x7c,
-	0x71, 0x22, 0x91, 0x17, 0x7e, 0x11, 0xf2, 0x3e, 0x0d, 0xa8, 0xbf, 0x47, 0x3b, 0xac, 0xd1, 0x29,
-	0xde, 0x28, 0x44, 0xaa, 0x4a, 0x47, 0xfd, 0x23, 0x98, 0x89, 0x29, 0x0e, 0x06, 0x5e, 0x3f, 0xa0,
-	0x78, 0x05, 0x32, 0x3e, 0xff, 0xde, 0x25, 0xad, 0x58, 0xf6, 0x31, 0x92, 0x09, 0x88, 0xf4, 0x50,
-	0x3b, 0x30, 0x23, 0x34, 0x37, 0x9d, 0x70, 0x9b, 0xcf, 0x24, 0x3e, 0x0d, 0x69, 0xca, 0x0a, 0x07,
-	0x26, 0x85, 0x34, 0xca, 0xdc, 0x4e, 0x84, 0x75, 0xa4, 0x97, 0xc4, 0x23, 0x7b, 0xf9, 0x59, 0x02,
-	0xe6, 0xe4, 0x28, 0xd7, 0xed, 0xb0, 0xbd, 0xfd, 0x84, 0x46, 0xc3, 0xef, 0xc2, 0x14, 0xd3, 0x3b,
-	0xf1, 0x97, 0x33, 0x21, 0x1e, 0x22, 0x0f, 0x16, 0x11, 0x76, 0x60, 0x8d, 0x4c, 0xbf, 0xdc, 0x24,
-	0x15, 0xec, 0x60, 0x64, 0x85, 0x9e, 0x10, 0x38, 0x99, 0x47, 0x04, 0xce, 0xd4, 0x51, 0x02, 0x47,
-	0xdd, 0x80, 0xf9, 0x71, 0xc6, 0x65, 0x70, 0xfc, 0x1e, 0x4c, 0x89, 0x49, 0x89, 0x72, 0xe4, 0xa4,
-	0x79, 0x8b, 0x5c, 0xd4, 0x4f, 0x13, 0x30, 0x2f, 0xd3, 0xd7, 0xd7, 0xe3, 0x3b, 0x1e, 0xe1, 0x39,
-	0x7d, 0xa4, 0x0f, 0xf4, 0x68, 0xf3, 0xa7, 0x96, 0x61, 0xe1, 0x00, 0x8f, 0x8f, 0xf1, 0xb1, 0x7e,
-	0xa6, 0xc0, 0xf4, 0x3a, 0xed, 0x3a, 0xfd, 0x27, 0x74, 0x16, 0x46, 0xc8, 0x4d, 0x1d, 0x29, 0x88,
-	0x07, 0x50, 0x90, 0x78, 0x25, 0x5b, 0x87, 0xd9, 0x56, 0x26, 0x7d, 0x2d, 0x97, 0x60, 0x5a, 0x1e,
-	0xb3, 0x6d, 0xd7, 0xb1, 0x83, 0x18, 0xcf, 0x81, 0x73, 0xb6, 0xc6, 0x8c, 0x44, 0x9e, 0xc8, 0xb9,
-	0xa0, 0xfe, 0x58, 0x81, 0x42, 0xd9, 0xeb, 0xf5, 0x9c, 0xf0, 0x09, 0xe5, 0xf8, 0x30, 0x43, 0xa9,
-	0x49, 0xf1, 0x78, 0x0e, 0x8a, 0x11, 0x4c, 0x49, 0xed, 0x81, 0x95, 0x46, 0x39, 0xb4, 0xd2, 0xfc,
-	0x44, 0x81, 0x19, 0xe2, 0xb9, 0xee, 0x96, 0xdd, 0xde, 0x79, 0xba, 0xc9, 0x39, 0x0f, 0x68, 0x08,
-	0xf4, 0xa8, 0xf4, 0xfc, 0x52, 0x81, 0x62, 0xc3, 0xa7, 0x03, 0xdb, 0xa7, 0x4f, 0x35, 0x3b, 0x6c,
-	0x9b, 0xde, 0x09, 0xe5, 0x06, 0x27, 0x47, 0x78, 0x59, 0x9d, 0x85, 0x99, 0x18, 0xbb, 0x20, 0x4c,
-	0xfd, 0x9e, 0x02, 0x0b, 0x22, 0xc4, 0xa4, 0xa5, 0xf3, 0x84, 0xd2, 0x12, 0xe1, 0x4d, 0x8d, 0xe0,
-	0x2d, 0xc1, 0xf1, 0x83, 0xd8, 0x24, 0xec, 0x77, 0x13, 0x70, 0x22, 0x0a, 0x9e, 0x27, 0x1c, 0xf8,
-	0x97, 0x88, 0x87, 0x45, 0x28, 0x1d, 0x26, 0x41, 0x32, 0xf4, 0x41, 0x02, 0x4a, 0x65, 0x9f, 0xda,
-	0x21, 0x1d, 0xd9, 0x07, 0x3d, 0x3d, 0xb1, 0x81, 0xcf, 0xc1, 0xf4, 0xc0, 0xf6, 0x43, 0xa7, 0xed,
-	0x0c, 0x6c, 0x76, 0x14, 0x4d, 0xf3, 0x6d, 0xd6, 0x81, 0x06, 0xc6, 0x5c, 0xd4, 0x53, 0x70, 0x72,
-	0x02, 0x23, 0x92, 0xaf, 0x5f, 0x29, 0x80, 0x9b, 0xa1, 0xed, 0x87, 0x5f, 0x83, 0x75, 0x69, 0x62,
-	0x30, 0x2d, 0xc0, 0xdc, 0x18, 0xfe, 0x51, 0x5e, 0x68, 0xf8, 0xb5, 0x58, 0x92, 0x3e, 0x97, 0x97,
-	0x51, 0xfc, 0x92, 0x97, 0x1f, 0x28, 0xb0, 0x58, 0xf6, 0xc4, 0xe5, 0xe3, 0x53, 0xf9, 0x85, 0xa9,
-	0xcf, 0xc3, 0xa9, 0x89, 0x00, 0x25, 0x01, 0xdf, 0x57, 0xe0, 0x38, 0xa1, 0x76, 0xe7, 0xe9, 0x04,
-	0x7f, 0x1d, 0x4e, 0x1c, 0x02, 0x27, 0xf7, 0x28, 0x17, 0x21, 0xdb, 0xa3, 0xa1, 0xcd, 0x76, 0xb8,
-	0x12, 0xd2, 0x62, 0xd4, 0xee, 0xd0, 0xbb, 0x26, 0x3d, 0x48, 0xec, 0xab, 0xfe, 0x30, 0x01, 0x73,
-	0x7c, 0x9f, 0xfd, 0xec, 0x90, 0x77, 0xa4, 0x5b, 0x98, 0xcc, 0xc1, 0xcd, 0x1f, 0x73, 0x18, 0xf8,
-	0xd4, 0x8a, 0x6e, 0x07, 0xa6, 0xf8, 0x3f, 0x36, 0x18, 0xf8, 0xf4, 0xba, 0xd0, 0xa8, 0xdf, 0x52,
-	0x60, 0x7e, 0x9c, 0xe2, 0xf8, 0x44, 0xf3, 0x9b, 0xbe, 0x6d, 0x99, 0x90, 0x52, 0x92, 0x47, 0x39,
-	0x24, 0xa5, 0x8e, 0x7c, 0x48, 0xfa, 0x76, 0x02, 0x4a, 0xa3, 0x60, 0x9e, 0xdd, 0xe9, 0x8c, 0xdf,
-	0xe9, 0x7c, 0xd1, 0x5b, 0x3e, 0xf5, 0x3b, 0x0a, 0x9c, 0x9c, 0x40, 0xe8, 0x17, 0x0b, 0x91, 0x91,
-	0x9b, 0x9d, 0xc4, 0x23, 0x6f, 0x76, 0xbe, 0xfa, 0x20, 0xf9, 0xae, 0x02, 0xf3, 0x35, 0x71, 0x57,
-	0x2f, 0x6e, 0x3e, 0x9e, 0xdc, 0x1c, 0xcc, 0xaf, 0xe3, 0x53, 0xc3, 0x9f, 0x51, 0x6a, 0x19, 0x16,
-	0x0e, 0x40, 0x7b, 0x8c, 0xdb, 0x9c, 0x5f, 0x28, 0x30, 0x2b, 0x5b, 0xd1, 0x9e, 0xd8, 0xed, 0xcb,
-	0x04, 0x76, 0xf0, 0x0b, 0x90, 0x74, 0x3a, 0xd1, 0xbe, 0x77, 0xfc, 0x5f, 0x3b, 0x33, 0xa8, 0x97,
-	0x01, 0x8f, 0xe2, 0x7e, 0x0c, 0xea, 0x7e, 0x9a, 0x80, 0x05, 0x22, 0xb2, 0xef, 0xb3, 0xff, 0x0b,
-	0x5f, 0xf6, 0xff, 0xc2, 0xc3, 0x17, 0xae, 0x4f, 0xf9, 0x66, 0x6a, 0x9c, 0xea, 0xaf, 0x6e, 0xe9,
-	0x3a, 0xb0, 0xd0, 0x26, 0x0f, 0x2d, 0xb4, 0x8f, 0x9f, 0x8f, 0x3e, 0x4d, 0xc0, 0xa2, 0x04, 0xf2,
-	0x6c, 0xaf, 0x73, 0xf4, 0x88, 0xc8, 0x1c, 0x8a, 0x88, 0x9f, 0x2b, 0x70, 0x6a, 0x22, 0x91, 0xbf,
-	0xf5, 0x1d, 0xcd, 0x81, 0xe8, 0x49, 0x3d, 0x32, 0x7a, 0xd2, 0x47, 0x8e, 0x9e, 0xf7, 0x13, 0x50,
-	0x24, 0xd4, 0xa5, 0x76, 0xf0, 0x94, 0xdf, 0xee, 0x1d, 0xe0, 0x30, 0x7d, 0xe8, 0x9e, 0x73, 0x16,
-	0x66, 0x62, 0x22, 0xe4, 0x81, 0x8b, 0x1f, 0xd0, 0xd9, 0x3a, 0xf8, 0x36, 0xb5, 0xdd, 0x30, 0xda,
-	0x09, 0xaa, 0xff, 0x93, 0x80, 0x02, 0x61, 0x1a, 0xa7, 0x47, 0x9b, 0xa1, 0x1d, 0x06, 0xf8, 0x25,
-	0x98, 0xde, 0xe6, 0x2e, 0xd6, 0x30, 0x42, 0x72, 0x24, 0x2f, 0x74, 0xe2, 0xef, 0xe3, 0x1a, 0x2c,
-	0x04, 0xb4, 0xed, 0xf5, 0x3b, 0x81, 0xb5, 0x45, 0xb7, 0x9d, 0x7e, 0xc7, 0xea, 0xd9, 0x41, 0x48,
-	0x7d, 0x4e, 0x4b, 0x81, 0xcc, 0x49, 0xe3, 0x3a, 0xb7, 0xd5, 0xb8, 0x09, 0x9f, 0x85, 0xf9, 0x2d,
-	0xa7, 0xef, 0x7a, 0x5d, 0x6b, 0xe0, 0xda, 0xfb, 0xd4, 0x0f, 0xac, 0xb6, 0xb7, 0xdb, 0x17, 0x7c,
-	0xa4, 0x09, 0x16, 0xb6, 0x86, 0x30, 0x95, 0x99, 0x05, 0xdf, 0x86, 0x95, 0x89, 0xbd, 0x58, 0x77,
-	0x1c, 0x37, 0xa4, 0x3e, 0xed, 0x58, 0x3e, 0x1d, 0xb8, 0x4e, 0x5b, 0xbc, 0x23, 0x12, 0x44, 0xbd,
-	0x3a, 0xa1, 0xeb, 0x4d, 0xe9, 0x4e, 0x86, 0xde, 0xf8, 0x14, 0xe4, 0xda, 0x83, 0x5d, 0x6b, 0x97,
-	0x3f, 0x5a, 0x60, 0xfc, 0x29, 0x24, 0xdb, 0x1e, 0xec, 0xb6, 0x98, 0x8c, 0x11, 0x24, 0xef, 0x0e,
-	0x44, 0x72, 0x56, 0x08, 0x2b, 0xaa, 0x9f, 0x29, 0x50, 0xd4, 0xba, 0x5d, 0x9f, 0x76, 0xed, 0x50,
-	0xd2, 0x74, 0x16, 0xe6, 0x05, 0x25, 0xfb, 0x96, 0x0c, 0x57, 0x81, 0x47, 0x11, 0x78, 0xa4, 0x4d,
-	0xc4, 0xaa, 0xc0, 0x73, 0x01, 0x8e, 0xef, 0xf6, 0x27, 0xd6, 0x49, 0xf0, 0x3a, 0xf3, 0xb1, 0x75,
-	0xb4, 0xd6, 0x1f, 0xc2, 0xc9, 0xc9, 0x2c, 0xf4, 0x1c, 0xf1, 0x96, 0xaf, 0x40, 0x8e, 0x4f, 0x00,
-	0x5d, 0x73, 0xfa, 0x0f, 0xa9, 0x6a, 0xdf, 0xe7, 0x7c, 0x7d, 0x4e, 0x55, 0xfb, 0xbe, 0xfa, 0x7f,
-	0xf1, 0x3f, 0xc5, 0x28, 0x5c, 0xe2, 0xc4, 0x11, 0x05, 0xb2, 0xf2, 0xb0, 0x40, 0x2e, 0xc1, 0x14,
-	0x0b, 0x46, 0xa7, 0xdf, 0xe5, 0xe0, 0xb2, 0x24, 0x12, 0x71, 0x13, 0x5e, 0x95, 0xd8, 0xe9, 0xfd,
-	0x90, 0xfa, 0x7d, 0xdb, 0x75, 0xf7, 0x2d, 0x71, 0xfd, 0xd8, 0x0f, 0x69, 0xc7, 0x1a, 0xbe, 0x6d,
-	0x14, 0xe9, 0xe3, 0x65, 0xe1, 0xad, 0xc7, 0xce, 0x24, 0xf6, 0x35, 0xe3, 0x57, 0x8f, 0x6f, 0x42,
-	0xd1, 0x97, 0x41, 0x6c, 0x05, 0x6c, 0x7a, 0x64, 0xca, 0x9d, 0x8f, 0x5e, 0x4d, 0x8c, 0x46, 0x38,
-	0x29, 0xf8, 0x63, 0x01, 0xff, 0xd8, 0x09, 0xe7, 0x6a, 0x2a, 0x9b, 0x41, 0x53, 0xea, 0xff, 0x2b,
-	0x30, 0x37, 0xe1, 0xec, 0x1e, 0x5f, 0x0c, 0x28, 0x23, 0xf7, 0x8e, 0xbf, 0x0f, 0x69, 0xfe, 0xa0,
-	0x45, 0x3e, 0x91, 0x3a, 0x71, 0xf8, 0xe8, 0xcf, 0x1f, 0x9f, 0x10, 0xe1, 0xc5, 0xbe, 0x45, 0x8e,
-	0xa9, 0xcd, 0x2f, 0x1e, 0xa3, 0x8c, 0x9a, 0x67, 0x3a, 0x71, 0x17, 0x79, 0xf8, 0x26, 0x33, 0xf5,
-	0xc8, 0x9b, 0xcc, 0x95, 0x7f, 0x4e, 0x42, 0xae, 0xb6, 0xdf, 0xbc, 0xeb, 0x6e, 0xba, 0x76, 0x97,
-	0xbf, 0x0e, 0xa9, 0x35, 0xcc, 0x5b, 0xe8, 0x18, 0x9e, 0x85, 0x82, 0x51, 0x37, 0x2d, 0xa3, 0x55,
-	0xad, 0x5a, 0x9b, 0x55, 0xed, 0x0a, 0x52, 0x30, 0x82, 0xe9, 0x06, 0xa9, 0x58, 0xd7, 0xf4, 0x5b,
-	0x42, 0x93, 0xc0, 0x73, 0x30, 0xd3, 0x32, 0x2a, 0xd7, 0x5b, 0xfa, 0x50, 0x99, 0xc2, 0x0b, 0x30,
-	0x5b, 0x6b, 0x55, 0xcd, 0x4a, 0xa3, 0x3a, 0xa2, 0xce, 0xe2, 0x02, 0xe4, 0xd6, 0xab, 0xf5, 0x75,
-	0x21, 0x22, 0xd6, 0x7e, 0xcb, 0x68, 0x56, 0xae, 0x18, 0xfa, 0x86, 0x50, 0x2d, 0x31, 0xd5, 0x6d,
-	0x9d, 0xd4, 0x37, 0x2b, 0x51, 0x97, 0x97, 0x31, 0x82, 0xfc, 0x7a, 0xc5, 0xd0, 0x88, 0x6c, 0xe5,
-	0x81, 0x82, 0x8b, 0x90, 0xd3, 0x8d, 0x56, 0x4d, 0xca, 0x09, 0x5c, 0x82, 0x39, 0xad, 0x65, 0xd6,
-	0xad, 0x8a, 0x51, 0x26, 0x7a, 0x4d, 0x37, 0x4c, 0x69, 0x49, 0xe1, 0x39, 0x28, 0x9a, 0x95, 0x9a,
-	0xde, 0x34, 0xb5, 0x5a, 0x43, 0x2a, 0xd9, 0x28, 0xb2, 0x4d, 0x3d, 0xf2, 0x41, 0x78, 0x11, 0x16,
-	0x8c, 0xba, 0x25, 0x5f, 0xda, 0x59, 0x37, 0xb4, 0x6a, 0x4b, 0x97, 0xb6, 0x25, 0x7c, 0x02, 0x70,
-	0xdd, 0xb0, 0x5a, 0x8d, 0x0d, 0xcd, 0xd4, 0x2d, 0xa3, 0x7e, 0x53, 0x1a, 0x2e, 0xe3, 0x22, 0x64,
-	0x87, 0x23, 0x78, 0xc0, 0x58, 0x28, 0x34, 0x34, 0x62, 0x0e, 0xc1, 0x3e, 0x78, 0xc0, 0xc8, 0x82,
-	0x2b, 0xa4, 0xde, 0x6a, 0x0c, 0xdd, 0x66, 0x21, 0x2f, 0xc9, 0x92, 0xaa, 0x14, 0x53, 0xad, 0x57,
-	0x8c, 0x72, 0x3c, 0xbe, 0x07, 0xd9, 0xc5, 0x04, 0x52, 0x56, 0x76, 0x20, 0xc5, 0xa7, 0x23, 0x0b,
-	0x29, 0xa3, 0x6e, 0xe8, 0xe8, 0x18, 0x9e, 0x01, 0xa8, 0x34, 0x2b, 0x86, 0xa9, 0x5f, 0x21, 0x5a,
-	0x95, 0xc1, 0xe6, 0x8a, 0x88, 0x40, 0x86, 0x76, 0x1a, 0xa6, 0x2a, 0xcd, 0xcd, 0x6a, 0x5d, 0x33,
-	0x25, 0xcc, 0x4a, 0xf3, 0x7a, 0xab, 0x6e, 0x32, 0x23, 0xc2, 0x79, 0xc8, 0x54, 0x9a, 0xa6, 0xfe,
-	0x8e, 0xc9, 0x70, 0x71, 0x9b, 0x60, 0x15, 0x3d, 0xb8, 0xbc, 0xf2, 0x51, 0x12, 0x52, 0xfc, 0xd1,
-	0x72, 0x01, 0x72, 0x7c, 0xb6, 0xcd, 0x5b, 0x0d, 0xd6, 0x65, 0x0e, 0x52, 0x15, 0xc3, 0xbc, 0x84,
-	0xfe, 0x2c, 0x81, 0x01, 0xd2, 0x2d, 0x5e, 0xfe, 0xf3, 0x0c, 0x2b, 0x57, 0x0c, 0xf3, 0xdc, 0x45,
-	0xf4, 0x6e, 0x82, 0x35, 0xdb, 0x12, 0xc2, 0x5f, 0x44, 0x86, 0xb5, 0x0b, 0xe8, 0xbd, 0xd8, 0xb0,
-	0x76, 0x01, 0xfd, 0x65, 0x64, 0x38, 0xbf, 0x86, 0xde, 0x8f, 0x0d, 0xe7, 0xd7, 0xd0, 0x5f, 0x45,
-	0x86, 0x8b, 0x17, 0xd0, 0x5f, 0xc7, 0x86, 0x8b, 0x17, 0xd0, 0xdf, 0x64, 0x18, 0x16, 0x8e, 0xe4,
-	0xfc, 0x1a, 0xfa, 0xdb, 0x6c, 0x2c, 0x5d, 0xbc, 0x80, 0xfe, 0x2e, 0xcb, 0xe6, 0x3f, 0x9e, 0x55,
-	0xf4, 0xf7, 0x88, 0x0d, 0x93, 0x4d, 0x10, 0xfa, 0x07, 0x5e, 0x64, 0x26, 0xf4, 0x8f, 0x88, 0x61,
-	0x64, 0x5a, 0x2e, 0x7e, 0xc0, 0x2d, 0xb7, 0x74, 0x8d, 0xa0, 0x7f, 0xca, 0x88, 0x87, 0x95, 0xe5,
-	0x4a, 0x4d, 0xab, 0x22, 0xcc, 0x6b, 0x30, 0x56, 0xfe, 0xe5, 0x2c, 0x2b, 0xb2, 0xf0, 0x44, 0xff,
-	0xda, 0x60, 0x1d, 0xde, 0xd0, 0x48, 0xf9, 0x6d, 0x8d, 0xa0, 0x7f, 0x3b, 0xcb, 0x3a, 0xbc, 0xa1,
-	0x11, 0xc9, 0xd7, 0xbf, 0x37, 0x98, 0x23, 0x37, 0x7d, 0x78, 0x96, 0x0d, 0x5a, 0xea, 0xff, 0xa3,
-	0x81, 0xb3, 0x90, 0x5c, 0xaf, 0x98, 0xe8, 0x23, 0xde, 0x1b, 0x0b, 0x51, 0xf4, 0x9f, 0x88, 0x29,
-	0x9b, 0xba, 0x89, 0x3e, 0x66, 0xca, 0xb4, 0xd9, 0x6a, 0x54, 0x75, 0xf4, 0x1c, 0x1b, 0xdc, 0x15,
-	0xbd, 0x5e, 0xd3, 0x4d, 0x72, 0x0b, 0xfd, 0x17, 0x77, 0xbf, 0xda, 0xac, 0x1b, 0xe8, 0x13, 0x84,
-	0x8b, 0x00, 0xfa, 0x3b, 0x0d, 0xa2, 0x37, 0x9b, 0x95, 0xba, 0x81, 0x5e, 0x5c, 0xd9, 0x04, 0x74,
-	0x30, 0x1d, 0x30, 0x00, 0x2d, 0xe3, 0x9a, 0x51, 0xbf, 0x69, 0xa0, 0x63, 0x4c, 0x68, 0x10, 0xbd,
-	0xa1, 0x11, 0x1d, 0x29, 0x18, 0x20, 0x23, 0x9f, 0x6b, 0x26, 0xf0, 0x34, 0x64, 0x49, 0xbd, 0x5a,
-	0x5d, 0xd7, 0xca, 0xd7, 0x50, 0x72, 0xfd, 0x75, 0x98, 0x71, 0xbc, 0xd5, 0x3d, 0x27, 0xa4, 0x41,
-	0x20, 0x9e, 0xc5, 0xdf, 0x56, 0xa5, 0xe4, 0x78, 0x67, 0x44, 0xe9, 0x4c, 0xd7, 0x3b, 0xb3, 0x17,
-	0x9e, 0xe1, 0xd6, 0x33, 0x3c, 0x63, 0x6c, 0x65, 0xb8, 0x70, 0xfe, 0xd7, 0x01, 0x00, 0x00, 0xff,
-	0xff, 0xc2, 0xa3, 0x7f, 0x77, 0x74, 0x2f, 0x00, 0x00,
+	// 3459 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xec, 0x5b, 0x4b, 0x70, 0x1c, 0x49,
+	0x5a, 0x76, 0x55, 0x3f, 0xd4, 0xfd, 0xf7, 0x43, 0xa9, 0x94, 0xe4, 0xe9, 0x91, 0xe7, 0xa1, 0xa9,
+	0x99, 0xd9, 0xd5, 0x0a, 0x90, 0x3d, 0xb2, 0xd7, 0x98, 0xd9, 0x05, 0xa6, 0xd4, 0x2a, 0x79, 0xda,
+	0xee, 0x97, 0xb3, 0xab, 0xed, 0xf5, 0x04, 0x11, 0x15, 0xa5, 0xee, 0x74, 0xab, 0x42, 0xd5, 0x55,
+	0xad, 0xaa, 0x6a, 0x7b, 0xb4, 0x27, 0xc3, 0xb2, 0x2c, 0x6f, 0x96, 0xe7, 0xb2, 0x6c, 0xb0, 0x41,
+	0x04, 0x44, 0x10, 0x5c, 0x38, 0x73, 0xe6, 0x30, 0x07, 0x0e, 0x44, 0x70, 0x04, 0x0e, 0xc0, 0x81,
+	0x80, 0x13, 0x41, 0x70, 0xe0, 0xc0, 0x81, 0x20, 0xf2, 0x51, 0xd5, 0xdd, 0xea, 0x1e, 0x5b, 0xeb,
+	0x65, 0x62, 0xc3, 0x1e, 0xdf, 0xf2, 0x7f, 0xe4, 0xe3, 0xff, 0xf2, 0xaf, 0xff, 0xcf, 0xca, 0xfa,
+	0x0b, 0x0a, 0x27, 0x63, 0x1a, 0x9c, 0xee, 0x8c, 0x02, 0x3f, 0xf2, 0x71, 0x86, 0x13, 0x1b, 0xe5,
+	0xc8, 0x1f, 0xf9, 0x7d, 0x3b, 0xb2, 0x05, 0x7b, 0xa3, 0xf0, 0x30, 0x0a, 0x46, 0x3d, 0x41, 0x68,
+	0xdf, 0x54, 0x20, 0x6b, 0xda, 0xc1, 0x80, 0x46, 0x78, 0x03, 0x72, 0xc7, 0xf4, 0x34, 0x1c, 0xd9,
+	0x3d, 0x5a, 0x51, 0x36, 0x95, 0xad, 0x3c, 0x49, 0x68, 0xbc, 0x06, 0x99, 0xf0, 0xc8, 0x0e, 0xfa,
+	0x15, 0x95, 0x0b, 0x04, 0x81, 0xbf, 0x0c, 0x85, 0xc8, 0x3e, 0x74, 0x69, 0x64, 0x45, 0xa7, 0x23,
+	0x5a, 0x49, 0x6d, 0x2a, 0x5b, 0xe5, 0xdd, 0xb5, 0x9d, 0x64, 0x3e, 0x93, 0x0b, 0xcd, 0xd3, 0x11,
+	0x25, 0x10, 0x25, 0x6d, 0x8c, 0x21, 0xdd, 0xa3, 0xae, 0x5b, 0x49, 0xf3, 0xb1, 0x78, 0x5b, 0xdb,
+	0x87, 0xf2, 0x5d, 0xf3, 0xa6, 0x1d, 0xd1, 0xaa, 0xed, 0xba, 0x34, 0xa8, 0xed, 0xb3, 0xe5, 0x8c,
+	0x43, 0x1a, 0x78, 0xf6, 0x30, 0x59, 0x4e, 0x4c, 0xe3, 0x8b, 0x90, 0x1d, 0x04, 0xfe, 0x78, 0x14,
+	0x56, 0xd4, 0xcd, 0xd4, 0x56, 0x9e, 0x48, 0x4a, 0xfb, 0x39, 0x00, 0xe3, 0x21, 0xf5, 0x22, 0xd3,
+	0x3f, 0xa6, 0x1e, 0x7e, 0x0d, 0xf2, 0x91, 0x33, 0xa4, 0x61, 0x64, 0x0f, 0x47, 0x7c, 0x88, 0x14,
+	0x99, 0x30, 0x3e, 0xc5, 0xa4, 0x0d, 0xc8, 0x8d, 0xfc, 0xd0, 0x89, 0x1c, 0xdf, 0xe3, 0xf6, 0xe4,
+	0x49, 0x42, 0x6b, 0x3f, 0x03, 0x99, 0xbb, 0xb6, 0x3b, 0xa6, 0xf8, 0x4d, 0x48, 0x73, 0x83, 0x15,
+	0x6e, 0x70, 0x61, 0x47, 0x80, 0xce, 0xed, 0xe4, 0x02, 0x36, 0xf6, 0x43, 0xa6, 0xc9, 0xc7, 0x2e,
+	0x12, 0x41, 0x68, 0xc7, 0x50, 0xdc, 0x73, 0xbc, 0xfe, 0x5d, 0x3b, 0x70, 0x18, 0x18, 0xcf, 0x38,
+	0x0c, 0x7e, 0x07, 0xb2, 0xbc, 0x11, 0x56, 0x52, 0x9b, 0xa9, 0xad, 0xc2, 0x6e, 0x51, 0x76, 0xe4,
+	0x6b, 0x23, 0x52, 0xa6, 0xfd, 0xb5, 0x02, 0xb0, 0xe7, 0x8f, 0xbd, 0xfe, 0x1d, 0x26, 0xc4, 0x08,
+	0x52, 0xe1, 0x89, 0x2b, 0x81, 0x64, 0x4d, 0x7c, 0x1b, 0xca, 0x87, 0x8e, 0xd7, 0xb7, 0x1e, 0xca,
+	0xe5, 0x08, 0x2c, 0x0b, 0xbb, 0xef, 0xc8, 0xe1, 0x26, 0x9d, 0x77, 0xa6, 0x57, 0x1d, 0x1a, 0x5e,
+	0x14, 0x9c, 0x92, 0xd2, 0xe1, 0x34, 0x6f, 0xa3, 0x0b, 0x78, 0x5e, 0x89, 0x4d, 0x7a, 0x4c, 0x4f,
+	0xe3, 0x49, 0x8f, 0xe9, 0x29, 0xfe, 0xd2, 0xb4, 0x45, 0x85, 0xdd, 0xd5, 0x78, 0xae, 0xa9, 0xbe,
+	0xd2, 0xcc, 0xf7, 0xd5, 0x1b, 0x8a, 0xf6, 0x57, 0x19, 0x28, 0x1b, 0x1f, 0xd3, 0xde, 0x38, 0xa2,
+	0xad, 0x11, 0xdb, 0x83, 0x10, 0x37, 0x60, 0xd9, 0xf1, 0x7a, 0xee, 0xb8, 0x4f, 0xfb, 0xd6, 0x03,
+	0x87, 0xba, 0xfd, 0x90, 0xfb, 0x51, 0x39, 0x59, 0xf7, 0xac, 0xfe, 0x4e, 0x4d, 0x2a, 0x1f, 0x70,
+	0x5d, 0x52, 0x76, 0x66, 0x68, 0xbc, 0x0d, 0x2b, 0x3d, 0xd7, 0xa1, 0x5e, 0x64, 0x3d, 0x60, 0xf6,
+	0x5a, 0x81, 0xff, 0x28, 0xac, 0x64, 0x36, 0x95, 0xad, 0x1c, 0x59, 0x16, 0x82, 0x03, 0xc6, 0x27,
+	0xfe, 0xa3, 0x10, 0xbf, 0x0f, 0xb9, 0x47, 0x7e, 0x70, 0xec, 0xfa, 0x76, 0xbf, 0x92, 0xe5, 0x73,
+	0xbe, 0xb1, 0x78, 0xce, 0x7b, 0x52, 0x8b, 0x24, 0xfa, 0x78, 0x0b, 0x50, 0x78, 0xe2, 0x5a, 0x21,
+	0x75, 0x69, 0x2f, 0xb2, 0x5c, 0x67, 0xe8, 0x44, 0x95, 0x1c, 0x77, 0xc9, 0x72, 0x78, 0xe2, 0x76,
+	0x38, 0xbb, 0xce, 0xb8, 0xd8, 0x82, 0xf5, 0x28, 0xb0, 0xbd, 0xd0, 0xee, 0xb1, 0xc1, 0x2c, 0x27,
+	0xf4, 0x5d, 0x9b, 0xb5, 0x2a, 0x79, 0x3e, 0xe5, 0xf6, 0xe2, 0x29, 0xcd, 0x49, 0x97, 0x5a, 0xdc,
+	0x83, 0xac, 0x45, 0x0b, 0xb8, 0xf8, 0x3d, 0x58, 0x0f, 0x8f, 0x9d, 0x91, 0xc5, 0xc7, 0xb1, 0x46,
+	0xae, 0xed, 0x59, 0x3d, 0xbb, 0x77, 0x44, 0x2b, 0xc0, 0xcd, 0xc6, 0x4c, 0xc8, 0xf7, 0xbd, 0xed,
+	0xda, 0x5e, 0x95, 0x49, 0xb4, 0xaf, 0x40, 0x79, 0x16, 0x47, 0xbc, 0x02, 0x25, 0xf3, 0x7e, 0xdb,
+	0xb0, 0xf4, 0xe6, 0xbe, 0xd5, 0xd4, 0x1b, 0x06, 0xba, 0x80, 0x4b, 0x90, 0xe7, 0xac, 0x56, 0xb3,
+	0x7e, 0x1f, 0x29, 0x78, 0x09, 0x52, 0x7a, 0xbd, 0x8e, 0x54, 0xed, 0x06, 0xe4, 0x62, 0x40, 0xf0,
+	0x32, 0x14, 0xba, 0xcd, 0x4e, 0xdb, 0xa8, 0xd6, 0x0e, 0x6a, 0xc6, 0x3e, 0xba, 0x80, 0x73, 0x90,
+	0x6e, 0xd5, 0xcd, 0x36, 0x52, 0x44, 0x4b, 0x6f, 0x23, 0x95, 0xf5, 0xdc, 0xdf, 0xd3, 0x51, 0x4a,
+	0xfb, 0x73, 0x05, 0xd6, 0x16, 0x19, 0x86, 0x0b, 0xb0, 0xb4, 0x6f, 0x1c, 0xe8, 0xdd, 0xba, 0x89,
+	0x2e, 0xe0, 0x55, 0x58, 0x26, 0x46, 0xdb, 0xd0, 0x4d, 0x7d, 0xaf, 0x6e, 0x58, 0xc4, 0xd0, 0xf7,
+	0x91, 0x82, 0x31, 0x94, 0x59, 0xcb, 0xaa, 0xb6, 0x1a, 0x8d, 0x9a, 0x69, 0x1a, 0xfb, 0x48, 0xc5,
+	0x6b, 0x80, 0x38, 0xaf, 0xdb, 0x9c, 0x70, 0x53, 0x18, 0x41, 0xb1, 0x63, 0x90, 0x9a, 0x5e, 0xaf,
+	0x7d, 0xc4, 0x06, 0x40, 0x69, 0xfc, 0x16, 0xbc, 0x5e, 0x6d, 0x35, 0x3b, 0xb5, 0x8e, 0x69, 0x34,
+	0x4d, 0xab, 0xd3, 0xd4, 0xdb, 0x9d, 0x0f, 0x5b, 0x26, 0x1f, 0x59, 0x18, 0x97, 0xc1, 0x65, 0x00,
+	0xbd, 0x6b, 0xb6, 0xc4, 0x38, 0x28, 0x7b, 0x2b, 0x9d, 0x53, 0x90, 0x7a, 0x2b, 0x9d, 0x53, 0x51,
+	0xea, 0x56, 0x3a, 0x97, 0x42, 0x69, 0xed, 0x3b, 0x2a, 0x64, 0x38, 0x56, 0x2c, 0xdc, 0x4d, 0x05,
+	0x31, 0xde, 0x4e, 0x1e, 0x7d, 0xf5, 0x09, 0x8f, 0x3e, 0x8f, 0x98, 0x32, 0x08, 0x09, 0x02, 0x5f,
+	0x82, 0xbc, 0x1f, 0x0c, 0x2c, 0x21, 0x11, 0xe1, 0x33, 0xe7, 0x07, 0x03, 0x1e, 0x67, 0x59, 0xe8,
+	0x62, 0x51, 0xf7, 0xd0, 0x0e, 0x29, 0xf7, 0xe0, 0x3c, 0x49, 0x68, 0xfc, 0x2a, 0x30, 0x3d, 0x8b,
+	0xaf, 0x23, 0xcb, 0x65, 0x4b, 0x7e, 0x30, 0x68, 0xb2, 0xa5, 0xbc, 0x0d, 0xa5, 0x9e, 0xef, 0x8e,
+	0x87, 0x9e, 0xe5, 0x52, 0x6f, 0x10, 0x1d, 0x55, 0x96, 0x36, 0x95, 0xad, 0x12, 0x29, 0x0a, 0x66,
+	0x9d, 0xf3, 0x70, 0x05, 0x96, 0x7a, 0x47, 0x76, 0x10, 0x52, 0xe1, 0xb5, 0x25, 0x12, 0x93, 0x7c,
+	0x56, 0xda, 0x73, 0x86, 0xb6, 0x1b, 0x72, 0x0f, 0x2d, 0x91, 0x84, 0x66, 0x46, 0x3c, 0x70, 0xed,
+	0x41, 0xc8, 0x3d, 0xab, 0x44, 0x04, 0xa1, 0xfd, 0x24, 0xa4, 0x88, 0xff, 0x88, 0x0d, 0x29, 0x26,
+	0x0c, 0x2b, 0xca, 0x66, 0x6a, 0x0b, 0x93, 0x98, 0x64, 0xd1, 0x5d, 0x06, 0x38, 0x11, 0xf7, 0xe2,
+	0x90, 0xf6, 0x3d, 0x05, 0x0a, 0xdc, 0x31, 0x09, 0x0d, 0xc7, 0x6e, 0xc4, 0x02, 0xa1, 0x8c, 0x00,
+	0xca, 0x4c, 0x20, 0xe4, 0xb0, 0x13, 0x29, 0x63, 0xf6, 0xb1, 0x87, 0xda, 0xb2, 0x1f, 0x3c, 0xa0,
+	0xbd, 0x88, 0x8a, 0x78, 0x9f, 0x26, 0x45, 0xc6, 0xd4, 0x25, 0x8f, 0x01, 0xeb, 0x78, 0x21, 0x0d,
+	0x22, 0xcb, 0xe9, 0x73, 0xc8, 0xd3, 0x24, 0x27, 0x18, 0xb5, 0x3e, 0x7e, 0x03, 0xd2, 0x3c, 0x2c,
+	0xa4, 0xf9, 0x2c, 0x20, 0x67, 0x21, 0xfe, 0x23, 0xc2, 0xf9, 0xb7, 0xd2, 0xb9, 0x0c, 0xca, 0x6a,
+	0x5f, 0x85, 0x22, 0x5f, 0xdc, 0x3d, 0x3b, 0xf0, 0x1c, 0x6f, 0xc0, 0xb3, 0x9c, 0xdf, 0x17, 0xdb,
+	0x5e, 0x22, 0xbc, 0xcd, 0x6c, 0x1e, 0xd2, 0x30, 0xb4, 0x07, 0x54, 0x66, 0x9d, 0x98, 0xd4, 0xfe,
+	0x24, 0x05, 0x85, 0x4e, 0x14, 0x50, 0x7b, 0xc8, 0x13, 0x18, 0xfe, 0x2a, 0x40, 0x18, 0xd9, 0x11,
+	0x1d, 0x52, 0x2f, 0x8a, 0xed, 0x7b, 0x4d, 0xce, 0x3c, 0xa5, 0xb7, 0xd3, 0x89, 0x95, 0xc8, 0x94,
+	0x3e, 0xde, 0x85, 0x02, 0x65, 0x62, 0x2b, 0x62, 0x89, 0x50, 0x06, 0xdb, 0x95, 0x38, 0x72, 0x24,
+	0x19, 0x92, 0x00, 0x4d, 0xda, 0x1b, 0xdf, 0x57, 0x21, 0x9f, 0x8c, 0x86, 0x75, 0xc8, 0xf5, 0xec,
+	0x88, 0x0e, 0xfc, 0xe0, 0x54, 0xe6, 0xa7, 0x77, 0x9f, 0x34, 0xfb, 0x4e, 0x55, 0x2a, 0x93, 0xa4,
+	0x1b, 0x7e, 0x1d, 0x44, 0xd2, 0x17, 0x5e, 0x27, 0xec, 0xcd, 0x73, 0x0e, 0xf7, 0xbb, 0xf7, 0x01,
+	0x8f, 0x02, 0x67, 0x68, 0x07, 0xa7, 0xd6, 0x31, 0x3d, 0x8d, 0x63, 0x79, 0x6a, 0xc1, 0x4e, 0x22,
+	0xa9, 0x77, 0x9b, 0x9e, 0xca, 0xe8, 0x73, 0x63, 0xb6, 0xaf, 0xf4, 0x96, 0xf9, 0xfd, 0x99, 0xea,
+	0xc9, 0xb3, 0x63, 0x18, 0xe7, 0xc1, 0x0c, 0x77, 0x2c, 0xd6, 0xd4, 0xbe, 0x08, 0xb9, 0x78, 0xf1,
+	0x38, 0x0f, 0x19, 0x23, 0x08, 0xfc, 0x00, 0x5d, 0xe0, 0x41, 0xa8, 0x51, 0x17, 0x71, 0x6c, 0x7f,
+	0x9f, 0xc5, 0xb1, 0x7f, 0x51, 0x93, 0x64, 0x44, 0xe8, 0xc9, 0x98, 0x86, 0x11, 0xfe, 0x59, 0x58,
+	0xa5, 0xdc, 0x85, 0x9c, 0x87, 0xd4, 0xea, 0xf1, 0x93, 0x0b, 0x73, 0x20, 0x85, 0xe3, 0xbd, 0xbc,
+	0x23, 0x0e, 0x5a, 0xf1, 0x89, 0x86, 0xac, 0x24, 0xba, 0x92, 0xd5, 0xc7, 0x06, 0xac, 0x3a, 0xc3,
+	0x21, 0xed, 0x3b, 0x76, 0x34, 0x3d, 0x80, 0xd8, 0xb0, 0xf5, 0x38, 0xb1, 0xcf, 0x1c, 0x8c, 0xc8,
+	0x4a, 0xd2, 0x23, 0x19, 0xe6, 0x5d, 0xc8, 0x46, 0xfc, 0x10, 0xc7, 0x7d, 0xb7, 0xb0, 0x5b, 0x8a,
+	0x03, 0x0a, 0x67, 0x12, 0x29, 0xc4, 0x5f, 0x04, 0x71, 0x24, 0xe4, 0xa1, 0x63, 0xe2, 0x10, 0x93,
+	0x4c, 0x4f, 0x84, 0x1c, 0xbf, 0x0b, 0xe5, 0x99, 0x1c, 0xd4, 0xe7, 0x80, 0xa5, 0x48, 0x69, 0x8a,
+	0x5b, 0xeb, 0xe3, 0xcb, 0xb0, 0xe4, 0x8b, 0xfc, 0x53, 0xc9, 0xce, 0xac, 0x78, 0x36, 0x39, 0x91,
+	0x58, 0x0b, 0xbf, 0x09, 0x85, 0x80, 0x86, 0x34, 0x78, 0x48, 0xfb, 0x6c, 0xd0, 0x25, 0x3e, 0x28,
+	0xc4, 0xac, 0x5a, 0x5f, 0xfb, 0x69, 0x58, 0x4e, 0x20, 0x0e, 0x47, 0xbe, 0x17, 0x52, 0xbc, 0x0d,
+	0xd9, 0x80, 0x3f, 0xef, 0x12, 0x56, 0x2c, 0xe7, 0x98, 0x8a, 0x04, 0x44, 0x6a, 0x68, 0x7d, 0x58,
+	0x16, 0x9c, 0x7b, 0x4e, 0x74, 0xc4, 0x77, 0x12, 0xbf, 0x0b, 0x19, 0xca, 0x1a, 0x67, 0x36, 0x85,
+	0xb4, 0xab, 0x5c, 0x4e, 0x84, 0x74, 0x6a, 0x16, 0xf5, 0xa9, 0xb3, 0xfc, 0xa7, 0x0a, 0xab, 0x72,
+	0x95, 0x7b, 0x76, 0xd4, 0x3b, 0x7a, 0x4e, 0xbd, 0xe1, 0xc7, 0x60, 0x89, 0xf1, 0x9d, 0xe4, 0xc9,
+	0x59, 0xe0, 0x0f, 0xb1, 0x06, 0xf3, 0x08, 0x3b, 0xb4, 0xa6, 0xb6, 0x5f, 0x1e, 0x92, 0x4a, 0x76,
+	0x38, 0x95, 0xa1, 0x17, 0x38, 0x4e, 0xf6, 0x29, 0x8e, 0xb3, 0x74, 0x1e, 0xc7, 0xd1, 0xf6, 0x61,
+	0x6d, 0x16, 0x71, 0xe9, 0x1c, 0x3f, 0x0e, 0x4b, 0x62, 0x53, 0xe2, 0x18, 0xb9, 0x68, 0xdf, 0x62,
+	0x15, 0xed, 0x13, 0x15, 0xd6, 0x64, 0xf8, 0xfa, 0x7c, 0x3c, 0xc7, 0x53, 0x38, 0x67, 0xce, 0xf5,
+	0x80, 0x9e, 0x6f, 0xff, 0xb4, 0x2a, 0xac, 0x9f, 0xc1, 0xf1, 0x19, 0x1e, 0xd6, 0xff, 0x50, 0xa0,
+	0xb8, 0x47, 0x07, 0x8e, 0xf7, 0x9c, 0xee, 0xc2, 0x14, 0xb8, 0xe9, 0x73, 0x39, 0xf1, 0x08, 0x4a,
+	0xd2, 0x5e, 0x89, 0xd6, 0x3c, 0xda, 0xca, 0xa2, 0xa7, 0xe5, 0x06, 0x14, 0xe5, 0x6b, 0xb6, 0xed,
+	0x3a, 0x76, 0x98, 0xd8, 0x73, 0xe6, 0x3d, 0x5b, 0x67, 0x42, 0x52, 0x88, 0x26, 0x84, 0xf6, 0xaf,
+	0x0a, 0x94, 0xaa, 0xfe, 0x70, 0xe8, 0x44, 0xcf, 0x29, 0xc6, 0xf3, 0x08, 0xa5, 0x17, 0xf9, 0xe3,
+	0x7b, 0x50, 0x8e, 0xcd, 0x94, 0xd0, 0x9e, 0xc9, 0x34, 0xca, 0x5c, 0xa6, 0xf9, 0x37, 0x05, 0x96,
+	0x89, 0xef, 0xba, 0x87, 0x76, 0xef, 0xf8, 0xc5, 0x06, 0xe7, 0x2a, 0xa0, 0x89, 0xa1, 0xe7, 0x85,
+	0xe7, 0x7f, 0x14, 0x28, 0xb7, 0x03, 0x3a, 0xb2, 0x03, 0xfa, 0x42, 0xa3, 0xc3, 0x8e, 0xe9, 0xfd,
+	0x48, 0x1e, 0x70, 0xf2, 0x84, 0xb7, 0xb5, 0x15, 0x58, 0x4e, 0x6c, 0x17, 0x80, 0x69, 0xff, 0xa0,
+	0xc0, 0xba, 0x70, 0x31, 0x29, 0xe9, 0x3f, 0xa7, 0xb0, 0xc4, 0xf6, 0xa6, 0xa7, 0xec, 0xad, 0xc0,
+	0xc5, 0xb3, 0xb6, 0x49, 0xb3, 0xbf, 0xa1, 0xc2, 0x2b, 0xb1, 0xf3, 0x3c, 0xe7, 0x86, 0xff, 0x10,
+	0xfe, 0xb0, 0x01, 0x95, 0x79, 0x10, 0x24, 0x42, 0xdf, 0x56, 0xa1, 0x52, 0x0d, 0xa8, 0x1d, 0xd1,
+	0xa9, 0x73, 0xd0, 0x8b, 0xe3, 0x1b, 0xf8, 0x3d, 0x28, 0x8e, 0xec, 0x20, 0x72, 0x7a, 0xce, 0xc8,
+	0x66, 0xaf, 0xa2, 0x99, 0xcd, 0xd4, 0xfc, 0x00, 0x33, 0x2a, 0xda, 0x25, 0x78, 0x75, 0x01, 0x22,
+	0x12, 0xaf, 0xff, 0x55, 0x00, 0x77, 0x22, 0x3b, 0x88, 0x3e, 0x07, 0x79, 0x69, 0xa1, 0x33, 0xad,
+	0xc3, 0xea, 0x8c, 0xfd, 0xd3, 0xb8, 0xd0, 0xe8, 0x73, 0x91, 0x92, 0x3e, 0x15, 0x97, 0x69, 0xfb,
+	0x25, 0x2e, 0xff, 0xa4, 0xc0, 0x46, 0xd5, 0x17, 0x97, 0x8f, 0x2f, 0xe4, 0x13, 0xa6, 0xbd, 0x0e,
+	0x97, 0x16, 0x1a, 0x28, 0x01, 0xf8, 0x47, 0x05, 0x2e, 0x12, 0x6a, 0xf7, 0x5f, 0x4c, 0xe3, 0xef,
+	0xc0, 0x2b, 0x73, 0xc6, 0xc9, 0x33, 0xca, 0x75, 0xc8, 0x0d, 0x69, 0x64, 0xf7, 0xed, 0xc8, 0x96,
+	0x26, 0x6d, 0xc4, 0xe3, 0x4e, 0xb4, 0x1b, 0x52, 0x83, 0x24, 0xba, 0xda, 0x3f, 0xab, 0xb0, 0xca,
+	0xcf, 0xd9, 0x2f, 0x5f, 0xf2, 0xce, 0x75, 0x0b, 0x93, 0x3d, 0x7b, 0xf8, 0x63, 0x0a, 0xa3, 0x80,
+	0x5a, 0xf1, 0xed, 0xc0, 0x12, 0xff, 0xc6, 0x06, 0xa3, 0x80, 0xde, 0x11, 0x1c, 0xed, 0x6f, 0x14,
+	0x58, 0x9b, 0x85, 0x38, 0x79, 0xa3, 0xf9, 0xff, 0xbe, 0x6d, 0x59, 0x10, 0x52, 0x52, 0xe7, 0x79,
+	0x49, 0x4a, 0x9f, 0xfb, 0x25, 0xe9, 0x6f, 0x55, 0xa8, 0x4c, 0x1b, 0xf3, 0xf2, 0x4e, 0x67, 0xf6,
+	0x4e, 0xe7, 0x07, 0xbd, 0xe5, 0xd3, 0xfe, 0x4e, 0x81, 0x57, 0x17, 0x00, 0xfa, 0x83, 0xb9, 0xc8,
+	0xd4, 0xcd, 0x8e, 0xfa, 0xd4, 0x9b, 0x9d, 0xcf, 0xde, 0x49, 0xfe, 0x5e, 0x81, 0xb5, 0x86, 0xb8,
+	0xab, 0x17, 0x37, 0x1f, 0xcf, 0x6f, 0x0c, 0xe6, 0xd7, 0xf1, 0xe9, 0xc9, 0xc7, 0x28, 0x76, 0x9b,
+	0x73, 0xc6, 0xb4, 0x67, 0xb8, 0xcd, 0xf9, 0x6f, 0x05, 0x56, 0xe4, 0x28, 0x7a, 0xef, 0xf8, 0xc5,
+	0x41, 0x07, 0xbf, 0x01, 0x29, 0xa7, 0x1f, 0x9f, 0x7b, 0x67, 0xbf, 0xb5, 0x33, 0x81, 0xf6, 0x01,
+	0xe0, 0x69, 0xbb, 0x9f, 0x01, 0xba, 0x7f, 0x57, 0x61, 0x9d, 0x88, 0xe8, 0xfb, 0xf2, 0xfb, 0xc2,
+	0x0f, 0xfb, 0x7d, 0xe1, 0xc9, 0x89, 0xeb, 0x13, 0x7e, 0x98, 0x9a, 0x85, 0xfa, 0xb3, 0x4b, 0x5d,
+	0x67, 0x12, 0x6d, 0x6a, 0x2e, 0xd1, 0x3e, 0x7b, 0x3c, 0xfa, 0x44, 0x85, 0x0d, 0x69, 0xc8, 0xcb,
+	0xb3, 0xce, 0xf9, 0x3d, 0x22, 0x3b, 0xe7, 0x11, 0xff, 0xa5, 0xc0, 0xa5, 0x85, 0x40, 0xfe, 0xc8,
+	0x4f, 0x34, 0x67, 0xbc, 0x27, 0xfd, 0x54, 0xef, 0xc9, 0x9c, 0xdb, 0x7b, 0xbe, 0xa5, 0x42, 0x99,
+	0x50, 0x97, 0xda, 0xe1, 0x0b, 0x7e, 0xbb, 0x77, 0x06, 0xc3, 0xcc, 0xdc, 0x3d, 0xe7, 0x0a, 0x2c,
+	0x27, 0x40, 0xc8, 0x17, 0x2e, 0xfe, 0x82, 0xce, 0xf2, 0xe0, 0x87, 0xd4, 0x76, 0xa3, 0xf8, 0x24,
+	0xa8, 0xfd, 0xa9, 0x0a, 0x25, 0xc2, 0x38, 0xce, 0x90, 0xb2, 0xef, 0xde, 0x21, 0x7e, 0x0b, 0x8a,
+	0x47, 0x5c, 0xc5, 0x9a, 0x78, 0x48, 0x9e, 0x14, 0x04, 0x4f, 0x7c, 0x7d, 0xdc, 0x85, 0xf5, 0x90,
+	0xf6, 0x7c, 0xaf, 0x1f, 0x5a, 0x87, 0xf4, 0x88, 0x95, 0x5b, 0x0d, 0xed, 0x30, 0xa2, 0x01, 0x87,
+	0xa5, 0x44, 0x56, 0xa5, 0x70, 0x8f, 0xcb, 0x1a, 0x5c, 0x84, 0xaf, 0xc0, 0xda, 0xa1, 0xe3, 0xb9,
+	0xfe, 0x80, 0xd5, 0xe6, 0x9c, 0xd2, 0x20, 0xb4, 0x7a, 0xfe, 0xd8, 0x13, 0x78, 0x64, 0x08, 0x16,
+	0xb2, 0xb6, 0x10, 0x55, 0x99, 0x04, 0x7f, 0x04, 0xdb, 0x0b, 0x67, 0xb1, 0x1e, 0x38, 0x6e, 0x44,
+	0x03, 0xda, 0xb7, 0x02, 0x3a, 0x72, 0x9d, 0x9e, 0xa8, 0x23, 0x12, 0x40, 0x7d, 0x61, 0xc1, 0xd4,
+	0x07, 0x52, 0x9d, 0x4c, 0xb4, 0x59, 0x65, 0x44, 0x6f, 0x34, 0xb6, 0xc6, 0xbc, 0x68, 0x81, 0xe1,
+	0xa7, 0x90, 0x5c, 0x6f, 0x34, 0xee, 0x32, 0x9a, 0x7d, 0x4d, 0x3f, 0x19, 0x89, 0xe0, 0xac, 0x10,
+	0xd6, 0x64, 0x1f, 0x75, 0xca, 0xfa, 0x60, 0x10, 0xd0, 0x81, 0x1d, 0x49, 0x98, 0xae, 0xc0, 0x9a,
+	0x80, 0xe4, 0xd4, 0x92, 0xee, 0x2a, 0xec, 0x51, 0x84, 0x3d, 0x52, 0x26, 0x7c, 0x55, 0xd8, 0x73,
+	0x0d, 0x2e, 0x8e, 0xbd, 0x85, 0x7d, 0x54, 0xde, 0x67, 0x6d, 0xec, 0x2d, 0xe8, 0xf5, 0x53, 0xf0,
+	0xea, 0x62, 0x14, 0x86, 0x8e, 0xa8, 0xe5, 0x2b, 0x91, 0x8b, 0x0b, 0x8c, 0x6e, 0x38, 0xde, 0x13,
+	0xba, 0xda, 0x1f, 0x57, 0xd2, 0x9f, 0xde, 0xd5, 0xfe, 0x58, 0xfb, 0x8b, 0xe4, 0x9b, 0x62, 0xec,
+	0x2e, 0x49, 0xe0, 0x88, 0x1d, 0x59, 0x79, 0x92, 0x23, 0x57, 0x60, 0x89, 0x39, 0xa3, 0xe3, 0x0d,
+	0xb8, 0x71, 0x39, 0x12, 0x93, 0xb8, 0x03, 0x5f, 0x90, 0xb6, 0xd3, 0x8f, 0x23, 0x1a, 0x78, 0xb6,
+	0xeb, 0x9e, 0x5a, 0xe2, 0xfa, 0xd1, 0x8b, 0x68, 0xdf, 0x9a, 0xd4, 0x36, 0x8a, 0xf0, 0xf1, 0xb6,
+	0xd0, 0x36, 0x12, 0x65, 0x92, 0xe8, 0x9a, 0xb1, 0x2a, 0xfe, 0x0a, 0x94, 0x03, 0xe9, 0xc4, 0x56,
+	0xc8, 0xb6, 0x47, 0x86, 0xdc, 0x35, 0xb9, 0xba, 0x19, 0x0f, 0x27, 0xa5, 0x60, 0x9a, 0x7c, 0xf6,
+	0x80, 0x73, 0x2b, 0x9d, 0xcb, 0xa2, 0x25, 0xed, 0x2f, 0x15, 0x58, 0x5d, 0xf0, 0xee, 0x9e, 0x5c,
+	0x0c, 0x28, 0x53, 0xf7, 0x8e, 0x3f, 0x01, 0x19, 0xb6, 0xbe, 0xb8, 0x44, 0xea, 0x95, 0xf9, 0x57,
+	0x7f, 0xb6, 0x26, 0x4a, 0x84, 0x16, 0x7b, 0x16, 0xb9, 0x4d, 0x3d, 0x7e, 0xf1, 0x18, 0x47, 0xd4,
+	0x02, 0xe3, 0x89, 0xbb, 0xc8, 0xf9, 0x9b, 0xcc, 0xf4, 0xd3, 0x6f, 0x32, 0xff, 0x6c, 0x09, 0xca,
+	0x3c, 0x82, 0xd7, 0xfd, 0x01, 0xa1, 0x3d, 0x3f, 0xe8, 0xb3, 0xe2, 0xa4, 0x21, 0x8d, 0x8e, 0xfc,
+	0x78, 0xb5, 0x92, 0x9a, 0xda, 0x6e, 0xf5, 0x49, 0xdb, 0x7d, 0x09, 0xf2, 0xbc, 0xe2, 0x2e, 0x29,
+	0x98, 0x65, 0x05, 0xa6, 0xae, 0xed, 0xf1, 0xc2, 0xd8, 0xb7, 0xa0, 0xe8, 0x07, 0xce, 0xc0, 0xf1,
+	0x6c, 0xd7, 0x62, 0x55, 0x2a, 0xe2, 0x18, 0x5a, 0x88, 0x79, 0x9d, 0x13, 0x17, 0xb7, 0xe6, 0xaa,
+	0x36, 0xc5, 0xc1, 0x74, 0x6b, 0x3a, 0xdf, 0x24, 0xab, 0x7d, 0x7a, 0xe5, 0x26, 0x2f, 0x8f, 0xa2,
+	0x8f, 0x02, 0x27, 0x8a, 0xa8, 0xc7, 0x27, 0x15, 0xe5, 0x61, 0xc5, 0x84, 0xc9, 0x66, 0xdd, 0x86,
+	0x15, 0x6f, 0x3c, 0x3c, 0xa4, 0x81, 0xe5, 0x3f, 0x98, 0x3a, 0x5d, 0x31, 0x88, 0x97, 0x85, 0xa0,
+	0xf5, 0x40, 0x26, 0x54, 0x56, 0xf6, 0x13, 0x46, 0x76, 0x10, 0x71, 0xff, 0x94, 0x35, 0x8e, 0x79,
+	0xce, 0x61, 0x5e, 0xc8, 0x2a, 0xd1, 0xa8, 0x27, 0x9c, 0x97, 0xd7, 0x8b, 0xa5, 0xc8, 0x12, 0xf5,
+	0xb8, 0x83, 0xb2, 0x9e, 0x91, 0x1f, 0xd9, 0xae, 0x10, 0x82, 0xe8, 0xc9, 0x39, 0xb1, 0x78, 0x78,
+	0x1a, 0x9e, 0x48, 0x71, 0x41, 0x88, 0x39, 0x87, 0x8b, 0xdf, 0x81, 0x72, 0xcf, 0xf7, 0x3c, 0xeb,
+	0x91, 0xed, 0xc8, 0xb9, 0x8b, 0x5c, 0xa5, 0xc8, 0xb8, 0xf7, 0x6c, 0x47, 0x4c, 0x7f, 0x0d, 0x2e,
+	0xf6, 0x7c, 0x2f, 0xf4, 0x5d, 0xa7, 0x6f, 0x47, 0x7e, 0x30, 0xa5, 0x5d, 0xe2, 0xda, 0x6b, 0xd3,
+	0xd2, 0xa4, 0xd7, 0xdb, 0x50, 0x12, 0xd5, 0x92, 0xa1, 0x3f, 0x0e, 0x7a, 0x34, 0xac, 0x94, 0x45,
+	0x8d, 0x1c, 0x67, 0x76, 0x04, 0x6f, 0xbe, 0xd0, 0x6c, 0x59, 0xcc, 0x3f, 0x53, 0x68, 0x16, 0x2b,
+	0x05, 0x34, 0x1a, 0x07, 0x1e, 0xed, 0x57, 0xd0, 0x44, 0x89, 0x48, 0x9e, 0xd8, 0x13, 0x11, 0x46,
+	0xac, 0xd0, 0xf9, 0x3a, 0xad, 0xac, 0x48, 0x25, 0xc9, 0xec, 0x38, 0x5f, 0x5f, 0xf4, 0xf1, 0x18,
+	0x9f, 0x23, 0x03, 0xae, 0xce, 0x9d, 0x22, 0xd6, 0xe2, 0x03, 0xce, 0x9a, 0xa8, 0x34, 0xe4, 0x04,
+	0xfe, 0x12, 0xa0, 0xb3, 0xd9, 0xbc, 0xb2, 0xce, 0x15, 0x96, 0xcf, 0xe4, 0x6c, 0xa6, 0x7a, 0xf6,
+	0xe4, 0x50, 0xb9, 0x28, 0x54, 0xcf, 0x9c, 0x12, 0x3e, 0xa3, 0x32, 0xe1, 0xed, 0xdf, 0x49, 0x41,
+	0xbe, 0x71, 0xda, 0x39, 0x71, 0x0f, 0x5c, 0x7b, 0xc0, 0x8b, 0xb8, 0x1a, 0x6d, 0xf3, 0x3e, 0xba,
+	0xc0, 0xaa, 0x54, 0x9b, 0x2d, 0xd3, 0x6a, 0x76, 0xeb, 0x75, 0xeb, 0xa0, 0xae, 0xdf, 0x44, 0x0a,
+	0x2b, 0xf7, 0x6c, 0x93, 0x9a, 0x75, 0xdb, 0xb8, 0x2f, 0x38, 0x2a, 0xab, 0x1f, 0xed, 0x36, 0x6b,
+	0x77, 0xba, 0xc6, 0x84, 0x99, 0xc6, 0xeb, 0xb0, 0xd2, 0xe8, 0xd6, 0xcd, 0x5a, 0xbb, 0x3e, 0xc5,
+	0xce, 0xb1, 0x1a, 0xd7, 0xbd, 0x7a, 0x6b, 0x4f, 0x90, 0x88, 0x8d, 0xdf, 0x6d, 0x76, 0x6a, 0x37,
+	0x9b, 0xc6, 0xbe, 0x60, 0x6d, 0x32, 0xd6, 0x47, 0x06, 0x69, 0x1d, 0xd4, 0xe2, 0x29, 0x3f, 0xc0,
+	0x08, 0x0a, 0x7b, 0xb5, 0xa6, 0x4e, 0xe4, 0x28, 0x8f, 0x15, 0x5c, 0x86, 0xbc, 0xd1, 0xec, 0x36,
+	0x24, 0xad, 0xe2, 0x0a, 0xac, 0xb2, 0x72, 0x52, 0xab, 0xd6, 0xac, 0x12, 0xa3, 0xc1, 0xaa, 0x4e,
+	0x85, 0x24, 0x8d, 0x57, 0xa1, 0x6c, 0xd6, 0x1a, 0x46, 0xc7, 0xd4, 0x1b, 0x6d, 0xc9, 0x64, 0xab,
+	0xc8, 0x75, 0x8c, 0x58, 0x07, 0xe1, 0x0d, 0x58, 0x6f, 0xb6, 0x2c, 0x59, 0x10, 0x6b, 0xdd, 0xd5,
+	0xeb, 0x5d, 0x43, 0xca, 0x36, 0xf1, 0x2b, 0x80, 0x5b, 0x4d, 0xab, 0xdb, 0xde, 0xd7, 0x4d, 0xc3,
+	0x6a, 0xb6, 0xee, 0x49, 0xc1, 0x07, 0xb8, 0x0c, 0xb9, 0xc9, 0x0a, 0x1e, 0x33, 0x14, 0x4a, 0x6d,
+	0x9d, 0x98, 0x13, 0x63, 0x1f, 0x3f, 0x66, 0x60, 0xc1, 0x4d, 0xd2, 0xea, 0xb6, 0x27, 0x6a, 0x2b,
+	0x50, 0x90, 0x60, 0x49, 0x56, 0x9a, 0xb1, 0xf6, 0x6a, 0xcd, 0x6a, 0xb2, 0xbe, 0xc7, 0xb9, 0x0d,
+	0x15, 0x29, 0xdb, 0xc7, 0x90, 0xe6, 0xdb, 0x91, 0x83, 0x74, 0xb3, 0xd5, 0x64, 0x05, 0xc2, 0xcb,
+	0x00, 0xb5, 0x4e, 0xad, 0x69, 0x1a, 0x37, 0x89, 0x5e, 0x67, 0x66, 0x73, 0x46, 0x0c, 0x20, 0xb3,
+	0xb6, 0x08, 0x4b, 0xb5, 0xce, 0x41, 0xbd, 0xa5, 0x9b, 0xd2, 0xcc, 0x5a, 0xe7, 0x4e, 0xb7, 0xc5,
+	0xea, 0x74, 0x1f, 0x23, 0x5c, 0x80, 0x2c, 0x2b, 0xc9, 0xfd, 0x9a, 0xc9, 0xec, 0xe2, 0x32, 0x81,
+	0x2a, 0x7a, 0xfc, 0xc1, 0xf6, 0x77, 0x53, 0x90, 0xe6, 0x21, 0xb4, 0x04, 0x79, 0xbe, 0xdb, 0xac,
+	0x12, 0x19, 0x5d, 0xc0, 0x79, 0x48, 0xd7, 0x9a, 0xe6, 0x0d, 0xf4, 0xf3, 0x2a, 0x06, 0xc8, 0x74,
+	0x79, 0xfb, 0x17, 0xb2, 0xac, 0x5d, 0x6b, 0x9a, 0xef, 0x5d, 0x47, 0xdf, 0x50, 0xd9, 0xb0, 0x5d,
+	0x41, 0xfc, 0x62, 0x2c, 0xd8, 0xbd, 0x86, 0xbe, 0x99, 0x08, 0x76, 0xaf, 0xa1, 0x5f, 0x8a, 0x05,
+	0x57, 0x77, 0xd1, 0xb7, 0x12, 0xc1, 0xd5, 0x5d, 0xf4, 0xcb, 0xb1, 0xe0, 0xfa, 0x35, 0xf4, 0x2b,
+	0x89, 0xe0, 0xfa, 0x35, 0xf4, 0xab, 0x59, 0x66, 0x0b, 0xb7, 0xe4, 0xea, 0x2e, 0xfa, 0xb5, 0x5c,
+	0x42, 0x5d, 0xbf, 0x86, 0x7e, 0x3d, 0xc7, 0xf6, 0x3f, 0xd9, 0x55, 0xf4, 0x1b, 0x88, 0x2d, 0x93,
+	0x6d, 0x10, 0xfa, 0x4d, 0xde, 0x64, 0x22, 0xf4, 0x5b, 0x88, 0xd9, 0xc8, 0xb8, 0x9c, 0xfc, 0x36,
+	0x97, 0xdc, 0x37, 0x74, 0x82, 0x7e, 0x3b, 0x2b, 0xea, 0x9f, 0xab, 0xb5, 0x86, 0x5e, 0x47, 0x98,
+	0xf7, 0x60, 0xa8, 0xfc, 0xee, 0x15, 0xd6, 0x64, 0xee, 0x89, 0x7e, 0xaf, 0xcd, 0x26, 0xbc, 0xab,
+	0x93, 0xea, 0x87, 0x3a, 0x41, 0xbf, 0x7f, 0x85, 0x4d, 0x78, 0x57, 0x27, 0x12, 0xaf, 0x3f, 0x68,
+	0x33, 0x45, 0x2e, 0xfa, 0xce, 0x15, 0xb6, 0x68, 0xc9, 0xff, 0xc3, 0x36, 0xce, 0x41, 0x6a, 0xaf,
+	0x66, 0xa2, 0xef, 0xf2, 0xd9, 0x98, 0x8b, 0xa2, 0x3f, 0x42, 0x8c, 0xd9, 0x31, 0x4c, 0xf4, 0x3d,
+	0xc6, 0xcc, 0x98, 0xdd, 0x76, 0xdd, 0x40, 0xaf, 0xb1, 0xc5, 0xdd, 0x34, 0x5a, 0x0d, 0xc3, 0x24,
+	0xf7, 0xd1, 0x1f, 0x73, 0xf5, 0x5b, 0x9d, 0x56, 0x13, 0x7d, 0x1f, 0xb1, 0xda, 0x68, 0xe3, 0x6b,
+	0x6d, 0x62, 0x74, 0x3a, 0xb5, 0x56, 0x13, 0xbd, 0xb9, 0x7d, 0x00, 0xe8, 0x6c, 0xd6, 0x66, 0x06,
+	0x74, 0x9b, 0xb7, 0x9b, 0xad, 0x7b, 0x4d, 0x74, 0x81, 0x11, 0x6d, 0x62, 0xb4, 0x75, 0x62, 0x20,
+	0x05, 0x03, 0x64, 0x65, 0x55, 0xb5, 0x8a, 0x8b, 0x90, 0x23, 0xad, 0x7a, 0x7d, 0x4f, 0xaf, 0xde,
+	0x46, 0xa9, 0xbd, 0x2f, 0xc3, 0xb2, 0xe3, 0xef, 0x3c, 0x74, 0x22, 0x1a, 0x86, 0xe2, 0xef, 0x95,
+	0x8f, 0x34, 0x49, 0x39, 0xfe, 0x65, 0xd1, 0xba, 0x3c, 0xf0, 0x2f, 0x3f, 0x8c, 0x2e, 0x73, 0xe9,
+	0x65, 0x1e, 0x34, 0x0e, 0xb3, 0x9c, 0xb8, 0xfa, 0x7f, 0x03, 0x00, 0xe9, 0x59, 0x34, 0x53, 0x1b,
+	0x33, 0x00, 0x00,
 }
@@ -49,12 +49,15 @@ type fileLogger struct {
 
 func (l *fileLogger) Stop() {
 	tabletenv.StatsLogger.Unsubscribe(l.logChan)
+	// Closing the channel closes the file, which flushes the
+	// compressed output if querylog-gzip is set.
+	close(l.logChan)
 }
 
 // Init starts logging to the given file path.
 func Init(path string) (FileLogger, error) {
 	log.Infof("Logging queries to file %s", path)
-	logChan, err := tabletenv.StatsLogger.LogToFile(path, streamlog.GetFormatter(tabletenv.StatsLogger))
+	logChan, err := tabletenv.StatsLogger.LogToFileWithWrapper(path, streamlog.GetFormatter(tabletenv.StatsLogger), tabletenv.QueryLogWriterWrapper())
 	if err != nil {
 		return nil, err
 	}
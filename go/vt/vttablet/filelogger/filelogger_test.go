@@ -17,10 +17,12 @@ limitations under the License.
 package filelogger
 
 import (
+	"compress/gzip"
 	"context"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,6 +30,14 @@ import (
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
 )
 
+// newColumns returns the trailing columns of the text format, after
+// the error: shard queries, fingerprint, plan and commit times, plan id
+// and rows returned. It caches the fingerprint of logStats, so it must
+// be called before logStats is sent.
+func newColumns(logStats *tabletenv.LogStats) string {
+	return "\t0\t" + logStats.QueryFingerprint() + "\t0.000000\t0.000000\t\"\"\t0\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tUNSPECIFIED\t0\t\"\"\t\"\"\t\"\"\t1\t\"\"\t\"\"\t0\t0\t\"\"\t\n"
+}
+
 // TestFileLog sends a stream of five query records to the plugin, and verifies that they are logged.
 func TestFileLog(t *testing.T) {
 	dir, err := ioutil.TempDir("", "filelogger_test")
@@ -51,6 +61,7 @@ func TestFileLog(t *testing.T) {
 	}
 	log1.AddRewrittenSQL("test 1 PII", time.Time{})
 	log1.MysqlResponseTime = 0
+	columns1 := newColumns(log1)
 	tabletenv.StatsLogger.Send(log1)
 
 	log2 := &tabletenv.LogStats{
@@ -59,13 +70,15 @@ func TestFileLog(t *testing.T) {
 	}
 	log2.AddRewrittenSQL("test 2 PII", time.Time{})
 	log2.MysqlResponseTime = 0
+	columns2 := newColumns(log2)
 	tabletenv.StatsLogger.Send(log2)
 
 	// Allow time for propagation
 	for i := 0; i < 10; i++ {
 		time.Sleep(10 * time.Millisecond)
 
-		want := "\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 1\"\tmap[]\t1\t\"test 1 PII\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"\t\n\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 2\"\tmap[]\t1\t\"test 2 PII\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"\t\n"
+		want := "\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 1\"\tmap[]\t1\t\"test 1 PII\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"" + columns1 +
+			"\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 2\"\tmap[]\t1\t\"test 2 PII\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"" + columns2
 		contents, _ := ioutil.ReadFile(logPath)
 		got := string(contents)
 		if want == got {
@@ -106,6 +119,7 @@ func TestFileLogRedacted(t *testing.T) {
 	}
 	log1.AddRewrittenSQL("test 1 PII", time.Time{})
 	log1.MysqlResponseTime = 0
+	columns1 := newColumns(log1)
 	tabletenv.StatsLogger.Send(log1)
 
 	log2 := &tabletenv.LogStats{
@@ -114,15 +128,81 @@ func TestFileLogRedacted(t *testing.T) {
 	}
 	log2.AddRewrittenSQL("test 2 PII", time.Time{})
 	log2.MysqlResponseTime = 0
+	columns2 := newColumns(log2)
 	tabletenv.StatsLogger.Send(log2)
 
 	// Allow time for propagation
 	time.Sleep(10 * time.Millisecond)
 
-	want := "\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 1\"\t\"[REDACTED]\"\t1\t\"[REDACTED]\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"\t\n\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 2\"\t\"[REDACTED]\"\t1\t\"[REDACTED]\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"\t\n"
+	want := "\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 1\"\t\"[REDACTED]\"\t1\t\"[REDACTED]\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"" + columns1 +
+		"\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\t\t\"test 2\"\t\"[REDACTED]\"\t1\t\"[REDACTED]\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"" + columns2
 	contents, _ := ioutil.ReadFile(logPath)
 	got := string(contents)
 	if want != string(got) {
 		t.Errorf("streamlog file: want %q got %q", want, got)
 	}
 }
+
+// TestFileLogGzip sends query records to the plugin with querylog-gzip set,
+// and verifies that the file decompresses to the complete records.
+func TestFileLogGzip(t *testing.T) {
+	*tabletenv.QueryLogGzip = true
+	defer func() {
+		*tabletenv.QueryLogGzip = false
+	}()
+
+	dir, err := ioutil.TempDir("", "filelogger_test")
+	if err != nil {
+		t.Fatalf("error getting tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	logPath := path.Join(dir, "test.log")
+	logger, err := Init(logPath)
+	if err != nil {
+		t.Fatalf("error setting up file logger: %v", err)
+	}
+
+	// The expected records are formatted like the plain log, so that the
+	// test doesn't depend on the columns of the text format.
+	ctx := context.Background()
+	var want strings.Builder
+	for _, sql := range []string{"test 1", "test 2"} {
+		logStats := &tabletenv.LogStats{
+			Ctx:         ctx,
+			OriginalSQL: sql,
+		}
+		logStats.AddRewrittenSQL(sql+" PII", time.Time{})
+		logStats.MysqlResponseTime = 0
+		if err := logStats.Logf(&want, nil); err != nil {
+			t.Fatalf("error formatting log record: %v", err)
+		}
+		tabletenv.StatsLogger.Send(logStats)
+	}
+
+	// Allow time for propagation, then stop to write the gzip footer.
+	time.Sleep(10 * time.Millisecond)
+	logger.Stop()
+
+	for i := 0; i < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+
+		f, err := os.Open(logPath)
+		if err != nil {
+			t.Fatalf("error opening log file: %v", err)
+		}
+		got := ""
+		if gz, err := gzip.NewReader(f); err == nil {
+			contents, _ := ioutil.ReadAll(gz)
+			got = string(contents)
+		}
+		f.Close()
+		if want.String() == got {
+			return
+		}
+		// Last iteration.
+		if i == 9 {
+			t.Errorf("streamlog file: want %q got %q", want.String(), got)
+		}
+	}
+}
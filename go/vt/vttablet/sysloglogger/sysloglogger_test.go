@@ -86,14 +86,19 @@ func (fw *failingFakeWriter) Close() error          { return nil }
 // expectedLogStatsText returns the results expected from the plugin processing a dummy message generated by mockLogStats(...).
 func expectedLogStatsText(originalSQL string) string {
 	return fmt.Sprintf("Execute\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\tPASS_SELECT\t"+
-		"\"%s\"\t%s\t1\t\"%s\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"", originalSQL, "map[]", originalSQL)
+		"\"%s\"\t%s\t1\t\"%s\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"\t0\t%s\t0.000000\t0.000000\t\"\"\t0\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"\"\t\"\"\t0\t0\t\"\"", originalSQL, "map[]", originalSQL, fingerprint(originalSQL))
 }
 
 // expectedRedactedLogStatsText returns the results expected from the plugin processing a dummy message generated by mockLogStats(...)
 // when redaction is enabled.
 func expectedRedactedLogStatsText(originalSQL string) string {
 	return fmt.Sprintf("Execute\t\t\t''\t''\t0001-01-01 00:00:00.000000\t0001-01-01 00:00:00.000000\t0.000000\tPASS_SELECT\t"+
-		"\"%s\"\t%q\t1\t\"%s\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"", originalSQL, "[REDACTED]", "[REDACTED]")
+		"\"%s\"\t%q\t1\t\"%s\"\tmysql\t0.000000\t0.000000\t0\t0\t\"\"\t0\t%s\t0.000000\t0.000000\t\"\"\t0\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"\"\t\"\"\t0\t0\t\"\"", originalSQL, "[REDACTED]", "[REDACTED]", fingerprint(originalSQL))
+}
+
+// fingerprint returns the query fingerprint logged for originalSQL.
+func fingerprint(originalSQL string) string {
+	return (&tabletenv.LogStats{OriginalSQL: originalSQL}).QueryFingerprint()
 }
 
 // TestSyslog sends a stream of five query records to the plugin, and verifies that they are logged.
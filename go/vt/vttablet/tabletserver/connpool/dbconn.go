@@ -51,6 +51,9 @@ type DBConn struct {
 	dbaPool *dbconnpool.ConnectionPool
 	stats   *tabletenv.Stats
 	current sync2.AtomicString
+	// warnings is the warning count of the last query executed
+	// by Exec or ExecOnce.
+	warnings uint16
 }
 
 // NewDBConn creates a new DBConn. It triggers a CheckMySQL if creation fails.
@@ -149,7 +152,31 @@ func (dbc *DBConn) execOnce(ctx context.Context, query string, maxrows int, want
 	}
 	// Uncomment this line for manual testing.
 	// defer time.Sleep(20 * time.Second)
-	return dbc.conn.ExecuteFetch(query, maxrows, wantfields)
+	result, warnings, err := dbc.conn.ExecuteFetchWithWarningCount(query, maxrows, wantfields)
+	dbc.warnings = warnings
+	return result, err
+}
+
+// Warnings returns the warnings raised by the last query executed by
+// Exec or ExecOnce, formatted as "<Level> <Code>: <Message>". It only
+// runs SHOW WARNINGS if MySQL reported warnings for that query, and
+// returns nil otherwise.
+func (dbc *DBConn) Warnings(ctx context.Context) ([]string, error) {
+	if dbc.warnings == 0 {
+		return nil, nil
+	}
+	qr, err := dbc.execOnce(ctx, "show warnings", int(dbc.warnings), false)
+	if err != nil {
+		return nil, err
+	}
+	warnings := make([]string, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		if len(row) < 3 {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s %s: %s", row[0].ToString(), row[1].ToString(), row[2].ToString()))
+	}
+	return warnings, nil
 }
 
 // ExecOnce executes the specified query, but does not retry on connection errors.
@@ -115,6 +115,50 @@ func TestDBConnExec(t *testing.T) {
 	compareTimingCounts(t, "PoolTest.Exec", 1, startCounts, mysqlTimings.Counts())
 }
 
+func TestDBConnWarnings(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	sql := "insert into test_table values ('abc')"
+	db.AddQuery(sql, &sqltypes.Result{RowsAffected: 1})
+	db.AddQuery("show warnings", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Level|Code|Message", "varchar|int64|varchar"),
+		"Warning|1265|Data truncated for column 'a' at row 1",
+	))
+	connPool := newPool()
+	connPool.Open(db.ConnParams(), db.ConnParams(), db.ConnParams())
+	defer connPool.Close()
+	ctx := context.Background()
+	dbConn, err := NewDBConn(ctx, connPool, db.ConnParams())
+	if dbConn != nil {
+		defer dbConn.Close()
+	}
+	if err != nil {
+		t.Fatalf("should not get an error, err: %v", err)
+	}
+
+	// No query is issued if mysql reports no warnings.
+	if _, err := dbConn.Exec(ctx, sql, 1, false); err != nil {
+		t.Fatalf("should not get an error, err: %v", err)
+	}
+	warnings, err := dbConn.Warnings(ctx)
+	if err != nil || len(warnings) != 0 {
+		t.Errorf("Warnings: %v, %v, want none", warnings, err)
+	}
+	if got := db.GetQueryCalledNum("show warnings"); got != 0 {
+		t.Errorf("show warnings was called %d times, want 0", got)
+	}
+
+	db.SetWarningCount(1)
+	if _, err := dbConn.Exec(ctx, sql, 1, false); err != nil {
+		t.Fatalf("should not get an error, err: %v", err)
+	}
+	warnings, err = dbConn.Warnings(ctx)
+	want := []string{"Warning 1265: Data truncated for column 'a' at row 1"}
+	if err != nil || !reflect.DeepEqual(warnings, want) {
+		t.Errorf("Warnings: %v, %v, want %v", warnings, err, want)
+	}
+}
+
 func TestDBConnDeadline(t *testing.T) {
 	db := fakesqldb.New(t)
 	defer db.Close()
@@ -139,6 +139,10 @@ type Plan struct {
 	// WhereClause is set for DMLs. It is used by the hot row protection
 	// to serialize e.g. UPDATEs going to the same row.
 	WhereClause *sqlparser.ParsedQuery
+
+	// SavepointName is set for the savepoint, release savepoint and
+	// rollback to savepoint statements.
+	SavepointName string
 }
 
 // TableName returns the table name for the plan.
@@ -187,11 +191,11 @@ func Build(statement sqlparser.Statement, tables map[string]*schema.Table) (*Pla
 	case *sqlparser.OtherAdmin:
 		plan, err = &Plan{PlanID: PlanOtherAdmin}, nil
 	case *sqlparser.Savepoint:
-		plan, err = &Plan{PlanID: PlanSavepoint}, nil
+		plan, err = &Plan{PlanID: PlanSavepoint, SavepointName: stmt.Name.String()}, nil
 	case *sqlparser.Release:
-		plan, err = &Plan{PlanID: PlanRelease}, nil
+		plan, err = &Plan{PlanID: PlanRelease, SavepointName: stmt.Name.String()}, nil
 	case *sqlparser.SRollback:
-		plan, err = &Plan{PlanID: PlanSRollback}, nil
+		plan, err = &Plan{PlanID: PlanSRollback, SavepointName: stmt.Name.String()}, nil
 	default:
 		return nil, vterrors.New(vtrpcpb.Code_INVALID_ARGUMENT, "invalid SQL")
 	}
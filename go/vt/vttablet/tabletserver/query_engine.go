@@ -28,6 +28,7 @@ import (
 
 	"vitess.io/vitess/go/acl"
 	"vitess.io/vitess/go/cache"
+	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/stats"
 	"vitess.io/vitess/go/streamlog"
 	"vitess.io/vitess/go/sync2"
@@ -290,6 +291,8 @@ func (qe *QueryEngine) GetPlan(ctx context.Context, logStats *tabletenv.LogStats
 	defer span.Finish()
 
 	if plan := qe.getQuery(sql); plan != nil {
+		logStats.PlanID = sql
+		logStats.PlanCacheHit = true
 		return plan, nil
 	}
 
@@ -334,6 +337,7 @@ func (qe *QueryEngine) GetPlan(ctx context.Context, logStats *tabletenv.LogStats
 	}
 	if !skipQueryPlanCache && !sqlparser.SkipQueryPlanCacheDirective(statement) {
 		qe.plans.Set(sql, plan)
+		logStats.PlanID = sql
 	}
 	return plan, nil
 }
@@ -383,6 +387,33 @@ func (qe *QueryEngine) IsMySQLReachable() error {
 	return nil
 }
 
+// IsSuperReadOnly returns true if mysql has super_read_only set.
+func (qe *QueryEngine) IsSuperReadOnly(ctx context.Context) (bool, error) {
+	conn, err := dbconnpool.NewDBConnection(ctx, qe.env.Config().DB.AppWithDB())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	qr, err := conn.ExecuteFetch("select @@global.super_read_only", 1, false)
+	if err != nil {
+		return false, err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 {
+		return false, fmt.Errorf("unexpected result for super_read_only: %v", qr.Rows)
+	}
+	return qr.Rows[0][0].ToString() == "1", nil
+}
+
+// MasterPosition returns the current replication position of mysql.
+func (qe *QueryEngine) MasterPosition(ctx context.Context) (mysql.Position, error) {
+	conn, err := dbconnpool.NewDBConnection(ctx, qe.env.Config().DB.AppWithDB())
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	defer conn.Close()
+	return conn.MasterPosition()
+}
+
 func (qe *QueryEngine) schemaChanged(tables map[string]*schema.Table, created, altered, dropped []string) {
 	qe.mu.Lock()
 	defer qe.mu.Unlock()
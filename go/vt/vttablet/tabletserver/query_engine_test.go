@@ -170,6 +170,22 @@ func TestQueryPlanCache(t *testing.T) {
 	if secondPlan == nil {
 		t.Fatalf("plan should not be nil")
 	}
+	if logStats.PlanID != secondQuery {
+		t.Errorf("PlanID: %q, want %q", logStats.PlanID, secondQuery)
+	}
+	if logStats.PlanCacheHit {
+		t.Errorf("PlanCacheHit: true, want false")
+	}
+	logStats = tabletenv.NewLogStats(ctx, "GetPlanStats")
+	if _, err := qe.GetPlan(ctx, logStats, secondQuery, false); err != nil {
+		t.Fatal(err)
+	}
+	if logStats.PlanID != secondQuery {
+		t.Errorf("cached PlanID: %q, want %q", logStats.PlanID, secondQuery)
+	}
+	if !logStats.PlanCacheHit {
+		t.Errorf("cached PlanCacheHit: false, want true")
+	}
 	expvar.Do(func(kv expvar.KeyValue) {
 		_ = kv.Value.String()
 	})
@@ -208,6 +224,9 @@ func TestNoQueryPlanCache(t *testing.T) {
 	if qe.plans.Size() != 0 {
 		t.Fatalf("query plan cache should be 0")
 	}
+	if logStats.PlanID != "" {
+		t.Errorf("PlanID: %q, want empty", logStats.PlanID)
+	}
 	qe.ClearQueryPlanCache()
 }
 
@@ -90,6 +90,7 @@ func (qre *QueryExecutor) Execute() (reply *sqltypes.Result, err error) {
 		qre.plan.AddStats(1, duration, mysqlTime, int64(reply.RowsAffected), 0)
 		qre.logStats.RowsAffected = int(reply.RowsAffected)
 		qre.logStats.Rows = reply.Rows
+		qre.logStats.ColumnCount = len(reply.Fields)
 		qre.tsv.Stats().ResultHistogram.Add(int64(len(reply.Rows)))
 	}(time.Now())
 
@@ -117,6 +118,9 @@ func (qre *QueryExecutor) Execute() (reply *sqltypes.Result, err error) {
 			return nil, err
 		}
 		defer conn.Unlock()
+		qre.logStats.ReservedConn = conn.IsTainted()
+		qre.logStats.TransactionMode = qre.tsv.te.transactionMode(conn)
+		qre.logStats.IsolationLevel = qre.tsv.te.isolationLevel(conn)
 		return qre.txConnExec(conn)
 	}
 
@@ -191,6 +195,7 @@ func (qre *QueryExecutor) execAsTransaction(f func(conn *StatefulConnection) (*s
 }
 
 func (qre *QueryExecutor) txConnExec(conn *StatefulConnection) (*sqltypes.Result, error) {
+	qre.logStats.SavepointDepth = conn.TxProperties().SavepointDepth()
 	switch qre.plan.PlanID {
 	case planbuilder.PlanInsert, planbuilder.PlanUpdate, planbuilder.PlanDelete:
 		return qre.txFetch(conn, true)
@@ -202,7 +207,7 @@ func (qre *QueryExecutor) txConnExec(conn *StatefulConnection) (*sqltypes.Result
 	case planbuilder.PlanSet, planbuilder.PlanOtherRead, planbuilder.PlanOtherAdmin:
 		return qre.execSQL(conn, qre.query, true)
 	case planbuilder.PlanSavepoint, planbuilder.PlanRelease, planbuilder.PlanSRollback:
-		return qre.execSQL(conn, qre.query, true)
+		return qre.execSavepoint(conn)
 	case planbuilder.PlanSelect, planbuilder.PlanSelectLock, planbuilder.PlanSelectImpossible:
 		maxrows := qre.getSelectLimit()
 		qre.bindVars["#maxLimit"] = sqltypes.Int64BindVariable(maxrows + 1)
@@ -220,6 +225,25 @@ func (qre *QueryExecutor) txConnExec(conn *StatefulConnection) (*sqltypes.Result
 	return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "%s unexpected plan type", qre.plan.PlanID.String())
 }
 
+// execSavepoint executes a savepoint statement in a transaction, and
+// tracks the resulting savepoints in the transaction properties.
+func (qre *QueryExecutor) execSavepoint(conn *StatefulConnection) (*sqltypes.Result, error) {
+	qr, err := qre.execSQL(conn, qre.query, true)
+	if err != nil {
+		return nil, err
+	}
+	txProps := conn.TxProperties()
+	switch qre.plan.PlanID {
+	case planbuilder.PlanSavepoint:
+		txProps.RecordSavepoint(qre.plan.SavepointName)
+	case planbuilder.PlanRelease:
+		txProps.ReleaseSavepoint(qre.plan.SavepointName)
+	case planbuilder.PlanSRollback:
+		txProps.RollbackToSavepoint(qre.plan.SavepointName)
+	}
+	return qr, nil
+}
+
 // Stream performs a streaming query execution.
 func (qre *QueryExecutor) Stream(callback func(*sqltypes.Result) error) error {
 	qre.logStats.PlanType = qre.plan.PlanID.String()
@@ -241,6 +265,9 @@ func (qre *QueryExecutor) Stream(callback func(*sqltypes.Result) error) error {
 			return err
 		}
 		defer txConn.Unlock()
+		qre.logStats.ReservedConn = txConn.IsTainted()
+		qre.logStats.TransactionMode = qre.tsv.te.transactionMode(txConn)
+		qre.logStats.IsolationLevel = qre.tsv.te.isolationLevel(txConn)
 		conn = txConn.UnderlyingDBConn()
 	} else {
 		dbConn, err := qre.getStreamConn()
@@ -537,7 +564,7 @@ func (qre *QueryExecutor) getConn() (*connpool.DBConn, error) {
 	conn, err := qre.tsv.qe.conns.Get(ctx)
 	switch err {
 	case nil:
-		qre.logStats.WaitingForConnection += time.Since(start)
+		qre.logStats.AddConnWait(start)
 		return conn, nil
 	case connpool.ErrConnPoolClosed:
 		return nil, err
@@ -553,7 +580,7 @@ func (qre *QueryExecutor) getStreamConn() (*connpool.DBConn, error) {
 	conn, err := qre.tsv.qe.streamConns.Get(ctx)
 	switch err {
 	case nil:
-		qre.logStats.WaitingForConnection += time.Since(start)
+		qre.logStats.AddConnWait(start)
 		return conn, nil
 	case connpool.ErrConnPoolClosed:
 		return nil, err
@@ -580,9 +607,9 @@ func (qre *QueryExecutor) qFetch(logStats *tabletenv.LogStats, parsedQuery *sqlp
 				q.Result, q.Err = qre.execSQL(conn, sql, false)
 			}
 		} else {
-			logStats.QuerySources |= tabletenv.QuerySourceConsolidator
 			startTime := time.Now()
 			q.Wait()
+			logStats.AddConsolidatorWait(startTime)
 			qre.tsv.stats.WaitTimings.Record("Consolidations", startTime)
 		}
 		if q.Err != nil {
@@ -664,6 +691,7 @@ func (qre *QueryExecutor) getSelectLimit() int64 {
 // executor is an abstraction for reusing code in execSQL.
 type executor interface {
 	Exec(ctx context.Context, query string, maxrows int, wantfields bool) (*sqltypes.Result, error)
+	Warnings(ctx context.Context) ([]string, error)
 }
 
 func (qre *QueryExecutor) execSQL(conn executor, sql string, wantfields bool) (*sqltypes.Result, error) {
@@ -671,7 +699,22 @@ func (qre *QueryExecutor) execSQL(conn executor, sql string, wantfields bool) (*
 	defer span.Finish()
 
 	defer qre.logStats.AddRewrittenSQL(sql, time.Now())
-	return conn.Exec(ctx, sql, int(qre.tsv.qe.maxResultSize.Get()), wantfields)
+	result, err := conn.Exec(ctx, sql, int(qre.tsv.qe.maxResultSize.Get()), wantfields)
+	qre.logStats.AddMysqlResult(result)
+	if err == nil && *tabletenv.LogMysqlWarnings {
+		// Failing to fetch the warnings doesn't fail the query.
+		// A nil result without error means that there was nothing
+		// to fetch.
+		warnings, werr := conn.Warnings(ctx)
+		if werr != nil {
+			log.Warningf("Could not fetch the mysql warnings: %v", werr)
+		}
+		if warnings != nil || werr != nil {
+			qre.logStats.AddMysqlRoundTrip()
+		}
+		qre.logStats.AddWarnings(warnings)
+	}
+	return result, err
 }
 
 func (qre *QueryExecutor) execStreamSQL(conn *connpool.DBConn, sql string, callback func(*sqltypes.Result) error) error {
@@ -679,6 +722,11 @@ func (qre *QueryExecutor) execStreamSQL(conn *connpool.DBConn, sql string, callb
 	trace.AnnotateSQL(span, sql)
 	callBackClosingSpan := func(result *sqltypes.Result) error {
 		defer span.Finish()
+		qre.logStats.AddMysqlResult(result)
+		if result != nil && len(result.Fields) != 0 {
+			// Only the first result of a stream has fields.
+			qre.logStats.ColumnCount = len(result.Fields)
+		}
 		return callback(result)
 	}
 
@@ -47,6 +47,7 @@ import (
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	tableaclpb "vitess.io/vitess/go/vt/proto/tableacl"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
@@ -283,6 +284,244 @@ func TestQueryExecutorPlans(t *testing.T) {
 	}
 }
 
+func TestQueryExecutorReservedConn(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	fields := sqltypes.MakeTestFields("a|b", "int64|varchar")
+	db.AddQuery("select * from t where 1 != 1", sqltypes.MakeTestResult(fields))
+	db.AddQuery("select * from t limit 10001", sqltypes.MakeTestResult(fields, "1|aaa"))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	// A transaction doesn't reserve its connection.
+	target := tsv.sm.Target()
+	txid, _, err := tsv.Begin(ctx, &target, nil)
+	require.NoError(t, err)
+	qre := newTestQueryExecutor(ctx, tsv, "select * from t", txid)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.False(t, qre.logStats.ReservedConn)
+	_, err = tsv.Rollback(ctx, &target, txid)
+	require.NoError(t, err)
+
+	reservedID, err := tsv.te.Reserve(ctx, nil, 0, nil)
+	require.NoError(t, err)
+	defer tsv.Release(ctx, &target, 0, reservedID)
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", reservedID)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.True(t, qre.logStats.ReservedConn)
+
+	// Neither does autocommit.
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.False(t, qre.logStats.ReservedConn)
+}
+
+func TestQueryExecutorTransactionMode(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	fields := sqltypes.MakeTestFields("a|b", "int64|varchar")
+	db.AddQuery("select * from t where 1 != 1", sqltypes.MakeTestResult(fields))
+	db.AddQuery("select * from t limit 10001", sqltypes.MakeTestResult(fields, "1|aaa"))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	target := tsv.sm.Target()
+	txid, _, err := tsv.Begin(ctx, &target, nil)
+	require.NoError(t, err)
+	qre := newTestQueryExecutor(ctx, tsv, "select * from t", txid)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, vtgatepb.TransactionMode_UNSPECIFIED, qre.logStats.TransactionMode)
+	_, err = tsv.Rollback(ctx, &target, txid)
+	require.NoError(t, err)
+
+	// A reserved connection isn't necessarily in a transaction.
+	reservedID, err := tsv.te.Reserve(ctx, nil, 0, nil)
+	require.NoError(t, err)
+	defer tsv.Release(ctx, &target, 0, reservedID)
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", reservedID)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, vtgatepb.TransactionMode_SINGLE, qre.logStats.TransactionMode)
+
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, vtgatepb.TransactionMode_SINGLE, qre.logStats.TransactionMode)
+}
+
+func TestQueryExecutorIsolationLevel(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	fields := sqltypes.MakeTestFields("a|b", "int64|varchar")
+	db.AddQuery("select * from t where 1 != 1", sqltypes.MakeTestResult(fields))
+	db.AddQuery("select * from t limit 10001", sqltypes.MakeTestResult(fields, "1|aaa"))
+	db.AddQuery("set transaction isolation level READ COMMITTED", &sqltypes.Result{})
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	target := tsv.sm.Target()
+	options := &querypb.ExecuteOptions{TransactionIsolation: querypb.ExecuteOptions_READ_COMMITTED}
+	txid, _, err := tsv.Begin(ctx, &target, options)
+	require.NoError(t, err)
+	defer tsv.Rollback(ctx, &target, txid)
+	qre := newTestQueryExecutor(ctx, tsv, "select * from t", txid)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, "READ COMMITTED", qre.logStats.IsolationLevel)
+
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, "", qre.logStats.IsolationLevel)
+}
+
+func TestQueryExecutorWarnings(t *testing.T) {
+	defer func(saved bool) { *tabletenv.LogMysqlWarnings = saved }(*tabletenv.LogMysqlWarnings)
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	fields := sqltypes.MakeTestFields("a|b", "int64|varchar")
+	db.AddQuery("select * from t where 1 != 1", sqltypes.MakeTestResult(fields))
+	db.AddQuery("select * from t limit 10001", sqltypes.MakeTestResult(fields, "1|aaa"))
+	db.AddQuery("show warnings", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Level|Code|Message", "varchar|int64|varchar"),
+		"Warning|1292|Truncated incorrect DOUBLE value: 'aaa'",
+	))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+	db.SetWarningCount(1)
+	defer db.SetWarningCount(0)
+
+	// The warnings are only fetched if requested.
+	*tabletenv.LogMysqlWarnings = false
+	qre := newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err := qre.Execute()
+	require.NoError(t, err)
+	assert.Empty(t, qre.logStats.Warnings)
+	assert.Equal(t, 0, db.GetQueryCalledNum("show warnings"))
+
+	*tabletenv.LogMysqlWarnings = true
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Warning 1292: Truncated incorrect DOUBLE value: 'aaa'"}, qre.logStats.Warnings)
+}
+
+func TestQueryExecutorMysqlRoundTrips(t *testing.T) {
+	defer func(saved bool) { *tabletenv.LogMysqlWarnings = saved }(*tabletenv.LogMysqlWarnings)
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	fields := sqltypes.MakeTestFields("a|b", "int64|varchar")
+	db.AddQuery("select * from t where 1 != 1", sqltypes.MakeTestResult(fields))
+	db.AddQuery("select * from t limit 10001", sqltypes.MakeTestResult(fields, "1|aaa"))
+	db.AddQuery("show warnings", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Level|Code|Message", "varchar|int64|varchar"),
+		"Warning|1292|Truncated incorrect DOUBLE value: 'aaa'",
+	))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	*tabletenv.LogMysqlWarnings = true
+	// Each statement is a round trip. There's an extra one for
+	// the field query when the plan is built.
+	qre := newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err := qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, 2, qre.logStats.NumberOfQueries)
+	assert.Equal(t, 2, qre.logStats.MysqlRoundTrips)
+
+	// Fetching the warnings takes another round trip, but
+	// it's not counted as a query.
+	db.SetWarningCount(1)
+	defer db.SetWarningCount(0)
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, 1, qre.logStats.NumberOfQueries)
+	assert.Equal(t, 2, qre.logStats.MysqlRoundTrips)
+}
+
+func TestQueryExecutorColumnCount(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	fields := sqltypes.MakeTestFields("a|b|c|d", "int64|varchar|int64|varchar")
+	db.AddQuery("select * from t where 1 != 1", sqltypes.MakeTestResult(fields))
+	db.AddQuery("select * from t limit 10001", sqltypes.MakeTestResult(fields, "1|aaa|2|bbb"))
+	db.AddQuery("select * from t", sqltypes.MakeTestResult(fields, "1|aaa|2|bbb"))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	qre := newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err := qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, 4, qre.logStats.ColumnCount)
+
+	// The fields of a stream come with its first result.
+	plan, err := tsv.qe.GetStreamPlan("select * from t")
+	require.NoError(t, err)
+	qre = newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	qre.plan = plan
+	err = qre.Stream(func(*sqltypes.Result) error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, 4, qre.logStats.ColumnCount)
+}
+
+func TestQueryExecutorSavepointDepth(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	fields := sqltypes.MakeTestFields("a|b", "int64|varchar")
+	db.AddQuery("select * from t where 1 != 1", sqltypes.MakeTestResult(fields))
+	db.AddQuery("select * from t limit 10001", sqltypes.MakeTestResult(fields, "1|aaa"))
+	for _, query := range []string{"savepoint a", "savepoint b", "rollback to a", "release savepoint a"} {
+		db.AddQuery(query, &sqltypes.Result{})
+	}
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	target := tsv.sm.Target()
+	txid, _, err := tsv.Begin(ctx, &target, nil)
+	require.NoError(t, err)
+	defer tsv.Commit(ctx, &target, txid)
+
+	// The depth is the number of savepoints active when each
+	// statement starts.
+	testcases := []struct {
+		query string
+		depth int
+	}{
+		{"select * from t", 0},
+		{"savepoint a", 0},
+		{"savepoint b", 1},
+		{"select * from t", 2},
+		{"rollback to a", 2},
+		{"select * from t", 1},
+		{"release savepoint a", 1},
+		{"select * from t", 0},
+	}
+	for _, tcase := range testcases {
+		qre := newTestQueryExecutor(ctx, tsv, tcase.query, txid)
+		_, err := qre.Execute()
+		require.NoError(t, err, tcase.query)
+		assert.Equal(t, tcase.depth, qre.logStats.SavepointDepth, tcase.query)
+	}
+
+	// Autocommit statements are not in a savepoint.
+	qre := newTestQueryExecutor(ctx, tsv, "select * from t", 0)
+	_, err = qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, 0, qre.logStats.SavepointDepth)
+}
+
 // TestQueryExecutorSelectImpossible is separate because it's a special case
 // because the "in transaction" case is a no-op.
 func TestQueryExecutorSelectImpossible(t *testing.T) {
@@ -18,12 +18,22 @@ package tabletserver
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
+	"vitess.io/vitess/go/event"
 	"vitess.io/vitess/go/history"
+	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sync2"
+	"vitess.io/vitess/go/tb"
+	"vitess.io/vitess/go/vt/concurrency"
 	"vitess.io/vitess/go/vt/log"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
@@ -45,8 +55,85 @@ const (
 	StateServing
 )
 
-// transitionRetryInterval is for tests.
-var transitionRetryInterval = 1 * time.Second
+// transitionHistorySize is the number of transitions
+// retained by TransitionHistory.
+const transitionHistorySize = 20
+
+// defaultCheckMySQLInterval is the minimum interval between two checks
+// by CheckMySQL, if not configured.
+const defaultCheckMySQLInterval = 1 * time.Second
+
+// mysqlProbeTimeout bounds the time given to a custom mysql probe.
+const mysqlProbeTimeout = 10 * time.Second
+
+// defaultTransitionRetryInterval is the fixed interval between
+// transition retries, if no retry backoff is configured.
+const defaultTransitionRetryInterval = 1 * time.Second
+
+// defaultRestorePollInterval is the interval at which the restore
+// progress source is polled for completion.
+const defaultRestorePollInterval = 1 * time.Second
+
+// Sentinel errors returned by StartRequest and VerifyTarget.
+// They can be matched with errors.Is. The returned errors retain
+// their original message and vtrpc code.
+var (
+	ErrNoTarget          = errors.New("no target")
+	ErrInvalidKeyspace   = errors.New("invalid keyspace")
+	ErrInvalidShard      = errors.New("invalid shard")
+	ErrInvalidTabletType = errors.New("invalid tablet type")
+	ErrNotServing        = errors.New("not serving")
+	ErrReplicaLagged     = errors.New("replica lagged")
+	ErrMaintenance       = errors.New("in maintenance")
+)
+
+// ErrTransitionBusy is returned by SetServingType if a transition
+// in progress didn't complete within maxTransitionWait. It can be
+// matched with errors.Is.
+var ErrTransitionBusy = errors.New("transition busy")
+
+// requestError associates a sentinel error with a vterror.
+// The vterror supplies the message and code, and the sentinel
+// is exposed through Unwrap.
+type requestError struct {
+	err      error
+	sentinel error
+}
+
+func newRequestError(sentinel, err error) error {
+	return &requestError{err: err, sentinel: sentinel}
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Cause() error  { return e.err }
+func (e *requestError) Unwrap() error { return e.sentinel }
+
+func (e *requestError) Format(s fmt.State, verb rune) {
+	if f, ok := e.err.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	_, _ = io.WriteString(s, e.err.Error())
+}
+
+// TransitionError is returned by a state transition that failed
+// because one of its subcomponents did. It can be recovered with
+// errors.As. The wrapped error retains its vtrpc code.
+type TransitionError struct {
+	// Component is the failing subcomponent, e.g. query_engine.
+	Component string
+	// Phase is the operation that failed, e.g. open.
+	Phase string
+	// Err is the error returned by the subcomponent.
+	Err error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Component, e.Phase, e.Err.Error())
+}
+
+func (e *TransitionError) Cause() error  { return e.Err }
+func (e *TransitionError) Unwrap() error { return e.Err }
 
 // stateName names every state. The number of elements must
 // match the number of states. Names can overlap.
@@ -56,6 +143,14 @@ var stateName = []string{
 	"SERVING",
 }
 
+// stateLabel is the label used for each state in metrics.
+// Unlike stateName, every state has a distinct label.
+var stateLabel = []string{
+	"NotConnected",
+	"NotServing",
+	"Serving",
+}
+
 // stateDetail matches every state and optionally more information about the reason
 // why the state is serving / not serving.
 var stateDetail = []string{
@@ -88,12 +183,180 @@ type stateManager struct {
 	state          servingState
 	target         querypb.Target
 	retrying       bool
+	// retryCount is the number of retries performed
+	// for the current wantState.
+	retryCount int
+	// retryGeneration is incremented to stop the current
+	// retryTransition loop.
+	retryGeneration int
+	// forcedReason is set by ForceNotServing. While set, the tablet
+	// is pinned to not serving, and forcedTabletType and forcedState
+	// hold the most recently requested type and state, which are
+	// restored by ClearForcedNotServing.
+	forcedReason     string
+	forcedTabletType topodatapb.TabletType
+	forcedState      servingState
+	// demoted is set by DemoteToReadOnly while a serving master
+	// only accepts read-only transactions.
+	demoted bool
+	// maintenance is set by EnterMaintenance. While set, requests are
+	// rejected with maintenanceMessage.
+	maintenance        bool
+	maintenanceMessage string
+	// autoRecover is set by SetAutoRecover. If set, a tablet shut
+	// down by CheckMySQL waits for mysql to be reachable, and then
+	// returns to the state it wanted when mysql failed.
+	autoRecover bool
+	// lastError is the error of the most recent transition, if it
+	// failed at lastErrorTime.
+	lastError     error
+	lastErrorTime time.Time
 	// TODO(sougou): deprecate alsoAllow
 	alsoAllow []topodatapb.TabletType
+	// frozen is set by Freeze. While it's set, and while Unfreeze
+	// releases them, SetServingType calls are queued in frozenQueue.
+	frozen      bool
+	unfreezing  bool
+	frozenQueue []*frozenCall
+	// mysqlProbe is an optional check that must pass, in addition
+	// to IsMySQLReachable, for mysql to be considered healthy.
+	mysqlProbe func(ctx context.Context) error
+	// If verifyReadOnly is set, readOnlyProbe is used to confirm that
+	// mysql is read-only after the tx engine accepts read-only
+	// transactions.
+	verifyReadOnly bool
+	readOnlyProbe  func(ctx context.Context) (bool, error)
+	// positionProbe returns the replication position of mysql. It's
+	// reported by PromoteToMaster.
+	positionProbe func(ctx context.Context) (mysql.Position, error)
+	// If maxReplicaLag is set, replicaLagGate is consulted by
+	// StartRequest while serving as a non-master, and requests are
+	// rejected if the lag exceeds maxReplicaLag or is unknown.
+	maxReplicaLag  time.Duration
+	replicaLagGate func() (lag time.Duration, ok bool)
+	// If degradedReplicaLag is set, a serving non-master whose lag,
+	// as reported by replicaLagGate, exceeds it or is unknown is
+	// reported as degraded by IsDegraded.
+	degradedReplicaLag time.Duration
+	// restoreProgress reports the progress of a restore while the
+	// tablet type is RESTORE. It's set by SetRestoreProgressSource.
+	restoreProgress func() RestoreProgress
+	// postRestoreType and postRestoreState are the target of the
+	// transition done once a restore completes. It's set by
+	// SetPostRestoreTarget, and disabled if postRestoreType is
+	// UNKNOWN. watchingRestore is set while a restore is watched.
+	postRestoreType  topodatapb.TabletType
+	postRestoreState servingState
+	watchingRestore  bool
+	// transitionGuard, if set, can veto a transition before it starts.
+	transitionGuard func(tabletType topodatapb.TabletType, state servingState) error
+	// lameduckDeadline is the time until which transitions
+	// are held back after entering lameduck.
+	lameduckDeadline time.Time
+	// timedState is the state and tablet type being timed since
+	// stateSince. stateDurations accumulates the time spent in
+	// previous states.
+	timedState     stateKey
+	stateSince     time.Time
+	stateDurations map[stateKey]time.Duration
+	// enteredState is the time of the last transition into the
+	// current state. Unlike stateSince, it's not affected by
+	// lameduck.
+	enteredState time.Time
+	// requestCounts counts the outcomes of StartRequest for
+	// each tablet type.
+	requestCounts map[requestKey]int64
+	// extraComponents are the subcomponents added by registerComponent.
+	extraComponents []stateComponent
+	// planned is set by PlanTransition. While set, transitions record
+	// the subcomponent actions in it instead of performing them. It's
+	// protected by the transitioning semaphore.
+	planned *[]string
+	// openNames are the subcomponents that are open, in the order
+	// in which they were opened.
+	openNames []string
 
 	requests sync.WaitGroup
+	// inFlight tracks the same count as requests, which
+	// doesn't expose it.
+	inFlight sync2.AtomicInt64
 	lameduck sync2.AtomicInt32
 
+	// If trackRequests is set, or while draining, TrackRequest
+	// records the requests in activeRequests, which is protected
+	// by activeRequestsMu. draining is set by StopServiceWithDrain
+	// and also makes StartRequest reject new requests.
+	trackRequests    bool
+	draining         sync2.AtomicBool
+	activeRequestsMu sync.Mutex
+	activeRequests   map[*RequestInfo]struct{}
+
+	// drainedRequests and drainTimedOutRequests count the requests
+	// that completed within the soft drain period of StopServiceWithDrain,
+	// and those that were still running when it expired. The latter are
+	// not cut off: the shutdown waits for them to complete.
+	drainedRequests       sync2.AtomicInt64
+	drainTimedOutRequests sync2.AtomicInt64
+	// redundantStops counts the calls to StopService that were
+	// no-ops because the service was already stopped.
+	redundantStops sync2.AtomicInt64
+
+	// mysqlProbeFailures counts the failures of mysqlProbe.
+	mysqlProbeFailures sync2.AtomicInt64
+	// mysqlLastReachable is the time, in unix nanoseconds, at which
+	// mysql was last found reachable by a health check.
+	mysqlLastReachable sync2.AtomicInt64
+	// The outcomes of CheckMySQL: checks skipped because another one
+	// was recent, checks that found mysql healthy, failed checks that
+	// didn't interfere with a transition in progress, and failed checks
+	// that shut the query service down. checkMySQLLastFailure is the
+	// time of the last failed check, in unix nanoseconds.
+	checkMySQLThrottled   sync2.AtomicInt64
+	checkMySQLHealthy     sync2.AtomicInt64
+	checkMySQLSkipped     sync2.AtomicInt64
+	checkMySQLShutdowns   sync2.AtomicInt64
+	checkMySQLLastFailure sync2.AtomicInt64
+	// checkMySQLBackoff is the minimum interval, and its jitter,
+	// between two checks by CheckMySQL.
+	checkMySQLBackoff backoffPolicy
+
+	// transitionSLO is the duration beyond which a transition is
+	// counted in slowTransitions. Zero disables the check.
+	transitionSLO   time.Duration
+	slowTransitions sync2.AtomicInt64
+	// transitionCount and failedTransitions count the transitions
+	// recorded by recordTransition that succeeded and failed.
+	transitionCount   sync2.AtomicInt64
+	failedTransitions sync2.AtomicInt64
+	// maxTransitionWait bounds how long mustTransition waits for
+	// a transition in progress. Zero means no bound.
+	maxTransitionWait time.Duration
+	// If transitionLogInterval is set, setState logs identical
+	// transitions at most once per interval. transitionLogs tracks
+	// when each of them was last logged, and how many were suppressed
+	// since. It's protected by mu.
+	transitionLogInterval time.Duration
+	transitionLogs        map[transitionLogKey]*transitionLogEntry
+	// If logTransitionsJSON is set, recordTransition logs every
+	// TransitionRecord as a line of JSON.
+	logTransitionsJSON bool
+	// slowestComponent and slowestComponentTime track the slowest
+	// subcomponent step of the current transition. They're protected
+	// by the transitioning semaphore.
+	slowestComponent     string
+	slowestComponentTime time.Duration
+	// transitionReason is the reason for the transition in progress,
+	// if any. It's also protected by the transitioning semaphore.
+	transitionReason string
+	// transitionWait is how long the transition in progress waited
+	// for the transitioning semaphore. It's also protected by it.
+	transitionWait time.Duration
+	// prevAlsoAllow is the alsoAllow list that was in effect before
+	// the transition in progress, so that it can be restored if the
+	// transition is rolled back. It's also protected by the
+	// transitioning semaphore.
+	prevAlsoAllow []topodatapb.TabletType
+
 	// Open must be done in forward order.
 	// Close must be done in reverse order.
 	// All Close functions must be called before Open.
@@ -112,7 +375,140 @@ type stateManager struct {
 	// doesn't get spammed.
 	checkMySQLThrottler *sync2.Semaphore
 	history             *history.History
+	transitions         *history.History
 	timebombDuration    time.Duration
+	// timebombHandler is set by SetTimebombHandler. It's protected by mu.
+	timebombHandler func(inflight int64)
+	// crash terminates the process when the timebomb fires.
+	// It can be overridden by tests.
+	crash func(args ...interface{})
+
+	// lameduckPeriod is the default time to remain in lameduck
+	// before a transition is allowed to proceed. lameduckByType
+	// overrides it for specific tablet types.
+	lameduckPeriod time.Duration
+	lameduckByType map[topodatapb.TabletType]time.Duration
+
+	// retryBackoff determines the delay between transition retries.
+	// If it's not configured, transitionRetryInterval is used instead.
+	retryBackoff            backoffPolicy
+	transitionRetryInterval time.Duration
+	// restorePollInterval is the interval at which the restore
+	// progress is polled when a post-restore target is set.
+	restorePollInterval time.Duration
+
+	// now returns the current time. It can be overridden by tests.
+	now func() time.Time
+
+	// componentTimings records the duration of subcomponent
+	// operations like schema_engine_open. It can be nil.
+	componentTimings durationRecorder
+	// waitTimings records how long SetServingType waited for the
+	// transitioning semaphore, as StateTransition. It can be nil.
+	waitTimings durationRecorder
+	// metricsSink observes every transition. It's protected by
+	// listenersMu, and it can be nil.
+	metricsSink TransitionMetricsSink
+
+	// listenersMu protects listeners. It's separate from mu
+	// because listeners are invoked without holding any locks.
+	listenersMu sync.Mutex
+	listeners   []*stateListener
+	// lameduckListeners are registered by OnLameduck. They're
+	// also protected by listenersMu.
+	lameduckListeners []func(entering bool)
+	// rejectionListeners are registered by OnRequestRejected.
+	// They're also protected by listenersMu.
+	rejectionListeners []func(target *querypb.Target, reason error)
+}
+
+// TransitionRecord describes a single transition attempt.
+// It's a value type that can be freely copied.
+type TransitionRecord struct {
+	Time       time.Time     `json:"time"`
+	From       string        `json:"from"`
+	To         string        `json:"to"`
+	TabletType string        `json:"tabletType"`
+	Duration   time.Duration `json:"duration"`
+	Retries    int           `json:"retries"`
+	Error      string        `json:"error,omitempty"`
+	// Reason is the reason given to SetServingTypeWithReason or
+	// ForceNotServing, if any.
+	Reason string `json:"reason,omitempty"`
+	// Wait is how long SetServingType waited for another transition
+	// to complete before starting this one. It's not part of Duration.
+	Wait time.Duration `json:"wait,omitempty"`
+}
+
+// backoffPolicy computes the delay between successive retries.
+// A zero initial value results in no delay: the caller is expected to
+// substitute its own default.
+type backoffPolicy struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	// jitter is the fraction of the delay by which it's
+	// randomly increased or decreased.
+	jitter float64
+}
+
+// newBackoffPolicy builds a backoffPolicy from the tabletenv config.
+func newBackoffPolicy(config tabletenv.TransitionRetryConfig) backoffPolicy {
+	return backoffPolicy{
+		initial:    time.Duration(config.InitialSeconds * 1e9),
+		max:        time.Duration(config.MaxSeconds * 1e9),
+		multiplier: config.Multiplier,
+		jitter:     config.Jitter,
+	}
+}
+
+// interval returns the delay before the specified retry attempt,
+// starting at 0. randFloat must return values in [0, 1).
+func (bp backoffPolicy) interval(attempt int, randFloat func() float64) time.Duration {
+	if bp.initial == 0 {
+		return 0
+	}
+	d := float64(bp.initial)
+	if bp.multiplier > 1 {
+		d *= math.Pow(bp.multiplier, float64(attempt))
+	}
+	if bp.max > 0 && d > float64(bp.max) {
+		d = float64(bp.max)
+	}
+	if bp.jitter > 0 {
+		d += d * bp.jitter * (2*randFloat() - 1)
+	}
+	return time.Duration(d)
+}
+
+// stateKey identifies a state for a tablet type.
+type stateKey struct {
+	state      servingState
+	tabletType topodatapb.TabletType
+}
+
+// requestKey identifies a StartRequest outcome for a tablet type.
+type requestKey struct {
+	tabletType topodatapb.TabletType
+	outcome    string
+}
+
+// requestOutcomes maps the sentinel errors of StartRequest to the
+// outcome label of their requestCounts.
+var requestOutcomes = map[error]string{
+	ErrNoTarget:          "NoTarget",
+	ErrInvalidKeyspace:   "InvalidKeyspace",
+	ErrInvalidShard:      "InvalidShard",
+	ErrInvalidTabletType: "InvalidTabletType",
+	ErrNotServing:        "NotServing",
+	ErrReplicaLagged:     "ReplicaLagged",
+	ErrMaintenance:       "Maintenance",
+}
+
+// stateListener wraps a state change callback. A pointer to it
+// is used as the identity for unsubscribing.
+type stateListener struct {
+	fn func(from, to servingState, tabletType topodatapb.TabletType)
 }
 
 type schemaEngine interface {
@@ -139,11 +535,126 @@ type subComponent interface {
 	Close()
 }
 
+// TransitionMetricsSink receives the outcome of every state transition,
+// including the retries, so that it can be exported to a metrics
+// system. d is how long the transition took, and err is nil if it
+// succeeded. ObserveTransition is called without holding any locks,
+// and it must not block.
+type TransitionMetricsSink interface {
+	ObserveTransition(from, to servingState, d time.Duration, err error)
+}
+
+// statsTransitionSink is the default TransitionMetricsSink. It records
+// the transitions in the tabletenv stats, labeled as FromToTo, e.g.
+// NotServingToServing.
+type statsTransitionSink struct {
+	stats *tabletenv.Stats
+}
+
+func (s *statsTransitionSink) ObserveTransition(from, to servingState, d time.Duration, err error) {
+	label := stateLabel[from] + "To" + stateLabel[to]
+	s.stats.TransitionTimings.Add(label, d)
+	if err != nil {
+		s.stats.TransitionErrors.Add(label, 1)
+	}
+}
+
+// durationRecorder records durations by name.
+// It's satisfied by servenv.TimingsWrapper.
+type durationRecorder interface {
+	Add(name string, elapsed time.Duration)
+}
+
 type txThrottler interface {
 	Open() error
 	Close()
 }
 
+// stateComponent declares where a subcomponent is opened by connect
+// and closed by closeAll. Components are opened in increasing
+// openOrder and closed in increasing closeOrder. The default orders
+// are spaced out so that new components can be inserted between
+// them. A component with no open is only closed.
+type stateComponent struct {
+	name       string
+	openOrder  int
+	closeOrder int
+	open       func() error
+	close      func()
+}
+
+// defaultComponents returns the built-in subcomponents. The methods
+// are resolved at call time, so that the fields can be replaced.
+func (sm *stateManager) defaultComponents() []stateComponent {
+	return []stateComponent{{
+		name:       "schema_engine",
+		openOrder:  10,
+		closeOrder: 80,
+		open:       func() error { return sm.se.Open() },
+		close:      func() { sm.se.Close() },
+	}, {
+		name:       "vstreamer",
+		openOrder:  20,
+		closeOrder: 50,
+		open:       func() error { sm.vstreamer.Open(); return nil },
+		close:      func() { sm.vstreamer.Close() },
+	}, {
+		name:       "query_engine",
+		openOrder:  30,
+		closeOrder: 20,
+		open:       func() error { return sm.qe.Open() },
+		close:      func() { sm.qe.Close() },
+	}, {
+		name:       "tx_throttler",
+		openOrder:  40,
+		closeOrder: 10,
+		open:       func() error { return sm.txThrottler.Open() },
+		close:      func() { sm.txThrottler.Close() },
+	}, {
+		name:       "replication_watcher",
+		closeOrder: 30,
+		close:      func() { sm.watcher.Close() },
+	}, {
+		name:       "tracker",
+		closeOrder: 40,
+		close:      func() { sm.tracker.Close() },
+	}, {
+		name:       "heartbeat_reader",
+		closeOrder: 60,
+		close:      func() { sm.hr.Close() },
+	}, {
+		name:       "heartbeat_writer",
+		closeOrder: 70,
+		close:      func() { sm.hw.Close() },
+	}}
+}
+
+// registerComponent adds a subcomponent to the ones opened by connect
+// and closed by closeAll. A component that has the same order as another
+// one runs after it. It must be called before the first transition.
+func (sm *stateManager) registerComponent(c stateComponent) {
+	sm.extraComponents = append(sm.extraComponents, c)
+}
+
+// openComponents returns the components opened by connect, in order.
+func (sm *stateManager) openComponents() []stateComponent {
+	var components []stateComponent
+	for _, c := range append(sm.defaultComponents(), sm.extraComponents...) {
+		if c.open != nil {
+			components = append(components, c)
+		}
+	}
+	sort.SliceStable(components, func(i, j int) bool { return components[i].openOrder < components[j].openOrder })
+	return components
+}
+
+// closeComponents returns the components closed by closeAll, in order.
+func (sm *stateManager) closeComponents() []stateComponent {
+	components := append(sm.defaultComponents(), sm.extraComponents...)
+	sort.SliceStable(components, func(i, j int) bool { return components[i].closeOrder < components[j].closeOrder })
+	return components
+}
+
 // SetServingType changes the state to the specified settings.
 // If a transition is in progress, it waits and then executes the
 // new request. If the transition fails, it returns an error, and
@@ -152,63 +663,429 @@ type txThrottler interface {
 // If sm is already in the requested state, it returns stateChanged as
 // false.
 func (sm *stateManager) SetServingType(tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType) (stateChanged bool, err error) {
+	return sm.SetServingTypeContext(context.Background(), tabletType, state, alsoAllow)
+}
+
+// SetServingTypeContext is like SetServingType, but it gives up if ctx
+// is done before the transition completes. The context is checked while
+// waiting for a transition in progress, and between subcomponent steps.
+// If a transition is interrupted, sm is rolled back to the previous state,
+// and ctx.Err() is returned.
+func (sm *stateManager) SetServingTypeContext(ctx context.Context, tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType) (stateChanged bool, err error) {
+	return sm.SetServingTypeWithReason(ctx, tabletType, state, alsoAllow, "")
+}
+
+// SetServingTypeWithReason is like SetServingTypeContext, but it also
+// records why the change was requested. The reason appears in the
+// transition history, in the queryservice history of the status page,
+// and in the ServingStateChanged event dispatched on success.
+func (sm *stateManager) SetServingTypeWithReason(ctx context.Context, tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType, reason string) (stateChanged bool, err error) {
+	return sm.setServingType(ctx, tabletType, state, alsoAllow, reason, nil, false)
+}
+
+// TrySetServingType is like SetServingType, but it never blocks waiting
+// for another transition. If one is already underway, it returns
+// immediately with busy set to true and the request is dropped. The
+// same applies while transitions are frozen or held back by a lameduck
+// period. Controllers that retry periodically can use it to avoid piling
+// up goroutines on the transitioning semaphore.
+func (sm *stateManager) TrySetServingType(tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType) (stateChanged, busy bool, err error) {
+	stateChanged, err = sm.setServingType(context.Background(), tabletType, state, alsoAllow, "", nil, true)
+	if err == errTransitionInProgress {
+		return false, true, nil
+	}
+	return stateChanged, false, err
+}
+
+// CompareAndSetServingType is like SetServingType, but it only honors
+// the request if the current state is expected, so that controllers
+// issuing transitions concurrently don't overwrite each other's
+// requests. It returns false without an error if the current state
+// didn't match, and true otherwise, even if no transition was needed.
+// The tablet types allowed by the previous request remain allowed.
+func (sm *stateManager) CompareAndSetServingType(expected servingState, tabletType topodatapb.TabletType, state servingState) (bool, error) {
+	_, err := sm.setServingType(context.Background(), tabletType, state, nil, "", &expected, false)
+	if err == errStateMismatch {
+		return false, nil
+	}
+	return true, err
+}
+
+// errStateMismatch is returned by mustTransition if the current state
+// isn't the expected one.
+var errStateMismatch = errors.New("current state doesn't match the expected one")
+
+// errTransitionInProgress is returned by setServingType in try mode
+// if it would have to wait for another transition.
+var errTransitionInProgress = errors.New("a state transition is in progress")
+
+// setServingType implements SetServingTypeWithReason. If expected is
+// not nil, the request is only honored if the current state matches
+// it, and alsoAllow is ignored in favor of the current types. If try
+// is set, it returns errTransitionInProgress instead of waiting.
+func (sm *stateManager) setServingType(ctx context.Context, tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType, reason string, expected *servingState, try bool) (stateChanged bool, err error) {
 	defer sm.ExitLameduck()
 
+	if try && sm.mustWait() {
+		return false, errTransitionInProgress
+	}
+	done, err := sm.waitUnfrozen(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer done()
+
+	state = allowedState(tabletType, state)
+
+	if err := sm.waitLameduck(ctx); err != nil {
+		return false, err
+	}
+
+	state = sm.pinnedState(tabletType, state)
+	log.Infof("Starting transition to %v %v", tabletType, stateName[state])
+	mustTransition, err := sm.mustTransition(ctx, tabletType, state, alsoAllow, expected, try)
+	if err != nil || !mustTransition {
+		return false, err
+	}
+	// The semaphore is held, so the state can't change under us.
+	from := sm.State()
+	sm.transitionReason = reason
+	if err := sm.execTransition(ctx, tabletType, state); err != nil {
+		return true, err
+	}
+	sm.notifyStateChange(from, state, tabletType)
+	event.Dispatch(&ServingStateChanged{
+		From:       stateName[from],
+		To:         stateName[state],
+		TabletType: tabletType,
+		Reason:     reason,
+	})
 	if tabletType == topodatapb.TabletType_RESTORE {
+		sm.watchRestore()
+	}
+	return true, nil
+}
+
+// allowedState returns the state that a tablet of the specified type
+// can be in, given the requested state. RESTORE tablets can only be
+// in StateNotConnected. SPARE and DRAINED tablets don't serve queries,
+// but they keep the schema engine open, so they're in StateNotServing
+// when serving is requested.
+func allowedState(tabletType topodatapb.TabletType, state servingState) servingState {
+	switch tabletType {
+	case topodatapb.TabletType_RESTORE:
 		// TODO(sougou): remove this code once tm can give us more accurate state requests.
-		state = StateNotConnected
+		return StateNotConnected
+	case topodatapb.TabletType_SPARE, topodatapb.TabletType_DRAINED:
+		if state == StateServing {
+			return StateNotServing
+		}
 	}
+	return state
+}
 
-	log.Infof("Starting transition to %v %v", tabletType, stateName[state])
-	if sm.mustTransition(tabletType, state, alsoAllow) {
-		return true, sm.execTransition(tabletType, state)
+// frozenCall is a SetServingType call queued by Freeze. Unfreeze
+// closes start to let it proceed, and waits for done to be closed
+// once it has completed.
+type frozenCall struct {
+	start, done chan struct{}
+}
+
+// Freeze makes subsequent SetServingType calls block until Unfreeze
+// is called, and stops the retry of a failed transition, the
+// auto-recover loop and CheckMySQL from making progress. This
+// guarantees that the state doesn't change during a coordinated
+// maintenance operation. It waits for a transition in progress to
+// complete.
+func (sm *stateManager) Freeze() {
+	sm.transitioning.Acquire()
+	defer sm.transitioning.Release()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	log.Infof("Freezing state transitions")
+	sm.frozen = true
+}
+
+// Unfreeze undoes Freeze. The SetServingType calls that were blocked
+// are executed one at a time, in the order in which they were made,
+// and Unfreeze returns once they've completed. If Freeze is called
+// again meanwhile, the calls not yet executed remain blocked.
+func (sm *stateManager) Unfreeze() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !sm.frozen || sm.unfreezing {
+		sm.frozen = false
+		return
+	}
+	log.Infof("Unfreezing state transitions, %d calls queued", len(sm.frozenQueue))
+	sm.frozen = false
+	sm.unfreezing = true
+	for !sm.frozen && len(sm.frozenQueue) > 0 {
+		call := sm.frozenQueue[0]
+		sm.frozenQueue = sm.frozenQueue[1:]
+		sm.mu.Unlock()
+		close(call.start)
+		<-call.done
+		sm.mu.Lock()
+	}
+	sm.unfreezing = false
+}
+
+// waitUnfrozen blocks while transitions are frozen. It returns a
+// function that must be called once the caller has completed. If ctx
+// is done while waiting, it returns ctx.Err().
+func (sm *stateManager) waitUnfrozen(ctx context.Context) (done func(), err error) {
+	sm.mu.Lock()
+	if !sm.frozen && !sm.unfreezing {
+		sm.mu.Unlock()
+		return func() {}, nil
+	}
+	call := &frozenCall{start: make(chan struct{}), done: make(chan struct{})}
+	sm.frozenQueue = append(sm.frozenQueue, call)
+	sm.mu.Unlock()
+
+	select {
+	case <-call.start:
+		return func() { close(call.done) }, nil
+	case <-ctx.Done():
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for i, queued := range sm.frozenQueue {
+		if queued == call {
+			sm.frozenQueue = append(sm.frozenQueue[:i], sm.frozenQueue[i+1:]...)
+			return nil, ctx.Err()
+		}
 	}
-	return false, nil
+	// Unfreeze has already released the call.
+	close(call.done)
+	return nil, ctx.Err()
+}
+
+// ServingStateChanged is dispatched when SetServingType completes
+// a transition.
+type ServingStateChanged struct {
+	From, To   string
+	TabletType topodatapb.TabletType
+	// Reason is the reason given to SetServingTypeWithReason, if any.
+	Reason string
+}
+
+// UpdateAllowedTypes replaces the alsoAllow list without a transition.
+// It takes effect for subsequent StartRequest and VerifyTarget calls,
+// even if a transition is in progress.
+func (sm *stateManager) UpdateAllowedTypes(alsoAllow []topodatapb.TabletType) {
+	allowed := make([]topodatapb.TabletType, len(alsoAllow))
+	copy(allowed, alsoAllow)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	log.Infof("Updating allowed tablet types: %v -> %v", sm.alsoAllow, allowed)
+	sm.alsoAllow = allowed
 }
 
 // mustTransition returns true if the requested state does not match the current
 // state. If so, it acquires the semaphore and returns true. If a transition is
 // already in progress, it waits. If the desired state is already reached, it
-// returns false without acquiring the semaphore.
-func (sm *stateManager) mustTransition(tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType) bool {
-	sm.transitioning.Acquire()
+// returns false without acquiring the semaphore. If ctx is done while waiting,
+// it returns ctx.Err(). If maxTransitionWait elapses first, it returns an
+// ErrTransitionBusy error. If try is set, it doesn't wait, and returns
+// errTransitionInProgress instead. If the transition guard vetoes the
+// transition, it returns the guard's error without acquiring the semaphore.
+func (sm *stateManager) mustTransition(ctx context.Context, tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType, expected *servingState, try bool) (bool, error) {
+	start := sm.now()
+	if try {
+		if !sm.transitioning.TryAcquire() {
+			return false, errTransitionInProgress
+		}
+	} else if err := sm.acquireTransition(ctx); err != nil {
+		return false, err
+	}
+	wait := sm.now().Sub(start)
+	if sm.waitTimings != nil {
+		sm.waitTimings.Add("StateTransition", wait)
+	}
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	if expected != nil {
+		if sm.state != *expected {
+			log.Infof("Transition to %v %v skipped: state is %s, expected %s", tabletType, stateName[state], stateInfo(sm.state), stateInfo(*expected))
+			sm.transitioning.Release()
+			return false, errStateMismatch
+		}
+		alsoAllow = sm.alsoAllow
+	}
+
+	mustTransition := sm.needsTransitionLocked(tabletType, state)
+	if mustTransition && sm.transitionGuard != nil {
+		if err := sm.transitionGuard(tabletType, state); err != nil {
+			log.Infof("Transition to %v %v vetoed: %v", tabletType, stateName[state], err)
+			sm.transitioning.Release()
+			return false, err
+		}
+	}
+	prevAlsoAllow := sm.alsoAllow
 	sm.wantTabletType = tabletType
 	sm.wantState = state
 	sm.alsoAllow = alsoAllow
-	if sm.target.TabletType == tabletType && sm.state == state {
+	sm.retryCount = 0
+	if !mustTransition {
 		sm.transitioning.Release()
-		return false
+		return false, nil
 	}
-	return true
+	sm.transitionWait = wait
+	sm.prevAlsoAllow = prevAlsoAllow
+	return true, nil
+}
+
+// acquireTransition acquires the transitioning semaphore, waiting
+// at most maxTransitionWait if it's set.
+func (sm *stateManager) acquireTransition(ctx context.Context) error {
+	if sm.maxTransitionWait == 0 {
+		if !sm.transitioning.AcquireContext(ctx) {
+			return ctx.Err()
+		}
+		return nil
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, sm.maxTransitionWait)
+	defer cancel()
+	if sm.transitioning.AcquireContext(waitCtx) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return newRequestError(ErrTransitionBusy, vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "another state transition is still in progress after %v", sm.maxTransitionWait))
+}
+
+// mustWait returns true if a transition requested now would have to
+// wait for transitions to be unfrozen or for a lameduck period to expire.
+func (sm *stateManager) mustWait() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.frozen || sm.unfreezing {
+		return true
+	}
+	return sm.lameduck.Get() != 0 && sm.lameduckDeadline.After(sm.now())
+}
+
+// needsTransitionLocked returns true if the current state does not
+// match the requested one. sm.mu must be held.
+func (sm *stateManager) needsTransitionLocked(tabletType topodatapb.TabletType, state servingState) bool {
+	return sm.target.TabletType != tabletType || sm.state != state || sm.demoted
+}
+
+// PlanTransition returns the subcomponent actions that SetServingType
+// would perform to transition to tabletType and state, in order, without
+// performing them. Actions are named component_phase, e.g. query_engine_open,
+// like they are in ComponentTimings. The plan assumes that every step
+// succeeds, and it's empty if no transition is needed. It waits for a
+// transition in progress to complete.
+func (sm *stateManager) PlanTransition(tabletType topodatapb.TabletType, state servingState) []string {
+	state = allowedState(tabletType, state)
+
+	sm.transitioning.Acquire()
+	defer sm.transitioning.Release()
+
+	sm.mu.Lock()
+	if sm.forcedReason != "" && state == StateServing {
+		state = StateNotServing
+	}
+	mustTransition := sm.needsTransitionLocked(tabletType, state)
+	sm.mu.Unlock()
+	if !mustTransition {
+		return nil
+	}
+
+	// planned is protected by the transitioning semaphore.
+	planned := []string{}
+	sm.planned = &planned
+	defer func() { sm.planned = nil }()
+	sm.transitionTo(context.Background(), tabletType, state)
+	return planned
+}
+
+// SetTransitionGuard installs a guard that SetServingType consults
+// before starting a transition. If the guard returns an error, the
+// transition is aborted with that error, and the desired state is
+// left unchanged. The guard is called with sm.mu held, and must not
+// call back into sm. A nil guard removes the current one.
+func (sm *stateManager) SetTransitionGuard(guard func(tabletType topodatapb.TabletType, state servingState) error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.transitionGuard = guard
 }
 
-func (sm *stateManager) execTransition(tabletType topodatapb.TabletType, state servingState) error {
+func (sm *stateManager) execTransition(ctx context.Context, tabletType topodatapb.TabletType, state servingState) error {
 	defer sm.transitioning.Release()
+	reason, wait, prevAlsoAllow := sm.transitionReason, sm.transitionWait, sm.prevAlsoAllow
+	defer func() { sm.transitionReason, sm.transitionWait, sm.prevAlsoAllow = "", 0, nil }()
+
+	sm.mu.Lock()
+	from, fromTabletType, retries := sm.state, sm.target.TabletType, sm.retryCount
+	sm.mu.Unlock()
+	start := sm.now()
+
+	sm.slowestComponent, sm.slowestComponentTime = "", 0
+	err := sm.transitionTo(ctx, tabletType, state)
+	elapsed := sm.now().Sub(start)
+	sm.recordTransition(TransitionRecord{
+		Time:       start,
+		From:       stateLabel[from],
+		To:         stateLabel[state],
+		TabletType: tabletType.String(),
+		Duration:   elapsed,
+		Retries:    retries,
+		Reason:     reason,
+		Wait:       wait,
+	}, err)
+	sm.checkTransitionSLO(tabletType, state, elapsed)
+	sm.observeTransition(from, state, elapsed, err)
+	if err != nil && err == ctx.Err() {
+		log.Infof("Transition to %v %v interrupted: %v, rolling back to %v %v", tabletType, stateName[state], err, fromTabletType, stateName[from])
+		sm.rollbackTransition(fromTabletType, from, prevAlsoAllow)
+		return err
+	}
+	if err != nil {
+		sm.retryTransition(fmt.Sprintf("Error transitioning to the desired state: %v, %v, will keep retrying: %v", tabletType, stateName[state], err))
+	}
+	return err
+}
 
-	var err error
+// transitionTo performs the steps needed to reach the requested state.
+// The transitioning semaphore must be held.
+func (sm *stateManager) transitionTo(ctx context.Context, tabletType topodatapb.TabletType, state servingState) error {
 	switch state {
 	case StateServing:
 		if tabletType == topodatapb.TabletType_MASTER {
-			err = sm.serveMaster()
-		} else {
-			err = sm.serveNonMaster(tabletType)
+			return sm.serveMaster(ctx)
 		}
+		return sm.serveNonMaster(ctx, tabletType)
 	case StateNotServing:
 		if tabletType == topodatapb.TabletType_MASTER {
-			err = sm.unserveMaster()
-		} else {
-			err = sm.unserveNonMaster(tabletType)
+			return sm.unserveMaster(ctx)
 		}
+		return sm.unserveNonMaster(ctx, tabletType)
 	case StateNotConnected:
-		sm.closeAll()
+		return sm.closeAll()
 	}
-	if err != nil {
-		sm.retryTransition(fmt.Sprintf("Error transitioning to the desired state: %v, %v, will keep retrying: %v", tabletType, stateName[state], err))
+	return nil
+}
+
+// rollbackTransition restores the state that was in effect before an
+// interrupted transition. The transitioning semaphore must be held.
+func (sm *stateManager) rollbackTransition(tabletType topodatapb.TabletType, state servingState, alsoAllow []topodatapb.TabletType) {
+	sm.mu.Lock()
+	sm.wantTabletType = tabletType
+	sm.wantState = state
+	sm.alsoAllow = alsoAllow
+	sm.mu.Unlock()
+
+	if err := sm.transitionTo(context.Background(), tabletType, state); err != nil {
+		sm.retryTransition(fmt.Sprintf("Error rolling back to the previous state: %v, %v, will keep retrying: %v", tabletType, stateName[state], err))
 	}
-	return err
 }
 
 func (sm *stateManager) retryTransition(message string) {
@@ -218,114 +1095,1093 @@ func (sm *stateManager) retryTransition(message string) {
 		return
 	}
 	sm.retrying = true
+	generation := sm.retryGeneration
 
 	log.Error(message)
 	go func() {
-		for {
-			time.Sleep(transitionRetryInterval)
-			if sm.recheckState() {
+		for attempt := 0; ; attempt++ {
+			time.Sleep(sm.retryDelay(attempt, rand.Float64))
+			if sm.recheckState(generation) {
 				return
 			}
 		}
 	}()
 }
 
-func (sm *stateManager) recheckState() bool {
+// recheckState returns true if the retry loop of the specified
+// generation should stop.
+func (sm *stateManager) recheckState(generation int) bool {
 	if !sm.transitioning.TryAcquire() {
 		return false
 	}
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	if generation != sm.retryGeneration {
+		// The loop was stopped.
+		sm.transitioning.Release()
+		return true
+	}
+	if sm.frozen {
+		sm.transitioning.Release()
+		return false
+	}
 	if sm.wantState == sm.state && sm.wantTabletType == sm.target.TabletType {
 		sm.retrying = false
 		sm.transitioning.Release()
 		return true
 	}
-	go sm.execTransition(sm.wantTabletType, sm.wantState)
+	sm.retryCount++
+	from, tabletType, state := sm.state, sm.wantTabletType, sm.wantState
+	go func() {
+		if err := sm.execTransition(context.Background(), tabletType, state); err == nil {
+			sm.notifyStateChange(from, state, tabletType)
+		}
+	}()
 	return false
 }
 
-// CheckMySQL verifies that we can connect to mysql.
-// If it fails, then we shutdown the service and initiate
-// the retry loop.
-func (sm *stateManager) CheckMySQL() {
-	if !sm.checkMySQLThrottler.TryAcquire() {
+// ForceNotServing immediately stops serving, and stops any retry
+// in progress. The tablet remains pinned to not serving until
+// ClearForcedNotServing is called: requests to serve are recorded,
+// but executed as requests to not serve. Unlike a regular transition,
+// it doesn't require mysql to be reachable. It waits for a transition
+// in progress to complete.
+func (sm *stateManager) ForceNotServing(reason string) {
+	sm.transitioning.Acquire()
+	defer sm.transitioning.Release()
+
+	sm.mu.Lock()
+	if sm.forcedReason == "" {
+		sm.forcedTabletType, sm.forcedState = sm.wantTabletType, sm.wantState
+	}
+	sm.forcedReason = reason
+	// Stop the retry loop, if any.
+	sm.retryGeneration++
+	sm.retrying = false
+	from, tabletType := sm.state, sm.target.TabletType
+	to := from
+	if to == StateServing {
+		to = StateNotServing
+	}
+	sm.wantTabletType, sm.wantState = tabletType, to
+	sm.mu.Unlock()
+
+	log.Warningf("Forcing %v to %s: %s", tabletType, stateName[to], reason)
+	start := sm.now()
+	if from != StateServing {
 		return
 	}
-	go func() {
-		defer func() {
-			time.Sleep(1 * time.Second)
-			sm.checkMySQLThrottler.Release()
-		}()
+	sm.unserveCommon()
+	sm.transitionReason = reason
+	sm.setState(tabletType, StateNotServing)
+	sm.transitionReason = ""
+	sm.recordTransition(TransitionRecord{
+		Time:       start,
+		From:       stateLabel[from],
+		To:         stateLabel[to],
+		TabletType: tabletType.String(),
+		Duration:   sm.now().Sub(start),
+		Reason:     reason,
+	}, nil)
+	sm.notifyStateChange(from, to, tabletType)
+}
 
-		err := sm.qe.IsMySQLReachable()
-		if err == nil {
-			return
-		}
+// DemoteToReadOnly stops a serving master from accepting writes, while
+// it continues to serve reads. The tx engine switches to read-only, and
+// the components that only run on a master are closed. The state remains
+// StateServing. PromoteToReadWrite, or any transition, reverts it. If the
+// tx engine fails to switch to read-only, the master is left unchanged.
+// The messager is closed first, and reopened in that case, since it
+// writes through the tx engine.
+func (sm *stateManager) DemoteToReadOnly() error {
+	sm.transitioning.Acquire()
+	defer sm.transitioning.Release()
 
-		if !sm.transitioning.TryAcquire() {
-			// If we're already transitioning, don't interfere.
-			return
-		}
-		defer sm.transitioning.Release()
+	sm.mu.Lock()
+	state, tabletType, demoted := sm.state, sm.target.TabletType, sm.demoted
+	sm.mu.Unlock()
+	if state != StateServing || tabletType != topodatapb.TabletType_MASTER {
+		return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot demote to read-only: %v %s is not a serving master", tabletType, stateName[state])
+	}
+	if demoted {
+		return nil
+	}
 
-		sm.closeAll()
-		sm.retryTransition(fmt.Sprintf("Cannot connect to MySQL, shutting down query service: %v", err))
-	}()
-}
+	log.Infof("Demoting master to read-only")
+	sm.timed("messager", "close", sm.messager.Close)
+	if err := sm.timedErr("tx_engine", "accept_read_only", sm.te.AcceptReadOnly); err != nil {
+		sm.timed("messager", "open", sm.messager.Open)
+		return err
+	}
+	sm.timed("tracker", "close", sm.tracker.Close)
+	sm.timed("heartbeat_writer", "close", sm.hw.Close)
+	sm.se.MakeNonMaster()
 
-// StopService shuts down sm. If the shutdown doesn't complete
-// within timeBombDuration, it crashes the process.
-func (sm *stateManager) StopService() {
-	defer close(sm.setTimeBomb())
-	sm.SetServingType(sm.Target().TabletType, StateNotConnected, nil)
+	sm.mu.Lock()
+	sm.demoted = true
+	sm.mu.Unlock()
+	return nil
 }
 
-// StartRequest validates the current state and target and registers
-// the request (a waitgroup) as started. Every StartRequest must be
-// ended with an EndRequest.
-func (sm *stateManager) StartRequest(ctx context.Context, target *querypb.Target, allowOnShutdown bool) (err error) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// PromoteToReadWrite undoes DemoteToReadOnly. It's a no-op if the
+// tablet is not demoted.
+func (sm *stateManager) PromoteToReadWrite() error {
+	sm.transitioning.Acquire()
+	defer sm.transitioning.Release()
 
-	if sm.state != StateServing {
-		return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "operation not allowed in state %s", stateName[sm.state])
+	sm.mu.Lock()
+	demoted := sm.demoted
+	sm.mu.Unlock()
+	if !demoted {
+		return nil
 	}
 
-	shuttingDown := sm.wantState != StateServing
-	if shuttingDown && !allowOnShutdown {
-		// This specific error string needs to be returned for vtgate buffering to work.
-		return vterrors.New(vtrpcpb.Code_FAILED_PRECONDITION, "operation not allowed in state SHUTTING_DOWN")
-	}
-
-	if target != nil {
-		switch {
-		case target.Keyspace != sm.target.Keyspace:
-			return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid keyspace %v", target.Keyspace)
-		case target.Shard != sm.target.Shard:
-			return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid shard %v", target.Shard)
-		case target.TabletType != sm.target.TabletType:
-			for _, otherType := range sm.alsoAllow {
-				if target.TabletType == otherType {
-					goto ok
-				}
-			}
-			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "invalid tablet type: %v, want: %v or %v", target.TabletType, sm.target.TabletType, sm.alsoAllow)
-		}
-	} else {
-		if !tabletenv.IsLocalContext(ctx) {
-			return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "No target")
-		}
+	log.Infof("Promoting read-only master to read-write")
+	sm.timed("heartbeat_writer", "open", sm.hw.Open)
+	sm.timed("tracker", "open", sm.tracker.Open)
+	if err := sm.timedErr("tx_engine", "accept_read_write", sm.te.AcceptReadWrite); err != nil {
+		return err
 	}
+	sm.timed("messager", "open", sm.messager.Open)
 
-ok:
-	sm.requests.Add(1)
+	sm.mu.Lock()
+	sm.demoted = false
+	sm.mu.Unlock()
 	return nil
 }
 
+// MasterPromoted is dispatched when PromoteToMaster turns a serving
+// replica into a serving master.
+type MasterPromoted struct {
+	// From is the tablet type before the promotion.
+	From topodatapb.TabletType
+	// Position is the replication position of the new master.
+	Position mysql.Position
+	// Duration is how long the transition took.
+	Duration time.Duration
+}
+
+// PromoteToMaster turns a serving non-master into a serving master: the
+// master-only components are opened, and the tx engine accepts writes.
+// It returns the replication position of mysql once it accepts writes,
+// and dispatches a MasterPromoted event. The tablet must be serving, and
+// not pinned by ForceNotServing. Promoting a serving master only returns
+// its position.
+func (sm *stateManager) PromoteToMaster(ctx context.Context) (mysql.Position, error) {
+	sm.mu.Lock()
+	state, from, forced, alsoAllow := sm.state, sm.target.TabletType, sm.forcedReason, sm.alsoAllow
+	sm.mu.Unlock()
+	if state != StateServing {
+		return mysql.Position{}, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot promote to master: %v %s is not serving", from, stateName[state])
+	}
+	if forced != "" {
+		return mysql.Position{}, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot promote to master: forced not serving: %s", forced)
+	}
+
+	start := sm.now()
+	changed, err := sm.SetServingTypeContext(ctx, topodatapb.TabletType_MASTER, StateServing, alsoAllow)
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	pos, err := sm.masterPosition(ctx)
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	if changed && from != topodatapb.TabletType_MASTER {
+		log.Infof("Promoted %v to master at %v", from, pos)
+		event.Dispatch(&MasterPromoted{
+			From:     from,
+			Position: pos,
+			Duration: sm.now().Sub(start),
+		})
+	}
+	return pos, nil
+}
+
+// masterPosition returns the position reported by positionProbe, or
+// an empty position if there is no probe.
+func (sm *stateManager) masterPosition(ctx context.Context) (mysql.Position, error) {
+	sm.mu.Lock()
+	probe := sm.positionProbe
+	sm.mu.Unlock()
+	if probe == nil {
+		return mysql.Position{}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, mysqlProbeTimeout)
+	defer cancel()
+	pos, err := probe(ctx)
+	if err != nil {
+		return mysql.Position{}, vterrors.Wrap(err, "could not read the master position")
+	}
+	return pos, nil
+}
+
+// ClearForcedNotServing removes the pin set by ForceNotServing, and
+// transitions to the most recently requested type and state.
+func (sm *stateManager) ClearForcedNotServing() (stateChanged bool, err error) {
+	sm.mu.Lock()
+	if sm.forcedReason == "" {
+		sm.mu.Unlock()
+		return false, nil
+	}
+	sm.forcedReason = ""
+	tabletType, state, alsoAllow := sm.forcedTabletType, sm.forcedState, sm.alsoAllow
+	sm.mu.Unlock()
+
+	return sm.SetServingType(tabletType, state, alsoAllow)
+}
+
+// pinnedState records the requested type and state if ForceNotServing
+// is in effect, and returns the state to transition to.
+func (sm *stateManager) pinnedState(tabletType topodatapb.TabletType, state servingState) servingState {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.forcedReason == "" {
+		return state
+	}
+	sm.forcedTabletType, sm.forcedState = tabletType, state
+	if state == StateServing {
+		log.Infof("Serving is disabled until cleared: %s", sm.forcedReason)
+		return StateNotServing
+	}
+	return state
+}
+
+// recordTransition adds the transition to the history, with err as its
+// outcome, and updates the last transition error.
+func (sm *stateManager) recordTransition(record TransitionRecord, err error) {
+	record.Error = errorString(err)
+	if err != nil {
+		sm.failedTransitions.Add(1)
+	} else {
+		sm.transitionCount.Add(1)
+	}
+	sm.mu.Lock()
+	if err != nil {
+		sm.lastError, sm.lastErrorTime = err, record.Time.Add(record.Duration)
+	} else {
+		sm.lastError, sm.lastErrorTime = nil, time.Time{}
+	}
+	sm.mu.Unlock()
+
+	if sm.logTransitionsJSON {
+		sm.logTransitionJSON(record)
+	}
+	if sm.transitions == nil {
+		return
+	}
+	sm.transitions.Add(record)
+}
+
+// SetTransitionMetricsSink replaces the sink that observes the state
+// transitions. The default records them in the tabletenv stats.
+// A nil sink disables the observations.
+func (sm *stateManager) SetTransitionMetricsSink(sink TransitionMetricsSink) {
+	sm.listenersMu.Lock()
+	defer sm.listenersMu.Unlock()
+	sm.metricsSink = sink
+}
+
+// observeTransition reports a transition to the metrics sink, if any.
+func (sm *stateManager) observeTransition(from, to servingState, d time.Duration, err error) {
+	sm.listenersMu.Lock()
+	sink := sm.metricsSink
+	sm.listenersMu.Unlock()
+	if sink == nil {
+		return
+	}
+	sink.ObserveTransition(from, to, d, err)
+}
+
+// TransitionCount returns the number of successful state transitions
+// since startup. A high rate indicates that the tablet is flapping.
+func (sm *stateManager) TransitionCount() int64 {
+	return sm.transitionCount.Get()
+}
+
+// FailedTransitionCount returns the number of failed state transitions
+// since startup, including the failed retries.
+func (sm *stateManager) FailedTransitionCount() int64 {
+	return sm.failedTransitions.Get()
+}
+
+// logTransitionJSON logs record as a single line of JSON.
+func (sm *stateManager) logTransitionJSON(record TransitionRecord) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		log.Errorf("Could not encode transition record %+v: %v", record, err)
+		return
+	}
+	log.Infof("%s", b)
+}
+
+// TransitionHistory returns the most recent transition attempts,
+// in reverse chronological order.
+func (sm *stateManager) TransitionHistory() []TransitionRecord {
+	if sm.transitions == nil {
+		return nil
+	}
+	records := sm.transitions.Records()
+	history := make([]TransitionRecord, 0, len(records))
+	for _, record := range records {
+		history = append(history, record.(TransitionRecord))
+	}
+	return history
+}
+
+// LastError returns the error of the most recent transition, and the
+// time at which it failed. It returns a nil error once a transition
+// succeeds.
+func (sm *stateManager) LastError() (err error, at time.Time) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.lastError, sm.lastErrorTime
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// CheckMySQL verifies that we can connect to mysql.
+// If it fails, then we shutdown the service and initiate
+// the retry loop.
+func (sm *stateManager) CheckMySQL() {
+	if !sm.checkMySQLThrottler.TryAcquire() {
+		sm.checkMySQLThrottled.Add(1)
+		return
+	}
+	go func() {
+		defer func() {
+			time.Sleep(sm.checkMySQLDelay(rand.Float64))
+			sm.checkMySQLThrottler.Release()
+		}()
+
+		err := sm.isMySQLHealthy()
+		if err == nil {
+			sm.checkMySQLHealthy.Add(1)
+			return
+		}
+		sm.checkMySQLLastFailure.Set(sm.now().UnixNano())
+
+		if !sm.transitioning.TryAcquire() {
+			// If we're already transitioning, don't interfere.
+			sm.checkMySQLSkipped.Add(1)
+			return
+		}
+		defer sm.transitioning.Release()
+		sm.mu.Lock()
+		frozen := sm.frozen
+		sm.mu.Unlock()
+		if frozen {
+			// The state must not change until transitions are unfrozen.
+			sm.checkMySQLSkipped.Add(1)
+			return
+		}
+
+		sm.checkMySQLShutdowns.Add(1)
+		sm.closeAll()
+		message := fmt.Sprintf("Cannot connect to MySQL, shutting down query service: %v", err)
+		if sm.autoRecoverEnabled() {
+			sm.startRecovery(message)
+			return
+		}
+		sm.retryTransition(message)
+	}()
+}
+
+// CheckMySQLCounts returns the number of CheckMySQL calls by outcome:
+// Throttled if a recent check was still in effect, Healthy if mysql
+// was healthy, Skipped if mysql failed during a transition or while
+// transitions were frozen, and ShutDown if the failure shut the query service down.
+func (sm *stateManager) CheckMySQLCounts() map[string]int64 {
+	return map[string]int64{
+		"Throttled": sm.checkMySQLThrottled.Get(),
+		"Healthy":   sm.checkMySQLHealthy.Get(),
+		"Skipped":   sm.checkMySQLSkipped.Get(),
+		"ShutDown":  sm.checkMySQLShutdowns.Get(),
+	}
+}
+
+// LastCheckMySQLFailure returns the time at which CheckMySQL last
+// found mysql unhealthy, or the zero time if it never did.
+func (sm *stateManager) LastCheckMySQLFailure() time.Time {
+	nanos := sm.checkMySQLLastFailure.Get()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// MySQLRecovered is dispatched when a tablet in auto-recover mode
+// returns to its desired state after CheckMySQL shut it down.
+type MySQLRecovered struct {
+	// TabletType and State are what the tablet recovered to.
+	TabletType topodatapb.TabletType
+	State      string
+	// Downtime is the time elapsed since the tablet was shut down.
+	Downtime time.Duration
+}
+
+// SetAutoRecover enables or disables the auto-recover mode. In this
+// mode, after CheckMySQL shuts everything down, the tablet polls mysql
+// instead of retrying transitions. It transitions back to the state it
+// wanted once mysql is reachable, and dispatches a MySQLRecovered event.
+func (sm *stateManager) SetAutoRecover(enabled bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.autoRecover = enabled
+}
+
+func (sm *stateManager) autoRecoverEnabled() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.autoRecover
+}
+
+// startRecovery launches the auto-recover loop. Like retryTransition,
+// it does nothing if a retry is already in progress, and it's stopped
+// by incrementing retryGeneration.
+func (sm *stateManager) startRecovery(message string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.retrying {
+		return
+	}
+	sm.retrying = true
+	generation := sm.retryGeneration
+	since := sm.now()
+
+	log.Errorf("%s, will recover to %v %v once it's reachable", message, sm.wantTabletType, stateName[sm.wantState])
+	go func() {
+		for attempt := 0; ; attempt++ {
+			time.Sleep(sm.retryDelay(attempt, rand.Float64))
+			if sm.isMySQLHealthy() != nil {
+				continue
+			}
+			if sm.recover(generation, since) {
+				return
+			}
+		}
+	}()
+}
+
+// recover transitions to the desired state now that mysql is
+// reachable. It returns true if the recovery loop of the specified
+// generation should stop. Like recheckState, it waits while
+// transitions are frozen.
+func (sm *stateManager) recover(generation int, since time.Time) bool {
+	if !sm.transitioning.TryAcquire() {
+		return false
+	}
+	sm.mu.Lock()
+	if generation != sm.retryGeneration {
+		// The loop was stopped.
+		sm.mu.Unlock()
+		sm.transitioning.Release()
+		return true
+	}
+	if sm.frozen {
+		sm.mu.Unlock()
+		sm.transitioning.Release()
+		return false
+	}
+	if sm.wantState == sm.state && sm.wantTabletType == sm.target.TabletType {
+		sm.retrying = false
+		sm.mu.Unlock()
+		sm.transitioning.Release()
+		return true
+	}
+	sm.retryCount++
+	from, tabletType, state := sm.state, sm.wantTabletType, sm.wantState
+	sm.mu.Unlock()
+
+	// execTransition releases the semaphore.
+	if err := sm.execTransition(context.Background(), tabletType, state); err != nil {
+		return false
+	}
+	sm.mu.Lock()
+	if generation == sm.retryGeneration {
+		sm.retrying = false
+	}
+	sm.mu.Unlock()
+
+	log.Infof("Recovered to %v %v after MySQL became reachable", tabletType, stateName[state])
+	sm.notifyStateChange(from, state, tabletType)
+	event.Dispatch(&MySQLRecovered{
+		TabletType: tabletType,
+		State:      stateName[state],
+		Downtime:   sm.now().Sub(since),
+	})
+	return true
+}
+
+// retryDelay returns the delay before the specified transition retry
+// attempt, starting at 0. randFloat must return values in [0, 1).
+func (sm *stateManager) retryDelay(attempt int, randFloat func() float64) time.Duration {
+	if sm.retryBackoff.initial == 0 {
+		return sm.transitionRetryInterval
+	}
+	return sm.retryBackoff.interval(attempt, randFloat)
+}
+
+// checkMySQLDelay returns the time for which CheckMySQL remains
+// throttled after a check. randFloat must return values in [0, 1).
+func (sm *stateManager) checkMySQLDelay(randFloat func() float64) time.Duration {
+	if sm.checkMySQLBackoff.initial == 0 {
+		return defaultCheckMySQLInterval
+	}
+	return sm.checkMySQLBackoff.interval(0, randFloat)
+}
+
+// SetMySQLProbe installs an additional check that must pass for mysql
+// to be considered healthy. It's invoked after IsMySQLReachable, both
+// by CheckMySQL and when connecting during a transition. A nil probe
+// removes the check.
+func (sm *stateManager) SetMySQLProbe(probe func(ctx context.Context) error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.mysqlProbe = probe
+}
+
+// isMySQLHealthy returns an error if mysql is unreachable,
+// or if the custom probe fails.
+func (sm *stateManager) isMySQLHealthy() error {
+	if err := sm.qe.IsMySQLReachable(); err != nil {
+		return err
+	}
+	sm.mysqlLastReachable.Set(sm.now().UnixNano())
+	sm.mu.Lock()
+	probe := sm.mysqlProbe
+	sm.mu.Unlock()
+	if probe == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mysqlProbeTimeout)
+	defer cancel()
+	if err := probe(ctx); err != nil {
+		sm.mysqlProbeFailures.Add(1)
+		log.Errorf("MySQL probe failed: %v", err)
+		return vterrors.Wrap(err, "mysql probe failed")
+	}
+	return nil
+}
+
+// SetReadOnlyProbe installs the check used to confirm that mysql is
+// read-only when serving as a non-master. It's only consulted if
+// verifyReadOnly is set.
+func (sm *stateManager) SetReadOnlyProbe(probe func(ctx context.Context) (bool, error)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.readOnlyProbe = probe
+}
+
+// EnterMaintenance makes StartRequest reject requests with msg, and an
+// UNAVAILABLE code so that clients retry elsewhere. Unlike a transition,
+// the subcomponents remain open and the serving state is unchanged.
+// Requests without a target are internal, and are still accepted.
+func (sm *stateManager) EnterMaintenance(msg string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	log.Infof("Entering maintenance: %s", msg)
+	sm.maintenance, sm.maintenanceMessage = true, msg
+}
+
+// ExitMaintenance undoes EnterMaintenance.
+func (sm *stateManager) ExitMaintenance() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !sm.maintenance {
+		return
+	}
+	log.Info("Exiting maintenance")
+	sm.maintenance, sm.maintenanceMessage = false, ""
+}
+
+// SetReplicaLagGate installs the function that reports the replication
+// lag for StartRequest and IsDegraded. ok is false if the lag is
+// unknown. The gate is only consulted if maxReplicaLag or
+// degradedReplicaLag is set. It's invoked without holding sm.mu,
+// so that it can call back into sm.
+func (sm *stateManager) SetReplicaLagGate(gate func() (lag time.Duration, ok bool)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.replicaLagGate = gate
+}
+
+// RestoreProgress describes the progress of a restore. Phase is a
+// free form description of the current step, e.g. "downloading".
+// Bytes is the amount of data restored so far, and ETA is the
+// estimated time remaining, or 0 if unknown. Done is set once the
+// restore has completed.
+type RestoreProgress struct {
+	Phase string
+	Bytes int64
+	ETA   time.Duration
+	Done  bool
+}
+
+// RestoreCompleted is dispatched when the tablet transitions to the
+// post-restore target after a restore completed.
+type RestoreCompleted struct {
+	TabletType topodatapb.TabletType
+	State      string
+}
+
+// SetRestoreProgressSource installs the function that reports the
+// progress of a restore for RestoreStatus. A nil source removes it.
+func (sm *stateManager) SetRestoreProgressSource(source func() RestoreProgress) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.restoreProgress = source
+}
+
+// SetPostRestoreTarget makes the tablet transition to tabletType and
+// state once the restore progress source reports that the restore is
+// done, instead of waiting for an explicit SetServingType. A
+// RestoreCompleted event is dispatched after the transition. The
+// progress is polled while the tablet type is RESTORE. A tabletType
+// of UNKNOWN disables the transition.
+func (sm *stateManager) SetPostRestoreTarget(tabletType topodatapb.TabletType, state servingState) {
+	sm.mu.Lock()
+	sm.postRestoreType, sm.postRestoreState = tabletType, state
+	restoring := sm.target.TabletType == topodatapb.TabletType_RESTORE
+	sm.mu.Unlock()
+	if restoring {
+		sm.watchRestore()
+	}
+}
+
+// watchRestore launches the loop that polls the restore progress, if
+// a post-restore target is set and the loop isn't already running.
+// The loop exits once the tablet type isn't RESTORE anymore, or the
+// post-restore target is cleared.
+func (sm *stateManager) watchRestore() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.watchingRestore || sm.postRestoreType == topodatapb.TabletType_UNKNOWN {
+		return
+	}
+	sm.watchingRestore = true
+	interval := sm.restorePollInterval
+	if interval == 0 {
+		interval = defaultRestorePollInterval
+	}
+	go func() {
+		for {
+			time.Sleep(interval)
+			if sm.pollRestore() {
+				return
+			}
+		}
+	}()
+}
+
+// pollRestore checks whether the restore completed, and transitions
+// to the post-restore target if it did. It returns true if the loop
+// launched by watchRestore should stop.
+func (sm *stateManager) pollRestore() bool {
+	sm.mu.Lock()
+	tabletType, state := sm.postRestoreType, sm.postRestoreState
+	source := sm.restoreProgress
+	if sm.target.TabletType != topodatapb.TabletType_RESTORE || tabletType == topodatapb.TabletType_UNKNOWN {
+		sm.watchingRestore = false
+		sm.mu.Unlock()
+		return true
+	}
+	sm.mu.Unlock()
+
+	// The source is invoked without holding sm.mu, like in RestoreStatus.
+	if source == nil || !source().Done {
+		return false
+	}
+	sm.mu.Lock()
+	sm.watchingRestore = false
+	sm.mu.Unlock()
+
+	log.Infof("Restore completed, transitioning to %v %v", tabletType, stateName[state])
+	if _, err := sm.SetServingTypeWithReason(context.Background(), tabletType, state, nil, "restore completed"); err != nil {
+		// SetServingType retries failed transitions by itself.
+		log.Errorf("Transition to %v %v after restore failed: %v", tabletType, stateName[state], err)
+		return true
+	}
+	event.Dispatch(&RestoreCompleted{
+		TabletType: tabletType,
+		State:      stateName[state],
+	})
+	return true
+}
+
+// RestoreStatus returns the progress of the restore in progress. ok is
+// false if the tablet type isn't RESTORE, or if no progress source is
+// installed. The state remains StateNotConnected during a restore.
+func (sm *stateManager) RestoreStatus() (progress RestoreProgress, ok bool) {
+	sm.mu.Lock()
+	source := sm.restoreProgress
+	restoring := sm.target.TabletType == topodatapb.TabletType_RESTORE
+	sm.mu.Unlock()
+	if !restoring || source == nil {
+		return RestoreProgress{}, false
+	}
+	// The source is invoked without holding sm.mu, so that it
+	// can call back into sm.
+	return source(), true
+}
+
+// replicaLagGateLocked returns the gate that requests for target must
+// pass, or nil if their replication lag doesn't need to be checked.
+// Only requests for a non-master are checked. Requests without a target
+// are internal, and are not checked. sm.mu must be held.
+func (sm *stateManager) replicaLagGateLocked(target *querypb.Target) func() (time.Duration, bool) {
+	if target == nil || sm.maxReplicaLag == 0 || sm.target.TabletType == topodatapb.TabletType_MASTER {
+		return nil
+	}
+	return sm.replicaLagGate
+}
+
+// checkReplicaLag rejects requests if the replication lag reported
+// by gate exceeds maxLag or is unknown. sm.mu must not be held.
+func checkReplicaLag(gate func() (time.Duration, bool), maxLag time.Duration) error {
+	lag, ok := gate()
+	if !ok {
+		return newRequestError(ErrReplicaLagged, vterrors.New(vtrpcpb.Code_FAILED_PRECONDITION, "replication lag is unknown"))
+	}
+	if lag > maxLag {
+		return newRequestError(ErrReplicaLagged, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "replication lag %v exceeds %v", lag, maxLag))
+	}
+	return nil
+}
+
+// checkReadOnly returns an error if verifyReadOnly is set and mysql
+// does not report being read-only. The error fails the transition,
+// which is then retried.
+func (sm *stateManager) checkReadOnly(ctx context.Context) error {
+	if sm.planned != nil {
+		return nil
+	}
+	sm.mu.Lock()
+	verify, probe := sm.verifyReadOnly, sm.readOnlyProbe
+	sm.mu.Unlock()
+	if !verify || probe == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, mysqlProbeTimeout)
+	defer cancel()
+	readOnly, err := probe(ctx)
+	if err != nil {
+		return vterrors.Wrap(err, "read-only probe failed")
+	}
+	if !readOnly {
+		return vterrors.New(vtrpcpb.Code_FAILED_PRECONDITION, "mysql is not read-only after accepting read-only transactions")
+	}
+	return nil
+}
+
+// MySQLProbeFailures returns the number of times the custom
+// mysql probe has failed.
+func (sm *stateManager) MySQLProbeFailures() int64 {
+	return sm.mysqlProbeFailures.Get()
+}
+
+// InFlightRequests returns the number of requests that have
+// been started but not yet ended.
+func (sm *stateManager) InFlightRequests() int64 {
+	return sm.inFlight.Get()
+}
+
+// RequestInfo describes a request registered by TrackRequest.
+type RequestInfo struct {
+	Method string
+	Start  time.Time
+	Target *querypb.Target
+}
+
+// TrackRequest registers a started request for InFlightRequestInfo,
+// and returns the function that unregisters it once it has ended.
+// To keep the overhead low, requests are only registered if request
+// tracking is enabled, or while the tablet is draining. Requests
+// that started before the drain are not registered in the latter case.
+func (sm *stateManager) TrackRequest(method string, target *querypb.Target) (untrack func()) {
+	if !sm.trackRequests && !sm.draining.Get() {
+		return func() {}
+	}
+	info := &RequestInfo{Method: method, Start: sm.now(), Target: target}
+	sm.activeRequestsMu.Lock()
+	defer sm.activeRequestsMu.Unlock()
+	if sm.activeRequests == nil {
+		sm.activeRequests = make(map[*RequestInfo]struct{})
+	}
+	sm.activeRequests[info] = struct{}{}
+	return func() {
+		sm.activeRequestsMu.Lock()
+		defer sm.activeRequestsMu.Unlock()
+		delete(sm.activeRequests, info)
+	}
+}
+
+// InFlightRequestInfo returns the requests registered by TrackRequest
+// that haven't ended yet, the oldest first. It helps identify the
+// requests that hold back a drain.
+func (sm *stateManager) InFlightRequestInfo() []RequestInfo {
+	sm.activeRequestsMu.Lock()
+	infos := make([]RequestInfo, 0, len(sm.activeRequests))
+	for info := range sm.activeRequests {
+		infos = append(infos, *info)
+	}
+	sm.activeRequestsMu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Start.Before(infos[j].Start)
+	})
+	return infos
+}
+
+// StopService shuts down sm. If the shutdown doesn't complete
+// within timeBombDuration, it crashes the process.
+func (sm *stateManager) StopService() {
+	sm.StopServiceWithDrain(0)
+}
+
+// StopServiceWithDrain shuts down sm in two phases. New requests are
+// rejected right away, and in-flight requests are given up to softDrain
+// to complete. After that, the shutdown proceeds as in StopService, with
+// the timebomb acting as the hard cutoff.
+func (sm *stateManager) StopServiceWithDrain(softDrain time.Duration) {
+	if sm.isStopped() {
+		sm.redundantStops.Add(1)
+		return
+	}
+	// New requests are rejected until the shutdown transition
+	// has set wantState.
+	sm.draining.Set(true)
+	defer sm.draining.Set(false)
+	sm.drainRequests(softDrain)
+
+	defer close(sm.setTimeBomb())
+	sm.SetServingType(sm.Target().TabletType, StateNotConnected, nil)
+}
+
+// isStopped returns true if the service is already stopped, and
+// is not being restarted: a further stop has nothing to do.
+func (sm *stateManager) isStopped() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.state == StateNotConnected && sm.wantState == StateNotConnected && !sm.retrying
+}
+
+// RedundantStops returns the number of StopService calls that
+// were ignored because the service was already stopped.
+func (sm *stateManager) RedundantStops() int64 {
+	return sm.redundantStops.Get()
+}
+
+// Reset returns sm to StateNotConnected so that it can be reused,
+// e.g. when the tablet is restarted in-process. All subcomponents
+// are closed, and the desired state, the overrides set by
+// ForceNotServing, EnterMaintenance, DemoteToReadOnly and lameduck,
+// the last error and the counters are cleared. The target, the
+// configuration, the probes and the listeners are retained, as are
+// the history records. Reset fails with an ErrTransitionBusy error if
+// a transition is in progress, and fails if requests are in flight. If
+// a subcomponent panics while closing, Reset completes anyway, and
+// returns the panic as an error.
+func (sm *stateManager) Reset() error {
+	if !sm.transitioning.TryAcquire() {
+		return newRequestError(ErrTransitionBusy, vterrors.New(vtrpcpb.Code_UNAVAILABLE, "cannot reset while a state transition is in progress"))
+	}
+	defer sm.transitioning.Release()
+	if n := sm.inFlight.Get(); n != 0 {
+		return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot reset with %d requests in flight", n)
+	}
+
+	sm.mu.Lock()
+	// Stop the retry loop, if any.
+	sm.retryGeneration++
+	sm.retrying = false
+	sm.retryCount = 0
+	sm.wantState = StateNotConnected
+	sm.mu.Unlock()
+
+	log.Infof("Resetting the state manager")
+	sm.ExitLameduck()
+	closeErr := sm.closeAll()
+
+	sm.mu.Lock()
+	sm.forcedReason = ""
+	sm.forcedTabletType, sm.forcedState = topodatapb.TabletType_UNKNOWN, StateNotConnected
+	sm.demoted = false
+	sm.maintenance, sm.maintenanceMessage = false, ""
+	sm.lastError, sm.lastErrorTime = nil, time.Time{}
+	sm.alsoAllow = nil
+	sm.lameduckDeadline = time.Time{}
+	sm.timedState, sm.stateSince, sm.stateDurations = stateKey{}, time.Time{}, nil
+	sm.requestCounts = nil
+	sm.mu.Unlock()
+
+	for _, counter := range []*sync2.AtomicInt64{
+		&sm.drainedRequests,
+		&sm.drainTimedOutRequests,
+		&sm.redundantStops,
+		&sm.mysqlProbeFailures,
+		&sm.mysqlLastReachable,
+		&sm.checkMySQLThrottled,
+		&sm.checkMySQLHealthy,
+		&sm.checkMySQLSkipped,
+		&sm.checkMySQLShutdowns,
+		&sm.checkMySQLLastFailure,
+		&sm.slowTransitions,
+		&sm.transitionCount,
+		&sm.failedTransitions,
+	} {
+		counter.Set(0)
+	}
+	return closeErr
+}
+
+// drainRequests waits up to softDrain for in-flight requests to
+// complete. It must be called with sm.draining set.
+func (sm *stateManager) drainRequests(softDrain time.Duration) {
+	pending := sm.inFlight.Get()
+	if softDrain > 0 && pending > 0 {
+		log.Infof("Waiting up to %v for %d requests to drain", softDrain, pending)
+		drained := make(chan struct{})
+		go func() {
+			sm.requests.Wait()
+			close(drained)
+		}()
+		tmr := time.NewTimer(softDrain)
+		defer tmr.Stop()
+		select {
+		case <-drained:
+		case <-tmr.C:
+		}
+	}
+	remaining := sm.inFlight.Get()
+	if remaining > pending {
+		// Requests allowed on shutdown may have started since.
+		pending = remaining
+	}
+	sm.drainedRequests.Add(pending - remaining)
+	sm.drainTimedOutRequests.Add(remaining)
+	if remaining > 0 {
+		log.Warningf("%d requests did not drain within %v, proceeding with shutdown", remaining, softDrain)
+		for _, info := range sm.InFlightRequestInfo() {
+			log.Warningf("Request still in flight: %s for %v since %v", info.Method, info.Target, info.Start)
+		}
+	}
+}
+
+// DrainCounts returns the number of requests that drained and
+// the ones that were still in flight when the soft drain period of
+// StopServiceWithDrain expired.
+func (sm *stateManager) DrainCounts() map[string]int64 {
+	return map[string]int64{
+		"Drained":       sm.drainedRequests.Get(),
+		"DrainTimedOut": sm.drainTimedOutRequests.Get(),
+	}
+}
+
+// StartRequest validates the current state and target and registers
+// the request (a waitgroup) as started. Every StartRequest must be
+// ended with an EndRequest. Rejections are reported to the listeners
+// registered by OnRequestRejected.
+func (sm *stateManager) StartRequest(ctx context.Context, target *querypb.Target, allowOnShutdown bool) error {
+	err := sm.startRequest(ctx, target, allowOnShutdown)
+	if err != nil {
+		sm.notifyRequestRejected(target, err)
+	}
+	return err
+}
+
+// startRequest performs the checks of StartRequest. The replication
+// lag is checked last, after sm.mu is released, and the request is
+// unregistered if it's rejected.
+func (sm *stateManager) startRequest(ctx context.Context, target *querypb.Target, allowOnShutdown bool) error {
+	gate, maxLag, err := sm.admitRequest(ctx, target, allowOnShutdown)
+	if gate == nil {
+		return err
+	}
+	if err = checkReplicaLag(gate, maxLag); err != nil {
+		sm.EndRequest()
+	}
+	sm.mu.Lock()
+	sm.countRequestLocked(err)
+	sm.mu.Unlock()
+	return err
+}
+
+// admitRequest performs the checks of StartRequest under sm.mu, and
+// registers the request if they pass. If the replication lag must also
+// be checked, it returns the gate and the lag to check it against, and
+// counting the request is left to the caller.
+func (sm *stateManager) admitRequest(ctx context.Context, target *querypb.Target, allowOnShutdown bool) (gate func() (time.Duration, bool), maxLag time.Duration, err error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	defer func() {
+		if gate == nil {
+			sm.countRequestLocked(err)
+		}
+	}()
+
+	if sm.state != StateServing {
+		return nil, 0, newRequestError(ErrNotServing, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "operation not allowed in state %s", stateName[sm.state]))
+	}
+
+	shuttingDown := sm.wantState != StateServing || sm.draining.Get()
+	if shuttingDown && !allowOnShutdown {
+		// This specific error string needs to be returned for vtgate buffering to work.
+		return nil, 0, newRequestError(ErrNotServing, vterrors.New(vtrpcpb.Code_FAILED_PRECONDITION, "operation not allowed in state SHUTTING_DOWN"))
+	}
+
+	if sentinel := sm.checkTargetLocked(ctx, target); sentinel != nil {
+		return nil, 0, sm.targetErrorLocked(sentinel, target)
+	}
+	if sm.maintenance && target != nil {
+		return nil, 0, newRequestError(ErrMaintenance, vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "tablet is in maintenance: %s", sm.maintenanceMessage))
+	}
+
+	sm.requests.Add(1)
+	sm.inFlight.Add(1)
+	return sm.replicaLagGateLocked(target), sm.maxReplicaLag, nil
+}
+
+// countRequestLocked records the outcome of a StartRequest against
+// the current tablet type. It must be called under sm.mu.
+func (sm *stateManager) countRequestLocked(err error) {
+	outcome := "Accepted"
+	if err != nil {
+		outcome = "Unknown"
+		if rerr, ok := err.(*requestError); ok {
+			if label, ok := requestOutcomes[rerr.sentinel]; ok {
+				outcome = label
+			}
+		}
+	}
+	if sm.requestCounts == nil {
+		sm.requestCounts = make(map[requestKey]int64)
+	}
+	sm.requestCounts[requestKey{tabletType: sm.target.TabletType, outcome: outcome}]++
+}
+
+// RequestCounts returns the number of accepted and rejected
+// StartRequest calls, keyed by tablet type and outcome.
+func (sm *stateManager) RequestCounts() map[string]int64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	counts := make(map[string]int64, len(sm.requestCounts))
+	for key, n := range sm.requestCounts {
+		counts[key.tabletType.String()+"."+key.outcome] = n
+	}
+	return counts
+}
+
 // EndRequest unregisters the current request (a waitgroup) as done.
 func (sm *stateManager) EndRequest() {
+	sm.inFlight.Add(-1)
 	sm.requests.Done()
 }
 
@@ -334,130 +2190,309 @@ func (sm *stateManager) EndRequest() {
 func (sm *stateManager) VerifyTarget(ctx context.Context, target *querypb.Target) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	if target != nil {
-		switch {
-		case target.Keyspace != sm.target.Keyspace:
-			return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid keyspace %v", target.Keyspace)
-		case target.Shard != sm.target.Shard:
-			return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid shard %v", target.Shard)
-		case target.TabletType != sm.target.TabletType:
-			for _, otherType := range sm.alsoAllow {
-				if target.TabletType == otherType {
-					return nil
-				}
-			}
-			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "invalid tablet type: %v, want: %v or %v", target.TabletType, sm.target.TabletType, sm.alsoAllow)
-		}
-	} else {
+	if sentinel := sm.checkTargetLocked(ctx, target); sentinel != nil {
+		return sm.targetErrorLocked(sentinel, target)
+	}
+	return nil
+}
+
+// VerifyTargetOK is like VerifyTarget, but only reports whether the
+// target is acceptable. It does not build an error on either path.
+func (sm *stateManager) VerifyTargetOK(ctx context.Context, target *querypb.Target) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.checkTargetLocked(ctx, target) == nil
+}
+
+// checkTargetLocked validates the target against the current one and
+// returns the sentinel for the first mismatch, or nil. It must be called
+// under sm.mu.
+func (sm *stateManager) checkTargetLocked(ctx context.Context, target *querypb.Target) error {
+	if target == nil {
 		if !tabletenv.IsLocalContext(ctx) {
-			return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "No target")
+			return ErrNoTarget
 		}
+		return nil
+	}
+	switch {
+	case target.Keyspace != sm.target.Keyspace:
+		return ErrInvalidKeyspace
+	case target.Shard != sm.target.Shard:
+		return ErrInvalidShard
+	case !sm.isTypeAllowedLocked(target.TabletType):
+		return ErrInvalidTabletType
 	}
 	return nil
 }
 
-func (sm *stateManager) serveMaster() error {
-	sm.watcher.Close()
-	sm.hr.Close()
+// IsTypeAllowed returns true if requests for the tablet type are
+// accepted: it's either the current tablet type, or one of the
+// types allowed by alsoAllow.
+func (sm *stateManager) IsTypeAllowed(tabletType topodatapb.TabletType) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.isTypeAllowedLocked(tabletType)
+}
+
+// isTypeAllowedLocked is IsTypeAllowed. It must be called under sm.mu.
+func (sm *stateManager) isTypeAllowedLocked(tabletType topodatapb.TabletType) bool {
+	if tabletType == sm.target.TabletType {
+		return true
+	}
+	for _, otherType := range sm.alsoAllow {
+		if tabletType == otherType {
+			return true
+		}
+	}
+	return false
+}
+
+// targetErrorLocked builds the descriptive error for a sentinel
+// returned by checkTargetLocked. It must be called under sm.mu.
+func (sm *stateManager) targetErrorLocked(sentinel error, target *querypb.Target) error {
+	switch sentinel {
+	case ErrInvalidKeyspace:
+		return newRequestError(sentinel, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid keyspace %v", target.Keyspace))
+	case ErrInvalidShard:
+		return newRequestError(sentinel, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid shard %v", target.Shard))
+	case ErrInvalidTabletType:
+		return newRequestError(sentinel, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "invalid tablet type: %v, want: %v or %v", target.TabletType, sm.target.TabletType, sm.alsoAllow))
+	}
+	return newRequestError(ErrNoTarget, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "No target"))
+}
+
+func (sm *stateManager) serveMaster(ctx context.Context) error {
+	sm.timed("replication_watcher", "close", sm.watcher.Close)
+	sm.timed("heartbeat_reader", "close", sm.hr.Close)
 
-	if err := sm.connect(); err != nil {
+	if err := sm.connect(ctx); err != nil {
 		return err
 	}
 
-	sm.hw.Open()
-	sm.tracker.Open()
-	if err := sm.te.AcceptReadWrite(); err != nil {
+	sm.timed("heartbeat_writer", "open", sm.hw.Open)
+	sm.timed("tracker", "open", sm.tracker.Open)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := sm.timedErr("tx_engine", "accept_read_write", sm.te.AcceptReadWrite); err != nil {
 		return err
 	}
-	sm.messager.Open()
+	sm.timed("messager", "open", sm.messager.Open)
 	sm.setState(topodatapb.TabletType_MASTER, StateServing)
 	return nil
 }
 
-func (sm *stateManager) unserveMaster() error {
+func (sm *stateManager) unserveMaster(ctx context.Context) error {
 	sm.unserveCommon()
 
-	sm.watcher.Close()
-	sm.hr.Close()
+	sm.timed("replication_watcher", "close", sm.watcher.Close)
+	sm.timed("heartbeat_reader", "close", sm.hr.Close)
 
-	if err := sm.connect(); err != nil {
+	if err := sm.connect(ctx); err != nil {
 		return err
 	}
 
-	sm.hw.Open()
-	sm.tracker.Open()
+	sm.timed("heartbeat_writer", "open", sm.hw.Open)
+	sm.timed("tracker", "open", sm.tracker.Open)
 	sm.setState(topodatapb.TabletType_MASTER, StateNotServing)
 	return nil
 }
 
-func (sm *stateManager) serveNonMaster(wantTabletType topodatapb.TabletType) error {
-	sm.messager.Close()
-	sm.tracker.Close()
-	sm.hw.Close()
-	sm.se.MakeNonMaster()
+func (sm *stateManager) serveNonMaster(ctx context.Context, wantTabletType topodatapb.TabletType) error {
+	sm.timed("messager", "close", sm.messager.Close)
+	sm.timed("tracker", "close", sm.tracker.Close)
+	sm.timed("heartbeat_writer", "close", sm.hw.Close)
+	sm.makeNonMaster()
 
-	if err := sm.connect(); err != nil {
+	if err := sm.connect(ctx); err != nil {
 		return err
 	}
 
-	if err := sm.te.AcceptReadOnly(); err != nil {
+	if err := sm.timedErr("tx_engine", "accept_read_only", sm.te.AcceptReadOnly); err != nil {
 		return err
 	}
-	sm.hr.Open()
-	sm.watcher.Open()
+	if err := sm.checkReadOnly(ctx); err != nil {
+		return &TransitionError{Component: "mysql", Phase: "read_only_check", Err: err}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sm.timed("heartbeat_reader", "open", sm.hr.Open)
+	sm.timed("replication_watcher", "open", sm.watcher.Open)
 	sm.setState(wantTabletType, StateServing)
 	return nil
 }
 
-func (sm *stateManager) unserveNonMaster(wantTabletType topodatapb.TabletType) error {
+func (sm *stateManager) unserveNonMaster(ctx context.Context, wantTabletType topodatapb.TabletType) error {
 	sm.unserveCommon()
 
-	sm.tracker.Close()
-	sm.hw.Close()
-	sm.se.MakeNonMaster()
+	sm.timed("tracker", "close", sm.tracker.Close)
+	sm.timed("heartbeat_writer", "close", sm.hw.Close)
+	sm.makeNonMaster()
 
-	if err := sm.connect(); err != nil {
+	if err := sm.connect(ctx); err != nil {
 		return err
 	}
 
-	sm.hr.Open()
-	sm.watcher.Open()
+	sm.timed("heartbeat_reader", "open", sm.hr.Open)
+	sm.timed("replication_watcher", "open", sm.watcher.Open)
 	sm.setState(wantTabletType, StateNotServing)
 	return nil
 }
 
-func (sm *stateManager) connect() error {
-	if err := sm.qe.IsMySQLReachable(); err != nil {
+// connect opens the components needed to talk to mysql. It stops
+// early if ctx is done.
+func (sm *stateManager) connect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
-	if err := sm.se.Open(); err != nil {
-		return err
+	// A planned transition assumes that mysql is healthy.
+	if sm.planned == nil {
+		if err := sm.isMySQLHealthy(); err != nil {
+			return &TransitionError{Component: "mysql", Phase: "health_check", Err: err}
+		}
 	}
-	sm.vstreamer.Open()
-	if err := sm.qe.Open(); err != nil {
-		return err
+	for i, c := range sm.openComponents() {
+		if i > 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := sm.timedErr(c.name, "open", c.open); err != nil {
+			return err
+		}
 	}
-	return sm.txThrottler.Open()
+	return nil
 }
 
+// unserveCommon stops the query traffic. The vstreamer is left open
+// so that change streams survive a NOT_SERVING tablet, and is only
+// closed by closeAll.
 func (sm *stateManager) unserveCommon() {
-	sm.messager.Close()
-	sm.te.Close()
+	sm.timed("messager", "close", sm.messager.Close)
+	sm.timed("tx_engine", "close", sm.te.Close)
+	if sm.planned != nil {
+		return
+	}
 	sm.qe.StopServing()
 	sm.requests.Wait()
 }
 
-func (sm *stateManager) closeAll() {
+// makeNonMaster tells the schema engine that the tablet is not a master,
+// unless the transition is only being planned.
+func (sm *stateManager) makeNonMaster() {
+	if sm.planned != nil {
+		return
+	}
+	sm.se.MakeNonMaster()
+}
+
+// closeAll closes all the subcomponents. A subcomponent that panics
+// doesn't stop the others from being closed: the panics are returned
+// as an aggregated error, and the state is StateNotConnected anyway.
+func (sm *stateManager) closeAll() error {
 	sm.unserveCommon()
-	sm.txThrottler.Close()
-	sm.qe.Close()
-	sm.watcher.Close()
-	sm.tracker.Close()
-	sm.vstreamer.Close()
-	sm.hr.Close()
-	sm.hw.Close()
-	sm.se.Close()
+	var allErr concurrency.AllErrorRecorder
+	for _, c := range sm.closeComponents() {
+		allErr.RecordError(sm.timed(c.name, "close", c.close))
+	}
 	sm.setState(topodatapb.TabletType_UNKNOWN, StateNotConnected)
+	return allErr.AggrError(vterrors.Aggregate)
+}
+
+// timed invokes fn, and records how long it took as the
+// duration of the subcomponent operation, named component_phase.
+// The component is tracked as closed if phase is close, and as
+// open otherwise. If fn panics, the panic is returned as an error,
+// and the tracked state of the component is left unchanged.
+func (sm *stateManager) timed(component, phase string, fn func()) error {
+	return sm.timedErr(component, phase, func() error {
+		fn()
+		return nil
+	})
+}
+
+// timedErr is like timed, for operations that can fail. A failure
+// or a panic is returned as a TransitionError, and leaves the tracked
+// state of the component unchanged.
+func (sm *stateManager) timedErr(component, phase string, fn func() error) error {
+	if sm.planned != nil {
+		*sm.planned = append(*sm.planned, component+"_"+phase)
+		return nil
+	}
+	start := sm.now()
+	err := callRecovered(component, phase, fn)
+	sm.recordComponentTiming(component+"_"+phase, start)
+	if err != nil {
+		return &TransitionError{Component: component, Phase: phase, Err: err}
+	}
+	sm.trackComponent(component, phase != "close")
+	return nil
+}
+
+// callRecovered invokes fn, and returns an error if it panics, so
+// that a misbehaving subcomponent can't abort a transition midway.
+func callRecovered(component, phase string, fn func() error) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			log.Errorf("Subcomponent %s panicked on %s: %v\n%s", component, phase, x, tb.Stack(4))
+			err = fmt.Errorf("panic: %v", x)
+		}
+	}()
+	return fn()
+}
+
+// trackComponent records whether the component is open.
+func (sm *stateManager) trackComponent(component string, open bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for i, name := range sm.openNames {
+		if name == component {
+			if !open {
+				sm.openNames = append(sm.openNames[:i:i], sm.openNames[i+1:]...)
+			}
+			return
+		}
+	}
+	if open {
+		sm.openNames = append(sm.openNames, component)
+	}
+}
+
+// OpenComponents returns the names of the subcomponents that are
+// open, in the order in which they were opened. After a transition
+// that failed midway, these are the ones it left open.
+func (sm *stateManager) OpenComponents() []string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return append([]string(nil), sm.openNames...)
+}
+
+func (sm *stateManager) recordComponentTiming(name string, start time.Time) {
+	elapsed := sm.now().Sub(start)
+	if elapsed > sm.slowestComponentTime {
+		sm.slowestComponent, sm.slowestComponentTime = name, elapsed
+	}
+	if sm.componentTimings == nil {
+		return
+	}
+	sm.componentTimings.Add(name, elapsed)
+}
+
+// checkTransitionSLO counts and logs a transition that took longer
+// than transitionSLO. It only observes: the transition is unaffected.
+func (sm *stateManager) checkTransitionSLO(tabletType topodatapb.TabletType, state servingState, elapsed time.Duration) {
+	if sm.transitionSLO <= 0 || elapsed <= sm.transitionSLO {
+		return
+	}
+	sm.slowTransitions.Add(1)
+	log.Warningf("Transition to %v %v took %v, exceeding the SLO of %v. Slowest step: %s (%v)", tabletType, stateName[state], elapsed, sm.transitionSLO, sm.slowestComponent, sm.slowestComponentTime)
+}
+
+// SlowTransitions returns the number of transitions
+// that exceeded transitionSLO.
+func (sm *stateManager) SlowTransitions() int64 {
+	return sm.slowTransitions.Get()
 }
 
 func (sm *stateManager) setTimeBomb() chan struct{} {
@@ -470,47 +2505,474 @@ func (sm *stateManager) setTimeBomb() chan struct{} {
 		defer tmr.Stop()
 		select {
 		case <-tmr.C:
-			log.Fatal("Shutdown took too long. Crashing")
+			sm.mu.Lock()
+			handler := sm.timebombHandler
+			sm.mu.Unlock()
+			if handler != nil {
+				handler(sm.inFlight.Get())
+			}
+			sm.crash("Shutdown took too long. Crashing")
 		case <-done:
 		}
 	}()
 	return done
 }
 
+// SetTimebombHandler installs a function that's called with the number
+// of in-flight requests when the timebomb fires, e.g. to dump them.
+// The process is terminated once it returns, so it shouldn't block.
+// A nil handler removes the current one.
+func (sm *stateManager) SetTimebombHandler(handler func(inflight int64)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.timebombHandler = handler
+}
+
 // setState changes the state and logs the event.
 func (sm *stateManager) setState(tabletType topodatapb.TabletType, state servingState) {
+	if sm.planned != nil {
+		return
+	}
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	if tabletType == topodatapb.TabletType_UNKNOWN {
 		tabletType = sm.wantTabletType
 	}
-	log.Infof("TabletServer transition: %v -> %v, %s -> %s", sm.target.TabletType, tabletType, stateInfo(sm.state), stateInfo(state))
+	if ok, suppressed := sm.shouldLogTransitionLocked(sm.target.TabletType, tabletType, sm.state, state); ok {
+		suffix := ""
+		if suppressed > 0 {
+			suffix = fmt.Sprintf(" (%d identical transitions suppressed)", suppressed)
+		}
+		log.Infof("TabletServer transition: %v -> %v, %s -> %s%s", sm.target.TabletType, tabletType, stateInfo(sm.state), stateInfo(state), suffix)
+	}
 	sm.target.TabletType = tabletType
 	sm.state = state
+	sm.demoted = false
+	sm.enteredState = sm.now()
+	sm.updateStateTimerLocked()
 	sm.history.Add(&historyRecord{
 		Time:         time.Now(),
 		ServingState: stateInfo(state),
 		TabletType:   sm.target.TabletType.String(),
+		Reason:       sm.transitionReason,
 	})
 }
 
+// transitionLogKey identifies identical transitions for the rate
+// limiting of the transition logs.
+type transitionLogKey struct {
+	fromType, toType topodatapb.TabletType
+	from, to         servingState
+}
+
+// transitionLogEntry records when a transition was last logged, and
+// the number of identical transitions suppressed since.
+type transitionLogEntry struct {
+	logged     time.Time
+	suppressed int
+}
+
+// shouldLogTransitionLocked returns true if the transition should be
+// logged, along with the number of identical transitions that were
+// suppressed since it was last logged. A transition is suppressed if
+// an identical one was logged less than transitionLogInterval ago.
+// sm.mu must be held.
+func (sm *stateManager) shouldLogTransitionLocked(fromType, toType topodatapb.TabletType, from, to servingState) (ok bool, suppressed int) {
+	if sm.transitionLogInterval == 0 {
+		return true, 0
+	}
+	key := transitionLogKey{fromType: fromType, toType: toType, from: from, to: to}
+	now := sm.now()
+	entry := sm.transitionLogs[key]
+	if entry != nil && now.Sub(entry.logged) < sm.transitionLogInterval {
+		entry.suppressed++
+		return false, 0
+	}
+	if sm.transitionLogs == nil {
+		sm.transitionLogs = make(map[transitionLogKey]*transitionLogEntry)
+	}
+	if entry != nil {
+		suppressed = entry.suppressed
+	}
+	sm.transitionLogs[key] = &transitionLogEntry{logged: now}
+	return true, suppressed
+}
+
+// updateStateTimerLocked charges the time elapsed since the last change
+// to the previously timed state, and starts timing the current one.
+// Lameduck is accounted as StateNotServing. sm.mu must be held.
+func (sm *stateManager) updateStateTimerLocked() {
+	now := sm.now()
+	if d := now.Sub(sm.stateSince); !sm.stateSince.IsZero() && d > 0 {
+		if sm.stateDurations == nil {
+			sm.stateDurations = make(map[stateKey]time.Duration)
+		}
+		sm.stateDurations[sm.timedState] += d
+	}
+	state := sm.state
+	if state == StateServing && sm.lameduck.Get() != 0 {
+		state = StateNotServing
+	}
+	sm.timedState = stateKey{state: state, tabletType: sm.target.TabletType}
+	sm.stateSince = now
+}
+
+// StateDurations returns the cumulative time spent in each state, in
+// nanoseconds, keyed by state and tablet type. It includes the time
+// spent so far in the current state.
+func (sm *stateManager) StateDurations() map[string]int64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	durations := make(map[string]int64, len(sm.stateDurations)+1)
+	for key, d := range sm.stateDurations {
+		durations[key.label()] = d.Nanoseconds()
+	}
+	if d := sm.now().Sub(sm.stateSince); !sm.stateSince.IsZero() && d > 0 {
+		durations[sm.timedState.label()] += d.Nanoseconds()
+	}
+	return durations
+}
+
+// TimeInState returns the time elapsed since the last successful
+// transition into the current state, including the one done by
+// StopService. It returns 0 if no transition has happened yet.
+func (sm *stateManager) TimeInState() time.Duration {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.enteredState.IsZero() {
+		return 0
+	}
+	return sm.now().Sub(sm.enteredState)
+}
+
+// CurrentStateLabel returns the multi-label key of the state that is
+// currently being timed. Lameduck is reported as StateNotServing.
+func (sm *stateManager) CurrentStateLabel() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.timedState.label()
+}
+
+func (key stateKey) label() string {
+	return stateLabel[key.state] + "." + key.tabletType.String()
+}
+
 // EnterLameduck causes tabletserver to enter the lameduck state. This
 // state causes health checks to fail, but the behavior of tabletserver
 // otherwise remains the same. Any subsequent calls to SetServingType will
 // cause the tabletserver to exit this mode.
+// Listeners see this as a transition to StateNotServing.
 func (sm *stateManager) EnterLameduck() {
-	sm.lameduck.Set(1)
+	if !sm.lameduck.CompareAndSwap(0, 1) {
+		return
+	}
+	sm.mu.Lock()
+	state, tabletType := sm.state, sm.target.TabletType
+	sm.lameduckDeadline = sm.now().Add(sm.lameduckPeriodFor(tabletType))
+	sm.updateStateTimerLocked()
+	sm.mu.Unlock()
+	sm.notifyStateChange(state, StateNotServing, tabletType)
+	sm.notifyLameduck(true)
 }
 
 // ExitLameduck causes the tabletserver to exit the lameduck mode.
+// Listeners see this as a transition back to the current state.
 func (sm *stateManager) ExitLameduck() {
-	sm.lameduck.Set(0)
+	if !sm.lameduck.CompareAndSwap(1, 0) {
+		return
+	}
+	sm.mu.Lock()
+	state, tabletType := sm.state, sm.target.TabletType
+	sm.updateStateTimerLocked()
+	sm.mu.Unlock()
+	sm.notifyStateChange(StateNotServing, state, tabletType)
+	sm.notifyLameduck(false)
+}
+
+// OnLameduck registers fn to be called with entering set to true when
+// the tablet enters lameduck, and false when it exits it, e.g. on the
+// next SetServingType. Like state change listeners, fn is invoked
+// without holding any locks.
+func (sm *stateManager) OnLameduck(fn func(entering bool)) {
+	sm.listenersMu.Lock()
+	defer sm.listenersMu.Unlock()
+	sm.lameduckListeners = append(sm.lameduckListeners, fn)
+}
+
+// notifyLameduck invokes the lameduck listeners. It must be called
+// without holding sm.mu.
+func (sm *stateManager) notifyLameduck(entering bool) {
+	sm.listenersMu.Lock()
+	listeners := sm.lameduckListeners
+	sm.listenersMu.Unlock()
+
+	for _, fn := range listeners {
+		func() {
+			defer func() {
+				if x := recover(); x != nil {
+					log.Errorf("Lameduck listener panicked (entering: %v): %v", entering, x)
+				}
+			}()
+			fn(entering)
+		}()
+	}
+}
+
+// OnRequestRejected registers fn to be called whenever StartRequest
+// rejects a request. The reason is the error returned by StartRequest,
+// which matches one of the sentinels like ErrNotServing or
+// ErrInvalidKeyspace with errors.Is. Like state change listeners, fn
+// is invoked without holding any locks.
+func (sm *stateManager) OnRequestRejected(fn func(target *querypb.Target, reason error)) {
+	sm.listenersMu.Lock()
+	defer sm.listenersMu.Unlock()
+	sm.rejectionListeners = append(sm.rejectionListeners, fn)
+}
+
+// notifyRequestRejected invokes the rejection listeners. It must be
+// called without holding sm.mu.
+func (sm *stateManager) notifyRequestRejected(target *querypb.Target, reason error) {
+	sm.listenersMu.Lock()
+	listeners := sm.rejectionListeners
+	sm.listenersMu.Unlock()
+
+	for _, fn := range listeners {
+		func() {
+			defer func() {
+				if x := recover(); x != nil {
+					log.Errorf("Request rejection listener panicked (reason: %v): %v", reason, x)
+				}
+			}()
+			fn(target, reason)
+		}()
+	}
+}
+
+// lameduckPeriodFor returns the lameduck period for the tablet type.
+// It falls back to lameduckPeriod if there is no type-specific value.
+func (sm *stateManager) lameduckPeriodFor(tabletType topodatapb.TabletType) time.Duration {
+	if d, ok := sm.lameduckByType[tabletType]; ok {
+		return d
+	}
+	return sm.lameduckPeriod
+}
+
+// waitLameduck waits for the remainder of the lameduck period, if any.
+// This gives clients time to notice the lameduck state before the
+// tablet transitions away from it. It returns ctx.Err() if ctx is
+// done before the period expires.
+func (sm *stateManager) waitLameduck(ctx context.Context) error {
+	if sm.lameduck.Get() == 0 {
+		return nil
+	}
+	sm.mu.Lock()
+	remaining := sm.lameduckDeadline.Sub(sm.now())
+	sm.mu.Unlock()
+	if remaining <= 0 {
+		return nil
+	}
+	log.Infof("Waiting %v for lameduck period to expire", remaining)
+	tmr := time.NewTimer(remaining)
+	defer tmr.Stop()
+	select {
+	case <-tmr.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SubscribeStateChanges registers fn to be called after every
+// successful state transition, including those completed by retries.
+// Listeners are invoked in registration order, without holding any
+// locks. This allows them to call back into the stateManager. The
+// returned function unregisters the listener.
+func (sm *stateManager) SubscribeStateChanges(fn func(from, to servingState, tabletType topodatapb.TabletType)) (unsubscribe func()) {
+	l := &stateListener{fn: fn}
+	sm.listenersMu.Lock()
+	defer sm.listenersMu.Unlock()
+	sm.listeners = append(sm.listeners, l)
+
+	return func() {
+		sm.listenersMu.Lock()
+		defer sm.listenersMu.Unlock()
+		for i, cur := range sm.listeners {
+			if cur == l {
+				// Build a new slice so that a concurrent notify
+				// iterating over the old one is not affected.
+				listeners := make([]*stateListener, 0, len(sm.listeners)-1)
+				listeners = append(listeners, sm.listeners[:i]...)
+				sm.listeners = append(listeners, sm.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// WaitForState blocks until sm reaches the specified state, or ctx is
+// done. It's woken up by state change notifications.
+func (sm *stateManager) WaitForState(ctx context.Context, state servingState) error {
+	changed := make(chan struct{}, 1)
+	unsubscribe := sm.SubscribeStateChanges(func(from, to servingState, tabletType topodatapb.TabletType) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	for {
+		if sm.State() == state {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			current := stateName[sm.State()]
+			if sm.IsRetrying() {
+				current += " (retrying)"
+			}
+			return vterrors.Errorf(vtrpcpb.Code_DEADLINE_EXCEEDED, "gave up waiting for state %s, current state: %s: %v", stateName[state], current, ctx.Err())
+		}
+	}
+}
+
+// notifyStateChange invokes all the listeners. It must be called
+// without holding sm.mu.
+func (sm *stateManager) notifyStateChange(from, to servingState, tabletType topodatapb.TabletType) {
+	sm.listenersMu.Lock()
+	listeners := sm.listeners
+	sm.listenersMu.Unlock()
+
+	for _, l := range listeners {
+		sm.invokeListener(l, from, to, tabletType)
+	}
+}
+
+// invokeListener calls the listener and recovers from a panic
+// so that a misbehaving listener cannot corrupt the state.
+func (sm *stateManager) invokeListener(l *stateListener, from, to servingState, tabletType topodatapb.TabletType) {
+	defer func() {
+		if x := recover(); x != nil {
+			log.Errorf("State change listener panicked for %v -> %v: %v", stateInfo(from), stateInfo(to), x)
+		}
+	}()
+	l.fn(from, to, tabletType)
 }
 
 // IsServing returns true if TabletServer is in SERVING state.
 func (sm *stateManager) IsServing() bool {
-	return sm.StateByName() == "SERVING"
+	return sm.State() == StateServing && sm.lameduck.Get() == 0
+}
+
+// IsRetrying returns true if a failed transition is being retried
+// until the state converges to the requested one.
+func (sm *stateManager) IsRetrying() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.retrying
+}
+
+// IsReady returns true if the tablet is serving and expected to stay
+// serving: there is no transition in progress or being retried, and
+// the tablet is not in lameduck mode. If not ready, it also returns
+// the reason.
+func (sm *stateManager) IsReady() (bool, string) {
+	if sm.lameduck.Get() != 0 {
+		return false, "in lameduck mode"
+	}
+	if sm.transitioning.Size() == 0 {
+		return false, "state transition in progress"
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	switch {
+	case sm.retrying:
+		return false, fmt.Sprintf("retrying transition to %v %s", sm.wantTabletType, stateName[sm.wantState])
+	case sm.wantTabletType == topodatapb.TabletType_RESTORE:
+		return false, "restoring"
+	case sm.wantState != StateServing:
+		return false, fmt.Sprintf("requested state is %s", stateInfo(sm.wantState))
+	case sm.state != StateServing:
+		return false, fmt.Sprintf("state is %s", stateInfo(sm.state))
+	}
+	return true, ""
+}
+
+// StateSnapshot is a consistent view of the state of a stateManager.
+// ReadOnly is set while a master is demoted by DemoteToReadOnly.
+type StateSnapshot struct {
+	State          servingState
+	WantState      servingState
+	WantTabletType topodatapb.TabletType
+	Target         querypb.Target
+	AlsoAllow      []topodatapb.TabletType
+	Lameduck       bool
+	Retrying       bool
+	ReadOnly       bool
+	Maintenance    bool
+}
+
+// Snapshot returns the current state, the requested state and the
+// target, read together under sm.mu.
+func (sm *stateManager) Snapshot() StateSnapshot {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	alsoAllow := make([]topodatapb.TabletType, len(sm.alsoAllow))
+	copy(alsoAllow, sm.alsoAllow)
+	return StateSnapshot{
+		State:          sm.state,
+		WantState:      sm.wantState,
+		WantTabletType: sm.wantTabletType,
+		Target:         sm.target,
+		AlsoAllow:      alsoAllow,
+		Lameduck:       sm.lameduck.Get() != 0,
+		Retrying:       sm.retrying,
+		ReadOnly:       sm.demoted,
+		Maintenance:    sm.maintenance,
+	}
+}
+
+// HealthStatus combines the signals that describe the health of
+// a tablet. LastMySQLReachable is the zero time if mysql was never
+// found reachable. ReplicationLag is only set for a non-master with
+// a replica lag gate, and ReplicationLagKnown reports whether the
+// gate knew the lag.
+type HealthStatus struct {
+	State               servingState
+	TabletType          topodatapb.TabletType
+	Lameduck            bool
+	InFlight            int64
+	LastMySQLReachable  time.Time
+	ReplicationLag      time.Duration
+	ReplicationLagKnown bool
+}
+
+// Health returns the current HealthStatus, read together under sm.mu.
+// The replication lag is read from the gate after sm.mu is released.
+func (sm *stateManager) Health() HealthStatus {
+	sm.mu.Lock()
+	health := HealthStatus{
+		State:      sm.state,
+		TabletType: sm.target.TabletType,
+		Lameduck:   sm.lameduck.Get() != 0,
+		InFlight:   sm.inFlight.Get(),
+	}
+	if nanos := sm.mysqlLastReachable.Get(); nanos != 0 {
+		health.LastMySQLReachable = time.Unix(0, nanos)
+	}
+	var gate func() (time.Duration, bool)
+	if sm.target.TabletType != topodatapb.TabletType_MASTER {
+		gate = sm.replicaLagGate
+	}
+	sm.mu.Unlock()
+	if gate != nil {
+		health.ReplicationLag, health.ReplicationLagKnown = gate()
+	}
+	return health
 }
 
 func (sm *stateManager) State() servingState {
@@ -534,6 +2996,23 @@ func (sm *stateManager) StateByName() string {
 	return stateName[sm.State()]
 }
 
+// IsDegraded returns true if the tablet is serving as a non-master,
+// and its replication lag exceeds degradedReplicaLag or is unknown.
+// A degraded tablet remains in StateServing and still allows queries.
+func (sm *stateManager) IsDegraded() bool {
+	sm.mu.Lock()
+	gate, maxLag := sm.replicaLagGate, sm.degradedReplicaLag
+	if sm.state != StateServing || sm.target.TabletType == topodatapb.TabletType_MASTER {
+		gate = nil
+	}
+	sm.mu.Unlock()
+	if gate == nil || maxLag == 0 {
+		return false
+	}
+	lag, ok := gate()
+	return !ok || lag > maxLag
+}
+
 // stateInfo returns a string representation of the state and optional detail
 // about the reason for the state transition
 func stateInfo(state servingState) string {
@@ -17,17 +17,26 @@ limitations under the License.
 package tabletserver
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"math/rand"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"vitess.io/vitess/go/event"
 	"vitess.io/vitess/go/history"
+	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sync2"
+	"vitess.io/vitess/go/vt/log"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
 )
 
@@ -43,7 +52,7 @@ func TestStateManagerStateByName(t *testing.T) {
 		"NOT_SERVING",
 		"SERVING",
 	}
-	sm := &stateManager{}
+	sm := &stateManager{now: time.Now}
 	for i, state := range states {
 		sm.state = state
 		require.Equal(t, names[i], sm.StateByName(), "StateByName")
@@ -178,6 +187,94 @@ func TestStateManagerClose(t *testing.T) {
 	assert.Equal(t, StateNotConnected, sm.state)
 }
 
+func TestStateManagerKeepsVStreamerOnDrain(t *testing.T) {
+	sm := newTestStateManager(t)
+	vs := &testClosesSubcomponent{}
+	sm.vstreamer = vs
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, testStateOpen, vs.State())
+	assert.Equal(t, 0, vs.closes)
+
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateNotConnected, nil)
+	require.NoError(t, err)
+	assert.Equal(t, testStateClosed, vs.State())
+	assert.Equal(t, 1, vs.closes)
+}
+
+func TestStateManagerRegisterComponent(t *testing.T) {
+	sm := newTestStateManager(t)
+	custom := &testSubcomponent{}
+	sm.registerComponent(stateComponent{
+		name:       "custom",
+		openOrder:  25,
+		closeOrder: 45,
+		open:       func() error { custom.Open(); return nil },
+		close:      custom.Close,
+	})
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	verifySubcomponent(t, 4, sm.se, testStateOpen)
+	verifySubcomponent(t, 5, sm.vstreamer, testStateOpen)
+	verifySubcomponent(t, 6, custom, testStateOpen)
+	verifySubcomponent(t, 7, sm.qe, testStateOpen)
+	verifySubcomponent(t, 8, sm.txThrottler, testStateOpen)
+
+	order.Set(0)
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotConnected, nil)
+	require.NoError(t, err)
+	verifySubcomponent(t, 3, sm.txThrottler, testStateClosed)
+	verifySubcomponent(t, 4, sm.qe, testStateClosed)
+	verifySubcomponent(t, 5, sm.watcher, testStateClosed)
+	verifySubcomponent(t, 6, sm.tracker, testStateClosed)
+	verifySubcomponent(t, 7, custom, testStateClosed)
+	verifySubcomponent(t, 8, sm.vstreamer, testStateClosed)
+	verifySubcomponent(t, 9, sm.hr, testStateClosed)
+	verifySubcomponent(t, 10, sm.hw, testStateClosed)
+	verifySubcomponent(t, 11, sm.se, testStateClosed)
+}
+
+func TestStateManagerClosePanic(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	sm.registerComponent(stateComponent{
+		name:       "custom",
+		closeOrder: 45,
+		close:      func() { panic("custom close") },
+	})
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+
+	// The panic doesn't stop the other components from closing.
+	order.Set(0)
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotConnected, nil)
+	assert.True(t, stateChanged)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "custom close: panic: custom close")
+	verifySubcomponent(t, 3, sm.txThrottler, testStateClosed)
+	verifySubcomponent(t, 4, sm.qe, testStateClosed)
+	verifySubcomponent(t, 5, sm.watcher, testStateClosed)
+	verifySubcomponent(t, 6, sm.tracker, testStateClosed)
+	verifySubcomponent(t, 7, sm.vstreamer, testStateClosed)
+	verifySubcomponent(t, 8, sm.hr, testStateClosed)
+	verifySubcomponent(t, 9, sm.hw, testStateClosed)
+	verifySubcomponent(t, 10, sm.se, testStateClosed)
+	assert.Equal(t, StateNotConnected, sm.State())
+
+	// The state converged, so there's nothing to retry.
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StateNotConnected, sm.State())
+}
+
 func TestStateManagerStopService(t *testing.T) {
 	sm := newTestStateManager(t)
 	stateChanged, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
@@ -192,6 +289,69 @@ func TestStateManagerStopService(t *testing.T) {
 	assert.Equal(t, StateNotConnected, sm.state)
 }
 
+func TestStateManagerStopServiceTwice(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+
+	order.Set(0)
+	sm.StopService()
+	assert.Equal(t, StateNotConnected, sm.State())
+	verifySubcomponent(t, 10, sm.se, testStateClosed)
+	assert.Equal(t, int64(10), order.Get())
+	assert.Equal(t, int64(0), sm.RedundantStops())
+
+	// The second call doesn't close anything.
+	sm.StopService()
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.Equal(t, int64(10), order.Get())
+	assert.Equal(t, int64(1), sm.RedundantStops())
+}
+
+func TestStateManagerReset(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+	sm.EndRequest()
+	sm.EnterMaintenance("upgrading")
+	sm.EnterLameduck()
+	sm.redundantStops.Add(1)
+	sm.slowTransitions.Add(1)
+
+	order.Set(0)
+	require.NoError(t, sm.Reset())
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.Equal(t, StateNotConnected, sm.wantState)
+	verifySubcomponent(t, 10, sm.se, testStateClosed)
+	assert.Empty(t, sm.OpenComponents())
+	assert.False(t, sm.maintenance)
+	assert.Equal(t, int32(0), sm.lameduck.Get())
+	assert.Equal(t, int64(0), sm.RedundantStops())
+	assert.Equal(t, int64(0), sm.SlowTransitions())
+	assert.Empty(t, sm.RequestCounts())
+	assert.Empty(t, sm.StateDurations())
+
+	// sm can be reused.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StateServing, sm.State())
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+
+	// Reset is refused while a request is in flight.
+	err = sm.Reset()
+	assert.EqualError(t, err, "cannot reset with 1 requests in flight")
+	sm.EndRequest()
+
+	// It's also refused while a transition is in progress.
+	sm.transitioning.Acquire()
+	err = sm.Reset()
+	assert.True(t, errors.Is(err, ErrTransitionBusy), "%v", err)
+	assert.Equal(t, StateServing, sm.State())
+	sm.transitioning.Release()
+}
+
 // testWatcher is used as a hook to invoke another transition
 type testWatcher struct {
 	t  *testing.T
@@ -232,218 +392,2544 @@ func TestStateManagerSetServingTypeRace(t *testing.T) {
 	assert.Equal(t, StateNotServing, sm.state)
 }
 
-func TestStateManagerSetServingTypeNoChange(t *testing.T) {
+func TestStateManagerMaxTransitionWait(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.maxTransitionWait = 10 * time.Millisecond
+
+	// Hold the semaphore as if another transition were in progress.
+	sm.transitioning.Acquire()
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	assert.True(t, errors.Is(err, ErrTransitionBusy), "%v", err)
+	assert.Equal(t, vtrpcpb.Code_UNAVAILABLE, vterrors.Code(err))
+	assert.Equal(t, StateNotConnected, sm.State())
+
+	// A done context takes precedence over the busy error.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sm.SetServingTypeContext(ctx, topodatapb.TabletType_MASTER, StateServing, nil)
+	assert.Equal(t, context.Canceled, err)
+
+	// Once the semaphore is released, the transition goes through.
+	sm.transitioning.Release()
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
+}
+
+func TestStateManagerTrySetServingType(t *testing.T) {
+	sm := newTestStateManager(t)
+
+	// Hold the semaphore as if another transition were in progress.
+	sm.transitioning.Acquire()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stateChanged, busy, err := sm.TrySetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+		assert.NoError(t, err)
+		assert.True(t, busy)
+		assert.False(t, stateChanged)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("TrySetServingType blocked on a transition in progress")
+	}
+	assert.Equal(t, StateNotConnected, sm.State())
+	sm.transitioning.Release()
+
+	stateChanged, busy, err := sm.TrySetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.False(t, busy)
+	assert.True(t, stateChanged)
+	assert.Equal(t, StateServing, sm.State())
+	assert.False(t, sm.isTransitioning())
+
+	// No transition needed.
+	stateChanged, busy, err = sm.TrySetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.False(t, busy)
+	assert.False(t, stateChanged)
+
+	// Frozen transitions are reported as busy too.
+	sm.Freeze()
+	_, busy, err = sm.TrySetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, busy)
+	sm.Unfreeze()
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
+}
+
+func TestStateManagerFreeze(t *testing.T) {
+	sm := newTestStateManager(t)
+	var got []topodatapb.TabletType
+	sm.SubscribeStateChanges(func(from, to servingState, tabletType topodatapb.TabletType) {
+		got = append(got, tabletType)
+	})
+	queued := func() int {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		return len(sm.frozenQueue)
+	}
+
+	sm.Freeze()
+	first := make(chan error, 1)
+	go func() {
+		_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+		first <- err
+	}()
+	for queued() != 1 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	second := make(chan error, 1)
+	go func() {
+		_, err := sm.SetServingType(topodatapb.TabletType_RDONLY, StateServing, nil)
+		second <- err
+	}()
+	for queued() != 2 {
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	// A call whose context is done leaves the queue.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := sm.SetServingTypeContext(ctx, topodatapb.TabletType_MASTER, StateServing, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 2, queued())
+
+	select {
+	case err := <-first:
+		t.Fatalf("SetServingType returned while frozen: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.Equal(t, StateNotConnected, sm.State())
+
+	sm.Unfreeze()
+	require.NoError(t, <-first)
+	require.NoError(t, <-second)
+	assert.Equal(t, []topodatapb.TabletType{topodatapb.TabletType_REPLICA, topodatapb.TabletType_RDONLY}, got)
+	assert.Equal(t, topodatapb.TabletType_RDONLY, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+
+	// Once unfrozen, calls proceed right away.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StateNotServing, sm.State())
+}
+
+func TestStateManagerSetServingTypeNoChange(t *testing.T) {
+	sm := newTestStateManager(t)
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+
+	stateChanged, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.False(t, stateChanged)
+
+	verifySubcomponent(t, 1, sm.messager, testStateClosed)
+	verifySubcomponent(t, 2, sm.tracker, testStateClosed)
+	verifySubcomponent(t, 3, sm.hw, testStateClosed)
+	assert.True(t, sm.se.(*testSchemaEngine).nonMaster)
+
+	verifySubcomponent(t, 4, sm.se, testStateOpen)
+	verifySubcomponent(t, 5, sm.vstreamer, testStateOpen)
+	verifySubcomponent(t, 6, sm.qe, testStateOpen)
+	verifySubcomponent(t, 7, sm.txThrottler, testStateOpen)
+	verifySubcomponent(t, 8, sm.te, testStateAcceptReadOnly)
+	verifySubcomponent(t, 9, sm.hr, testStateOpen)
+	verifySubcomponent(t, 10, sm.watcher, testStateOpen)
+
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.target.TabletType)
+	assert.Equal(t, StateServing, sm.state)
+}
+
+func TestStateManagerTransitionGuard(t *testing.T) {
+	sm := newTestStateManager(t)
+	errNoMaster := errors.New("no promotion during maintenance")
+	var calls []topodatapb.TabletType
+	sm.SetTransitionGuard(func(tabletType topodatapb.TabletType, state servingState) error {
+		calls = append(calls, tabletType)
+		if tabletType == topodatapb.TabletType_MASTER {
+			return errNoMaster
+		}
+		return nil
+	})
+
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+
+	order.Set(0)
+	stateChanged, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	assert.Equal(t, errNoMaster, err)
+	assert.False(t, stateChanged)
+	assert.Equal(t, int64(0), order.Get())
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.target.TabletType)
+	assert.Equal(t, StateServing, sm.state)
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.wantTabletType)
+	assert.False(t, sm.isTransitioning())
+
+	// The guard is not consulted if there's nothing to do.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []topodatapb.TabletType{topodatapb.TabletType_REPLICA, topodatapb.TabletType_MASTER}, calls)
+
+	sm.SetTransitionGuard(nil)
+	stateChanged, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.target.TabletType)
+}
+
+func TestStateManagerTransitionFailRetry(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	sm.qe.(*testQueryEngine).failMySQL = true
+
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	assert.True(t, stateChanged)
+
+	// Calling retryTransition while retrying should be a no-op.
+	sm.retryTransition("")
+
+	// Steal the lock and wait long enough for the retry
+	// to fail, and then release it. The retry will have
+	// to keep retrying.
+	sm.transitioning.Acquire()
+	time.Sleep(30 * time.Millisecond)
+	sm.transitioning.Release()
+
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+}
+
+func TestStateManagerTransitionRetryIntervalPerInstance(t *testing.T) {
+	fast := newTestStateManager(t)
+	fast.transitionRetryInterval = 10 * time.Millisecond
+	fast.qe.(*testQueryEngine).failMySQL = true
+	slow := newTestStateManager(t)
+	slow.transitionRetryInterval = 500 * time.Millisecond
+	slow.qe.(*testQueryEngine).failMySQL = true
+
+	_, err := fast.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	_, err = slow.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+
+	for fast.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StateServing, fast.State())
+	// The slow manager is still waiting for its first retry.
+	assert.True(t, slow.IsRetrying())
+	assert.NotEqual(t, StateServing, slow.State())
+
+	for slow.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StateServing, slow.State())
+}
+
+func TestStateManagerTransitionError(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	defer sm.StopService()
+	sm.qe.(*testQueryEngine).failOpen = true
+
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	assert.True(t, stateChanged)
+	assert.EqualError(t, err, "query_engine open: intentional open error")
+
+	var terr *TransitionError
+	require.True(t, errors.As(err, &terr))
+	assert.Equal(t, "query_engine", terr.Component)
+	assert.Equal(t, "open", terr.Phase)
+
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStateManagerOpenComponents(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	assert.Empty(t, sm.OpenComponents())
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	want := []string{"schema_engine", "vstreamer", "query_engine", "tx_throttler", "tx_engine", "heartbeat_reader", "replication_watcher"}
+	assert.Equal(t, want, sm.OpenComponents())
+
+	sm.StopService()
+	assert.Empty(t, sm.OpenComponents())
+
+	// A transition that fails midway leaves the components opened
+	// before the failure open, including while it's retried.
+	var fail sync2.AtomicBool
+	fail.Set(true)
+	sm.registerComponent(stateComponent{
+		name:       "custom",
+		openOrder:  25,
+		closeOrder: 45,
+		open: func() error {
+			if fail.Get() {
+				return errors.New("intentional open error")
+			}
+			return nil
+		},
+		close: func() {},
+	})
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.Error(t, err)
+	assert.Equal(t, []string{"schema_engine", "vstreamer"}, sm.OpenComponents())
+
+	fail.Set(false)
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	want = []string{"schema_engine", "vstreamer", "custom", "query_engine", "tx_throttler", "tx_engine", "heartbeat_reader", "replication_watcher"}
+	assert.Equal(t, want, sm.OpenComponents())
+}
+
+func TestStateManagerForceNotServing(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+
+	// Make mysql unreachable so that the transition keeps retrying.
+	sm.SetMySQLProbe(func(ctx context.Context) error {
+		return errors.New("mysql is down")
+	})
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, sm.IsRetrying())
+
+	sm.ForceNotServing("mysql is dead")
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.Target().TabletType)
+	assert.Equal(t, StateNotServing, sm.State())
+	assert.Equal(t, "mysql is dead", sm.TransitionHistory()[0].Reason)
+
+	// Forcing again doesn't change the state, and isn't recorded.
+	history := len(sm.TransitionHistory())
+	sm.ForceNotServing("mysql is still dead")
+	assert.Equal(t, StateNotServing, sm.State())
+	assert.Len(t, sm.TransitionHistory(), history)
+
+	// The retry must stop.
+	failures := sm.MySQLProbeFailures()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, failures, sm.MySQLProbeFailures())
+	assert.False(t, sm.IsRetrying())
+	assert.False(t, sm.isTransitioning())
+
+	// Requests to serve are pinned to not serving.
+	sm.SetMySQLProbe(nil)
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
+	assert.Equal(t, StateNotServing, sm.State())
+
+	stateChanged, err := sm.ClearForcedNotServing()
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+
+	stateChanged, err = sm.ClearForcedNotServing()
+	require.NoError(t, err)
+	assert.False(t, stateChanged)
+}
+
+func TestStateManagerComponentTimings(t *testing.T) {
+	sm := newTestStateManager(t)
+	fc := newFakeClock()
+	sm.now = fc.Now
+	timings := &testDurationRecorder{}
+	sm.componentTimings = timings
+	sm.se = &testClockSchemaEngine{fc: fc, open: 20 * time.Millisecond, close: 5 * time.Millisecond}
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{20 * time.Millisecond}, timings.get("schema_engine_open"))
+	assert.Equal(t, []time.Duration{0}, timings.get("query_engine_open"))
+	assert.Equal(t, []time.Duration{0}, timings.get("replication_watcher_open"))
+
+	sm.StopService()
+	assert.Equal(t, []time.Duration{5 * time.Millisecond}, timings.get("schema_engine_close"))
+	assert.Equal(t, []time.Duration{0}, timings.get("query_engine_close"))
+	assert.Nil(t, timings.get("heartbeat_writer_open"))
+}
+
+func TestStateManagerTransitionMetricsSink(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	fc := newFakeClock()
+	sm.now = fc.Now
+	sm.se = &testClockSchemaEngine{fc: fc, open: 20 * time.Millisecond, close: 5 * time.Millisecond}
+	sink := &testTransitionSink{}
+	sm.SetTransitionMetricsSink(sink)
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []testTransitionObservation{
+		{from: StateNotConnected, to: StateServing, d: 20 * time.Millisecond},
+	}, sink.reset())
+
+	// Failed transitions and their retries are observed too.
+	sm.qe.(*testQueryEngine).failMySQL = true
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	sm.qe.(*testQueryEngine).failMySQL = false
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := sink.reset()
+	require.GreaterOrEqual(t, len(got), 2)
+	assert.Equal(t, StateServing, got[0].from)
+	assert.Equal(t, StateServing, got[0].to)
+	assert.Error(t, got[0].err)
+	assert.NoError(t, got[len(got)-1].err)
+
+	// A nil sink disables the observations.
+	sm.SetTransitionMetricsSink(nil)
+	sm.StopService()
+	assert.Nil(t, sink.reset())
+}
+
+func TestStateManagerPlanTransition(t *testing.T) {
+	sm := newTestStateManager(t)
+	timings := &testDurationRecorder{}
+	sm.componentTimings = timings
+
+	plan := sm.PlanTransition(topodatapb.TabletType_MASTER, StateServing)
+	// Planning doesn't change anything.
+	assert.Nil(t, timings.reset())
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.Equal(t, int64(0), order.Get())
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	want := []string{
+		"replication_watcher_close",
+		"heartbeat_reader_close",
+		"schema_engine_open",
+		"vstreamer_open",
+		"query_engine_open",
+		"tx_throttler_open",
+		"heartbeat_writer_open",
+		"tracker_open",
+		"tx_engine_accept_read_write",
+		"messager_open",
+	}
+	assert.Equal(t, want, plan)
+	assert.Equal(t, plan, timings.reset())
+
+	plan = sm.PlanTransition(topodatapb.TabletType_RDONLY, StateNotServing)
+	assert.Contains(t, plan, "tx_engine_close")
+	assert.Nil(t, timings.reset())
+	assert.Equal(t, StateServing, sm.State())
+	_, err = sm.SetServingType(topodatapb.TabletType_RDONLY, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, timings.reset(), plan)
+
+	// No transition is needed.
+	assert.Empty(t, sm.PlanTransition(topodatapb.TabletType_RDONLY, StateNotServing))
+}
+
+func TestStateManagerTransitionSLO(t *testing.T) {
+	sm := newTestStateManager(t)
+	fc := newFakeClock()
+	sm.now = fc.Now
+	sm.transitionSLO = 50 * time.Millisecond
+	sm.se = &testClockSchemaEngine{fc: fc, open: 20 * time.Millisecond, close: 60 * time.Millisecond}
+
+	// Within the SLO.
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), sm.SlowTransitions())
+
+	// The slow close exceeds the SLO, which doesn't fail the transition.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotConnected, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), sm.SlowTransitions())
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.Equal(t, "schema_engine_close", sm.slowestComponent)
+	assert.Equal(t, 60*time.Millisecond, sm.slowestComponentTime)
+
+	// A zero SLO disables the check.
+	sm.transitionSLO = 0
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotConnected, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), sm.SlowTransitions())
+}
+
+func TestStateManagerCompareAndSetServingType(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, []topodatapb.TabletType{topodatapb.TabletType_RDONLY})
+	require.NoError(t, err)
+	count := sm.TransitionCount()
+
+	// A stale expected state leaves everything unchanged.
+	swapped, err := sm.CompareAndSetServingType(StateNotServing, topodatapb.TabletType_REPLICA, StateNotConnected)
+	require.NoError(t, err)
+	assert.False(t, swapped)
+	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, count, sm.TransitionCount())
+	snap := sm.Snapshot()
+	assert.Equal(t, StateServing, snap.WantState)
+
+	swapped, err = sm.CompareAndSetServingType(StateServing, topodatapb.TabletType_REPLICA, StateNotServing)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+	assert.Equal(t, StateNotServing, sm.State())
+	assert.Equal(t, count+1, sm.TransitionCount())
+	// The allowed types are retained.
+	assert.True(t, sm.IsTypeAllowed(topodatapb.TabletType_RDONLY))
+
+	// The previous expected state is now stale.
+	swapped, err = sm.CompareAndSetServingType(StateServing, topodatapb.TabletType_REPLICA, StateServing)
+	require.NoError(t, err)
+	assert.False(t, swapped)
+	assert.Equal(t, StateNotServing, sm.State())
+
+	// A match that needs no transition is still a swap.
+	swapped, err = sm.CompareAndSetServingType(StateNotServing, topodatapb.TabletType_REPLICA, StateNotServing)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+	assert.Equal(t, count+1, sm.TransitionCount())
+}
+
+func TestStateManagerTransitionCount(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	assert.Equal(t, int64(0), sm.TransitionCount())
+	assert.Equal(t, int64(0), sm.FailedTransitionCount())
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	// A no-op request isn't a transition.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), sm.TransitionCount())
+	assert.Equal(t, int64(0), sm.FailedTransitionCount())
+
+	// The failed transition is retried until it succeeds.
+	sm.qe.(*testQueryEngine).failMySQL = true
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, int64(3), sm.TransitionCount())
+	assert.Equal(t, int64(1), sm.FailedTransitionCount())
+
+	// Neither are calls that leave the state unchanged.
+	require.NoError(t, sm.DemoteToReadOnly())
+	require.NoError(t, sm.PromoteToReadWrite())
+	assert.Equal(t, int64(3), sm.TransitionCount())
+	sm.ForceNotServing("test")
+	sm.ForceNotServing("test")
+	assert.Equal(t, int64(4), sm.TransitionCount())
+	assert.Equal(t, int64(1), sm.FailedTransitionCount())
+
+	require.NoError(t, sm.Reset())
+	assert.Equal(t, int64(0), sm.TransitionCount())
+	assert.Equal(t, int64(0), sm.FailedTransitionCount())
+}
+
+func TestStateManagerTransitionWait(t *testing.T) {
+	sm := newTestStateManager(t)
+	waits := &testDurationRecorder{}
+	sm.waitTimings = waits
+
+	// An uncontended transition barely waits.
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Less(t, int64(sm.TransitionHistory()[0].Wait), int64(10*time.Millisecond))
+
+	sm.transitioning.Acquire()
+	done := make(chan error)
+	go func() {
+		_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	sm.transitioning.Release()
+	require.NoError(t, <-done)
+
+	record := sm.TransitionHistory()[0]
+	assert.Equal(t, "NotServing", record.To)
+	assert.GreaterOrEqual(t, int64(record.Wait), int64(20*time.Millisecond))
+	// The wait isn't part of the duration of the transition.
+	assert.Less(t, int64(record.Duration), int64(record.Wait))
+
+	waits.mu.Lock()
+	defer waits.mu.Unlock()
+	require.Len(t, waits.durations["StateTransition"], 2)
+	assert.Equal(t, record.Wait, waits.durations["StateTransition"][1])
+}
+
+func TestStateManagerRetryBackoff(t *testing.T) {
+	// A zero policy retains the fixed interval.
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	assert.Equal(t, 10*time.Millisecond, sm.retryDelay(0, nil))
+	assert.Equal(t, 10*time.Millisecond, sm.retryDelay(5, nil))
+
+	bp := backoffPolicy{}
+	assert.Equal(t, time.Duration(0), bp.interval(0, nil))
+
+	bp = backoffPolicy{
+		initial:    10 * time.Millisecond,
+		max:        50 * time.Millisecond,
+		multiplier: 2,
+	}
+	var got []time.Duration
+	for attempt := 0; attempt < 5; attempt++ {
+		got = append(got, bp.interval(attempt, nil))
+	}
+	want := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		50 * time.Millisecond,
+		50 * time.Millisecond,
+	}
+	assert.Equal(t, want, got)
+
+	// Jitter stays within bounds, including at the cap.
+	bp.jitter = 0.5
+	r := rand.New(rand.NewSource(1))
+	for attempt := 0; attempt < 20; attempt++ {
+		d := bp.interval(attempt, r.Float64)
+		assert.GreaterOrEqual(t, int64(d), int64(5*time.Millisecond))
+		assert.LessOrEqual(t, int64(d), int64(75*time.Millisecond))
+	}
+
+	// Extremes of the random source.
+	bp = backoffPolicy{initial: 100 * time.Millisecond, jitter: 0.2}
+	assert.Equal(t, 80*time.Millisecond, bp.interval(0, func() float64 { return 0 }))
+	assert.Equal(t, 120*time.Millisecond, bp.interval(0, func() float64 { return 1 }))
+}
+
+func TestStateManagerVerifyReadOnly(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	sm.verifyReadOnly = true
+	// mysql ignores the first two requests to go read-only.
+	var probes sync2.AtomicInt64
+	sm.SetReadOnlyProbe(func(ctx context.Context) (bool, error) {
+		return probes.Add(1) > 2, nil
+	})
+
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mysql is not read-only")
+	assert.True(t, stateChanged)
+
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int64(3), probes.Get())
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+
+	// Masters are not checked.
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), probes.Get())
+
+	// Without verifyReadOnly, the probe is not consulted.
+	sm.verifyReadOnly = false
+	_, err = sm.SetServingType(topodatapb.TabletType_RDONLY, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), probes.Get())
+}
+
+func TestStateManagerTransitionFailRetryBackoff(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.retryBackoff = backoffPolicy{initial: 5 * time.Millisecond, max: 10 * time.Millisecond, multiplier: 2}
+	sm.qe.(*testQueryEngine).failMySQL = true
+
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+
+	for sm.IsRetrying() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, StateServing, sm.State())
+}
+
+func TestStateManagerLastError(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.retryBackoff = backoffPolicy{initial: 100 * time.Millisecond}
+	fc := newFakeClock()
+	sm.now = fc.Now
+	err, at := sm.LastError()
+	assert.NoError(t, err)
+	assert.True(t, at.IsZero())
+
+	sm.qe.(*testQueryEngine).failMySQL = true
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	lastErr, at := sm.LastError()
+	var terr *TransitionError
+	require.True(t, errors.As(lastErr, &terr), "%v", lastErr)
+	assert.Equal(t, "mysql", terr.Component)
+	assert.Equal(t, fc.Now(), at)
+
+	// The retry succeeds, and clears the error.
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StateServing, sm.State())
+	err, at = sm.LastError()
+	assert.NoError(t, err)
+	assert.True(t, at.IsZero())
+}
+
+func TestStateManagerTransitionHistory(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	sm.qe.(*testQueryEngine).failMySQL = true
+
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	history := sm.TransitionHistory()
+	require.Len(t, history, 2)
+	// Most recent first.
+	assert.Equal(t, "NotConnected", history[0].From)
+	assert.Equal(t, "Serving", history[0].To)
+	assert.Equal(t, "MASTER", history[0].TabletType)
+	assert.Equal(t, 1, history[0].Retries)
+	assert.Equal(t, "", history[0].Error)
+	assert.Equal(t, 0, history[1].Retries)
+	assert.Equal(t, "mysql health_check: intentional error", history[1].Error)
+
+	// Mutating the returned copy must not affect the history.
+	history[0].Error = "changed"
+	assert.Equal(t, "", sm.TransitionHistory()[0].Error)
+
+	history[1].Time = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	history[1].Duration = 2 * time.Millisecond
+	history[1].Wait = time.Millisecond
+	b, err := json.Marshal(history[1])
+	require.NoError(t, err)
+	want := `{"time":"2020-01-01T00:00:00Z","from":"NotConnected","to":"Serving","tabletType":"MASTER","duration":2000000,"retries":0,"error":"mysql health_check: intentional error","wait":1000000}`
+	assert.Equal(t, want, string(b))
+}
+
+func TestStateManagerLogTransitionsJSON(t *testing.T) {
+	sm := newTestStateManager(t)
+	// Keep the retry of the failed transition from logging.
+	sm.transitionRetryInterval = time.Hour
+	clock := newFakeClock()
+	sm.now = clock.Now
+	sm.se = &testClockSchemaEngine{fc: clock, open: 20 * time.Millisecond}
+	tl := newTestLogger()
+	defer tl.Close()
+	jsonLines := func() []map[string]interface{} {
+		var records []map[string]interface{}
+		for _, line := range tl.logs {
+			if !strings.HasPrefix(line, "{") {
+				continue
+			}
+			record := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+			records = append(records, record)
+		}
+		return records
+	}
+
+	// Transitions aren't logged as JSON by default.
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Empty(t, jsonLines())
+
+	sm.logTransitionsJSON = true
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	sm.qe.(*testQueryEngine).failMySQL = true
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+
+	assert.Equal(t, []map[string]interface{}{{
+		"time":       "2020-01-01T00:00:00.02Z",
+		"from":       "NotServing",
+		"to":         "Serving",
+		"tabletType": "REPLICA",
+		"duration":   float64(20 * time.Millisecond),
+		"retries":    float64(0),
+	}, {
+		"time":       "2020-01-01T00:00:00.04Z",
+		"from":       "Serving",
+		"to":         "Serving",
+		"tabletType": "MASTER",
+		"duration":   float64(0),
+		"retries":    float64(0),
+		"error":      "mysql health_check: intentional error",
+	}}, jsonLines())
+}
+
+func TestStateManagerTransitionLogInterval(t *testing.T) {
+	sm := newTestStateManager(t)
+	clock := newFakeClock()
+	sm.now = clock.Now
+	sm.transitionLogInterval = time.Minute
+	tl := newTestLogger()
+	defer tl.Close()
+	transitionLogs := func(prefix string) []string {
+		var logs []string
+		for _, line := range tl.logs {
+			if strings.HasPrefix(line, prefix) {
+				logs = append(logs, line)
+			}
+		}
+		return logs
+	}
+	flap := func(n int) {
+		for i := 0; i < n; i++ {
+			_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+			require.NoError(t, err)
+			_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+			require.NoError(t, err)
+		}
+	}
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	toServing := "TabletServer transition: REPLICA -> REPLICA, NOT_SERVING (Not Serving) -> SERVING"
+	toNotServing := "TabletServer transition: REPLICA -> REPLICA, SERVING -> NOT_SERVING (Not Serving)"
+
+	// Only the first of the identical transitions is logged.
+	flap(3)
+	assert.Equal(t, []string{toServing}, transitionLogs(toServing))
+	assert.Equal(t, []string{toNotServing}, transitionLogs(toNotServing))
+	// The transition history isn't affected.
+	assert.Len(t, sm.TransitionHistory(), 7)
+
+	// Past the interval, the next one is logged with the number
+	// of suppressed transitions.
+	clock.Advance(time.Minute)
+	flap(1)
+	assert.Equal(t, []string{toServing, toServing + " (2 identical transitions suppressed)"}, transitionLogs(toServing))
+	assert.Equal(t, []string{toNotServing, toNotServing + " (2 identical transitions suppressed)"}, transitionLogs(toNotServing))
+
+	// Transitions are all logged without an interval.
+	sm.transitionLogInterval = 0
+	flap(2)
+	assert.Len(t, transitionLogs(toServing), 4)
+	assert.Len(t, transitionLogs(toNotServing), 4)
+}
+
+func TestStateManagerTransitionReason(t *testing.T) {
+	changed := make(chan *ServingStateChanged, 1)
+	event.AddListener(func(ev *ServingStateChanged) {
+		select {
+		case changed <- ev:
+		default:
+		}
+	})
+
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", sm.TransitionHistory()[0].Reason)
+	<-changed
+
+	stateChanged, err := sm.SetServingTypeWithReason(context.Background(), topodatapb.TabletType_REPLICA, StateNotServing, nil, "draining for maintenance")
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+
+	history := sm.TransitionHistory()
+	assert.Equal(t, "draining for maintenance", history[0].Reason)
+	assert.Equal(t, "NotServing", history[0].To)
+	assert.Equal(t, "draining for maintenance", sm.history.Records()[0].(*historyRecord).Reason)
+	select {
+	case ev := <-changed:
+		assert.Equal(t, &ServingStateChanged{
+			From:       "SERVING",
+			To:         "NOT_SERVING",
+			TabletType: topodatapb.TabletType_REPLICA,
+			Reason:     "draining for maintenance",
+		}, ev)
+	default:
+		t.Fatal("no ServingStateChanged event")
+	}
+
+	// The reason doesn't carry over to the next transition.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", sm.TransitionHistory()[0].Reason)
+	assert.Equal(t, "", sm.history.Records()[0].(*historyRecord).Reason)
+}
+
+// testCancelSubcomponent cancels a context when opened.
+type testCancelSubcomponent struct {
+	testSubcomponent
+	cancel context.CancelFunc
+}
+
+func (te *testCancelSubcomponent) Open() {
+	te.testSubcomponent.Open()
+	te.cancel()
+}
+
+func TestStateManagerSetServingTypeContextCancel(t *testing.T) {
+	sm := newTestStateManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sm.hw = &testCancelSubcomponent{cancel: cancel}
+
+	stateChanged, err := sm.SetServingTypeContext(ctx, topodatapb.TabletType_MASTER, StateServing, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, stateChanged)
+
+	// Everything must have been rolled back.
+	for _, component := range []interface{}{sm.se, sm.hw, sm.hr, sm.vstreamer, sm.tracker, sm.watcher, sm.qe, sm.txThrottler, sm.te, sm.messager} {
+		assert.NotEqual(t, testStateOpen, component.(orderState).State())
+	}
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.Equal(t, StateNotConnected, sm.wantState)
+	assert.False(t, sm.isTransitioning())
+	assert.False(t, sm.IsRetrying())
+}
+
+func TestStateManagerSetServingTypeContextRollback(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sm.hw = &testCancelSubcomponent{cancel: cancel}
+
+	_, err = sm.SetServingTypeContext(ctx, topodatapb.TabletType_MASTER, StateServing, nil)
+	assert.Equal(t, context.Canceled, err)
+
+	// The previous state must be fully restored.
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, testStateClosed, sm.hw.(orderState).State())
+	assert.Equal(t, testStateClosed, sm.tracker.(orderState).State())
+	assert.Equal(t, testStateClosed, sm.messager.(orderState).State())
+	assert.Equal(t, testStateAcceptReadOnly, sm.te.(orderState).State())
+	assert.Equal(t, testStateOpen, sm.hr.(orderState).State())
+	assert.Equal(t, testStateOpen, sm.watcher.(orderState).State())
+	assert.False(t, sm.isTransitioning())
+}
+
+func TestStateManagerSetServingTypeContextRollbackAlsoAllow(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, []topodatapb.TabletType{topodatapb.TabletType_RDONLY})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sm.hw = &testCancelSubcomponent{cancel: cancel}
+
+	_, err = sm.SetServingTypeContext(ctx, topodatapb.TabletType_MASTER, StateServing, []topodatapb.TabletType{topodatapb.TabletType_BATCH})
+	assert.Equal(t, context.Canceled, err)
+
+	// The previous alsoAllow must be restored along with the state.
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.Target().TabletType)
+	assert.Equal(t, []topodatapb.TabletType{topodatapb.TabletType_RDONLY}, sm.alsoAllow)
+	assert.False(t, sm.isTransitioning())
+}
+
+func TestStateManagerSetServingTypeContextWait(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitioning.Acquire()
+	defer sm.transitioning.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	stateChanged, err := sm.SetServingTypeContext(ctx, topodatapb.TabletType_MASTER, StateServing, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.False(t, stateChanged)
+	assert.Equal(t, StateNotConnected, sm.State())
+}
+
+func TestStateManagerRestoreType(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.EnterLameduck()
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_RESTORE, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+
+	assert.Equal(t, topodatapb.TabletType_RESTORE, sm.target.TabletType)
+	// RESTORE can only be in StateNotConnected.
+	assert.Equal(t, StateNotConnected, sm.state)
+}
+
+func TestStateManagerPostRestoreTarget(t *testing.T) {
+	completed := make(chan *RestoreCompleted, 1)
+	event.AddListener(func(ev *RestoreCompleted) {
+		select {
+		case completed <- ev:
+		default:
+		}
+	})
+
+	sm := newTestStateManager(t)
+	sm.restorePollInterval = 10 * time.Millisecond
+	var done sync2.AtomicBool
+	sm.SetRestoreProgressSource(func() RestoreProgress {
+		return RestoreProgress{Phase: "downloading", Done: done.Get()}
+	})
+	sm.SetPostRestoreTarget(topodatapb.TabletType_REPLICA, StateServing)
+
+	_, err := sm.SetServingType(topodatapb.TabletType_RESTORE, StateNotConnected, nil)
+	require.NoError(t, err)
+	// The tablet remains in RESTORE until the restore is done.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, topodatapb.TabletType_RESTORE, sm.Target().TabletType)
+
+	done.Set(true)
+	select {
+	case ev := <-completed:
+		assert.Equal(t, &RestoreCompleted{TabletType: topodatapb.TabletType_REPLICA, State: "SERVING"}, ev)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for RestoreCompleted")
+	}
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, "restore completed", sm.TransitionHistory()[0].Reason)
+}
+
+func TestStateManagerPostRestoreTargetDisabled(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.restorePollInterval = 10 * time.Millisecond
+	sm.SetRestoreProgressSource(func() RestoreProgress {
+		return RestoreProgress{Done: true}
+	})
+
+	// Without a post-restore target, the tablet waits for an
+	// explicit transition.
+	_, err := sm.SetServingType(topodatapb.TabletType_RESTORE, StateNotConnected, nil)
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, topodatapb.TabletType_RESTORE, sm.Target().TabletType)
+
+	// Setting the target while restoring starts watching the restore.
+	sm.SetPostRestoreTarget(topodatapb.TabletType_RDONLY, StateNotServing)
+	assert.Eventually(t, func() bool {
+		return sm.Target().TabletType == topodatapb.TabletType_RDONLY
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, StateNotServing, sm.State())
+}
+
+func TestStateManagerSpareAndDrainedTypes(t *testing.T) {
+	for _, tabletType := range []topodatapb.TabletType{topodatapb.TabletType_SPARE, topodatapb.TabletType_DRAINED} {
+		t.Run(tabletType.String(), func(t *testing.T) {
+			sm := newTestStateManager(t)
+			stateChanged, err := sm.SetServingType(tabletType, StateServing, nil)
+			require.NoError(t, err)
+			assert.True(t, stateChanged)
+
+			verifySubcomponent(t, 1, sm.messager, testStateClosed)
+			verifySubcomponent(t, 2, sm.te, testStateClosed)
+			assert.True(t, sm.qe.(*testQueryEngine).stopServing)
+			verifySubcomponent(t, 3, sm.tracker, testStateClosed)
+			verifySubcomponent(t, 4, sm.hw, testStateClosed)
+
+			verifySubcomponent(t, 5, sm.se, testStateOpen)
+
+			// SPARE and DRAINED can only be in StateNotServing.
+			assert.Equal(t, tabletType, sm.target.TabletType)
+			assert.Equal(t, StateNotServing, sm.state)
+			assert.Equal(t, "NOT_SERVING", sm.StateByName())
+
+			// Requests for the tablet type are accepted, but only
+			// those allowed in a non-serving state can proceed.
+			assert.NoError(t, sm.VerifyTarget(context.Background(), &querypb.Target{TabletType: tabletType}))
+			err = sm.VerifyTarget(context.Background(), &querypb.Target{TabletType: topodatapb.TabletType_REPLICA})
+			assert.True(t, errors.Is(err, ErrInvalidTabletType))
+			err = sm.StartRequest(context.Background(), &querypb.Target{TabletType: tabletType}, false)
+			assert.True(t, errors.Is(err, ErrNotServing))
+
+			// An explicit StateNotConnected is honored.
+			_, err = sm.SetServingType(tabletType, StateNotConnected, nil)
+			require.NoError(t, err)
+			assert.Equal(t, StateNotConnected, sm.state)
+		})
+	}
+}
+
+func TestStateManagerRestoreStatus(t *testing.T) {
+	sm := newTestStateManager(t)
+	progress := RestoreProgress{Phase: "downloading", Bytes: 1 << 20, ETA: 5 * time.Minute}
+	sm.SetRestoreProgressSource(func() RestoreProgress {
+		return progress
+	})
+
+	// There's no restore in progress.
+	_, ok := sm.RestoreStatus()
+	assert.False(t, ok)
+
+	_, err := sm.SetServingType(topodatapb.TabletType_RESTORE, StateServing, nil)
+	require.NoError(t, err)
+	got, ok := sm.RestoreStatus()
+	assert.True(t, ok)
+	assert.Equal(t, progress, got)
+	assert.Equal(t, StateNotConnected, sm.State())
+
+	progress = RestoreProgress{Phase: "applying", Bytes: 2 << 20}
+	got, ok = sm.RestoreStatus()
+	assert.True(t, ok)
+	assert.Equal(t, progress, got)
+	assert.Equal(t, StateNotConnected, sm.State())
+
+	sm.SetRestoreProgressSource(nil)
+	_, ok = sm.RestoreStatus()
+	assert.False(t, ok)
+}
+
+func TestStateManagerCheckMySQL(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+
+	sm.qe.(*testQueryEngine).failMySQL = true
+	order.Set(0)
+	sm.CheckMySQL()
+
+	// Rechecking immediately should be a no-op:
+	sm.CheckMySQL()
+
+	// Wait for closeAll to get under way.
+	for {
+		if order.Get() >= 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Wait to get out of transitioning state.
+	for {
+		if !sm.isTransitioning() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Wait for retry to finish.
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, map[string]int64{
+		"Throttled": 1,
+		"Healthy":   0,
+		"Skipped":   0,
+		"ShutDown":  1,
+	}, sm.CheckMySQLCounts())
+	assert.False(t, sm.LastCheckMySQLFailure().IsZero())
+}
+
+func TestStateManagerCheckMySQLFrozen(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+
+	sm.Freeze()
+	defer sm.Unfreeze()
+	sm.qe.(*testQueryEngine).failMySQL = true
+	sm.CheckMySQL()
+	for sm.CheckMySQLCounts()["Skipped"] == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The failure doesn't shut the query service down.
+	assert.Equal(t, int64(0), sm.CheckMySQLCounts()["ShutDown"])
+	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, testStateOpen, sm.qe.(*testQueryEngine).State())
+	assert.False(t, sm.IsRetrying())
+}
+
+func TestStateManagerAutoRecover(t *testing.T) {
+
+	recovered := make(chan *MySQLRecovered, 1)
+	event.AddListener(func(ev *MySQLRecovered) {
+		select {
+		case recovered <- ev:
+		default:
+		}
+	})
+
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	sm.SetAutoRecover(true)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+
+	var down sync2.AtomicBool
+	down.Set(true)
+	sm.SetMySQLProbe(func(ctx context.Context) error {
+		if down.Get() {
+			return errors.New("mysql is down")
+		}
+		return nil
+	})
+	sm.CheckMySQL()
+
+	// Wait for the tablet to be shut down.
+	for sm.State() != StateNotConnected {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// It stays down while mysql is unreachable.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, StateNotConnected, sm.State())
+	select {
+	case ev := <-recovered:
+		t.Fatalf("unexpected recovery: %v", ev)
+	default:
+	}
+
+	down.Set(false)
+	select {
+	case ev := <-recovered:
+		assert.Equal(t, topodatapb.TabletType_REPLICA, ev.TabletType)
+		assert.Equal(t, "SERVING", ev.State)
+		assert.True(t, ev.Downtime > 0, "%v", ev.Downtime)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the recovery")
+	}
+	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.Target().TabletType)
+	assert.False(t, sm.IsRetrying())
+}
+
+func TestStateManagerAutoRecoverFrozen(t *testing.T) {
+	recovered := make(chan *MySQLRecovered, 1)
+	event.AddListener(func(ev *MySQLRecovered) {
+		select {
+		case recovered <- ev:
+		default:
+		}
+	})
+
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	sm.SetAutoRecover(true)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+
+	var down sync2.AtomicBool
+	down.Set(true)
+	sm.SetMySQLProbe(func(ctx context.Context) error {
+		if down.Get() {
+			return errors.New("mysql is down")
+		}
+		return nil
+	})
+	sm.CheckMySQL()
+	for sm.State() != StateNotConnected {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The tablet doesn't recover while transitions are frozen.
+	sm.Freeze()
+	down.Set(false)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.True(t, sm.IsRetrying())
+
+	sm.Unfreeze()
+	select {
+	case ev := <-recovered:
+		assert.Equal(t, topodatapb.TabletType_REPLICA, ev.TabletType)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the recovery")
+	}
+	assert.Equal(t, StateServing, sm.State())
+}
+
+func TestStateManagerCheckMySQLJitter(t *testing.T) {
+	sm := newTestStateManager(t)
+	assert.Equal(t, defaultCheckMySQLInterval, sm.checkMySQLDelay(rand.Float64))
+
+	sm.checkMySQLBackoff = backoffPolicy{initial: 40 * time.Millisecond, jitter: 0.5}
+	assert.Equal(t, 20*time.Millisecond, sm.checkMySQLDelay(func() float64 { return 0 }))
+	assert.Equal(t, 40*time.Millisecond, sm.checkMySQLDelay(func() float64 { return 0.5 }))
+	for i := 0; i < 100; i++ {
+		d := sm.checkMySQLDelay(rand.Float64)
+		assert.True(t, d >= 20*time.Millisecond && d < 60*time.Millisecond, "%v", d)
+	}
+
+	var checks sync2.AtomicInt64
+	sm.SetMySQLProbe(func(ctx context.Context) error {
+		checks.Add(1)
+		return nil
+	})
+	start := time.Now()
+	sm.CheckMySQL()
+	// Rechecking immediately should be a no-op.
+	sm.CheckMySQL()
+
+	// Wait for the throttler to be released.
+	for !sm.checkMySQLThrottler.TryAcquire() {
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(start)
+	sm.checkMySQLThrottler.Release()
+	assert.Equal(t, int64(1), checks.Get())
+	assert.True(t, elapsed >= 20*time.Millisecond, "%v", elapsed)
+	assert.Equal(t, map[string]int64{
+		"Throttled": 1,
+		"Healthy":   1,
+		"Skipped":   0,
+		"ShutDown":  0,
+	}, sm.CheckMySQLCounts())
+	assert.True(t, sm.LastCheckMySQLFailure().IsZero())
+}
+
+func TestStateManagerCheckMySQLProbe(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+
+	var probeCalls sync2.AtomicInt64
+	sm.SetMySQLProbe(func(ctx context.Context) error {
+		// Fail only the first time.
+		if probeCalls.Add(1) == 1 {
+			return errors.New("probe error")
+		}
+		return nil
+	})
+
+	// MySQL is reachable, but the probe fails.
+	order.Set(0)
+	sm.CheckMySQL()
+
+	// Wait for closeAll to get under way.
+	for order.Get() < 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for sm.IsRetrying() || sm.isTransitioning() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, int64(1), sm.MySQLProbeFailures())
+	assert.GreaterOrEqual(t, probeCalls.Get(), int64(2))
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
+	assert.Equal(t, StateServing, sm.State())
+
+	// A probe failure during a transition fails the transition.
+	sm.SetMySQLProbe(func(ctx context.Context) error {
+		return errors.New("probe error")
+	})
+	sm.transitioning.Acquire()
+	err = sm.execTransition(context.Background(), topodatapb.TabletType_MASTER, StateServing)
+	assert.EqualError(t, err, "mysql health_check: mysql probe failed: probe error")
+
+	// Once the probe passes, the retry converges.
+	sm.SetMySQLProbe(nil)
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StateServing, sm.State())
+}
+
+func TestStateManagerIsReady(t *testing.T) {
+	sm := newTestStateManager(t)
+
+	ready, reason := sm.IsReady()
+	assert.False(t, ready)
+	assert.Equal(t, "requested state is NOT_SERVING (Not Connected)", reason)
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	ready, reason = sm.IsReady()
+	assert.False(t, ready)
+	assert.Equal(t, "requested state is NOT_SERVING (Not Serving)", reason)
+
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	ready, reason = sm.IsReady()
+	assert.True(t, ready)
+	assert.Equal(t, "", reason)
+
+	sm.EnterLameduck()
+	ready, reason = sm.IsReady()
+	assert.False(t, ready)
+	assert.Equal(t, "in lameduck mode", reason)
+	sm.ExitLameduck()
+
+	sm.transitioning.Acquire()
+	ready, reason = sm.IsReady()
+	assert.False(t, ready)
+	assert.Equal(t, "state transition in progress", reason)
+	sm.transitioning.Release()
+
+	sm.mu.Lock()
+	sm.retrying = true
+	sm.mu.Unlock()
+	ready, reason = sm.IsReady()
+	assert.False(t, ready)
+	assert.Equal(t, "retrying transition to REPLICA SERVING", reason)
+	sm.mu.Lock()
+	sm.retrying = false
+	sm.mu.Unlock()
+
+	// The state lags wantState while a transition is executing.
+	sm.mu.Lock()
+	sm.state = StateNotServing
+	sm.mu.Unlock()
+	ready, reason = sm.IsReady()
+	assert.False(t, ready)
+	assert.Equal(t, "state is NOT_SERVING (Not Serving)", reason)
+	sm.mu.Lock()
+	sm.state = StateServing
+	sm.mu.Unlock()
+
+	_, err = sm.SetServingType(topodatapb.TabletType_RESTORE, StateServing, nil)
+	require.NoError(t, err)
+	ready, reason = sm.IsReady()
+	assert.False(t, ready)
+	assert.Equal(t, "restoring", reason)
+}
+
+func TestStateManagerValidations(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.target = *target
+
+	err := sm.StartRequest(ctx, target, false)
+	assert.Contains(t, err.Error(), "operation not allowed")
+
+	sm.state = StateServing
+	sm.wantState = StateNotServing
+	err = sm.StartRequest(ctx, target, false)
+	assert.Contains(t, err.Error(), "operation not allowed")
+
+	err = sm.StartRequest(ctx, target, true)
+	assert.NoError(t, err)
+
+	sm.wantState = StateServing
+	target.Keyspace = "a"
+	err = sm.StartRequest(ctx, target, false)
+	assert.Contains(t, err.Error(), "invalid keyspace")
+	err = sm.VerifyTarget(ctx, target)
+	assert.Contains(t, err.Error(), "invalid keyspace")
+
+	target.Keyspace = ""
+	target.Shard = "a"
+	err = sm.StartRequest(ctx, target, false)
+	assert.Contains(t, err.Error(), "invalid shard")
+	err = sm.VerifyTarget(ctx, target)
+	assert.Contains(t, err.Error(), "invalid shard")
+
+	target.Shard = ""
+	target.TabletType = topodatapb.TabletType_REPLICA
+	err = sm.StartRequest(ctx, target, false)
+	assert.Contains(t, err.Error(), "invalid tablet type")
+	err = sm.VerifyTarget(ctx, target)
+	assert.Contains(t, err.Error(), "invalid tablet type")
+
+	sm.alsoAllow = []topodatapb.TabletType{topodatapb.TabletType_REPLICA}
+	err = sm.StartRequest(ctx, target, false)
+	assert.NoError(t, err)
+	err = sm.VerifyTarget(ctx, target)
+	assert.NoError(t, err)
+
+	err = sm.StartRequest(ctx, nil, false)
+	assert.Contains(t, err.Error(), "No target")
+	err = sm.VerifyTarget(ctx, nil)
+	assert.Contains(t, err.Error(), "No target")
+
+	localctx := tabletenv.LocalContext()
+	err = sm.StartRequest(localctx, nil, false)
+	assert.NoError(t, err)
+	err = sm.VerifyTarget(localctx, nil)
+	assert.NoError(t, err)
+}
+
+func TestStateManagerVerifyTargetOK(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.target = querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_MASTER}
+	sm.alsoAllow = []topodatapb.TabletType{topodatapb.TabletType_REPLICA}
+
+	testcases := []struct {
+		ctx    context.Context
+		target *querypb.Target
+	}{{
+		ctx:    ctx,
+		target: &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_MASTER},
+	}, {
+		ctx:    ctx,
+		target: &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA},
+	}, {
+		ctx:    ctx,
+		target: &querypb.Target{Keyspace: "a", Shard: "0", TabletType: topodatapb.TabletType_MASTER},
+	}, {
+		ctx:    ctx,
+		target: &querypb.Target{Keyspace: "ks", Shard: "a", TabletType: topodatapb.TabletType_MASTER},
+	}, {
+		ctx:    ctx,
+		target: &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_RDONLY},
+	}, {
+		ctx: ctx,
+	}, {
+		ctx: tabletenv.LocalContext(),
+	}}
+	for _, tcase := range testcases {
+		err := sm.VerifyTarget(tcase.ctx, tcase.target)
+		assert.Equal(t, err == nil, sm.VerifyTargetOK(tcase.ctx, tcase.target), "%v: %v", tcase.target, err)
+	}
+}
+
+func TestStateManagerIsTypeAllowed(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.target = querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_MASTER}
+	assert.True(t, sm.IsTypeAllowed(topodatapb.TabletType_MASTER))
+	assert.False(t, sm.IsTypeAllowed(topodatapb.TabletType_REPLICA))
+
+	sm.alsoAllow = []topodatapb.TabletType{topodatapb.TabletType_REPLICA}
+	assert.True(t, sm.IsTypeAllowed(topodatapb.TabletType_MASTER))
+	assert.True(t, sm.IsTypeAllowed(topodatapb.TabletType_REPLICA))
+	assert.False(t, sm.IsTypeAllowed(topodatapb.TabletType_RDONLY))
+	assert.False(t, sm.IsTypeAllowed(topodatapb.TabletType_UNKNOWN))
+}
+
+func TestStateManagerMaintenance(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+
+	sm.EnterMaintenance("upgrading mysql")
+	assert.True(t, sm.Snapshot().Maintenance)
+	err = sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrMaintenance), "%v", err)
+	assert.Equal(t, vtrpcpb.Code_UNAVAILABLE, vterrors.Code(err))
+	assert.EqualError(t, err, "tablet is in maintenance: upgrading mysql")
+	// The serving state is unchanged.
+	assert.Equal(t, StateServing, sm.State())
+	assert.True(t, sm.IsServing())
+
+	// Internal requests are still accepted.
+	err = sm.StartRequest(tabletenv.LocalContext(), nil, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+
+	sm.ExitMaintenance()
+	assert.False(t, sm.Snapshot().Maintenance)
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+}
+
+func TestStateManagerRequestCounts(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}
+	sm.target = *target
+
+	// Not serving.
+	err := sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrNotServing), "%v", err)
+
+	sm.state = StateServing
+	sm.wantState = StateServing
+	err = sm.StartRequest(ctx, nil, false)
+	assert.True(t, errors.Is(err, ErrNoTarget), "%v", err)
+	err = sm.StartRequest(ctx, &querypb.Target{Keyspace: "a", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}, false)
+	assert.True(t, errors.Is(err, ErrInvalidKeyspace), "%v", err)
+	err = sm.StartRequest(ctx, &querypb.Target{Keyspace: "ks", Shard: "1", TabletType: topodatapb.TabletType_REPLICA}, false)
+	assert.True(t, errors.Is(err, ErrInvalidShard), "%v", err)
+	err = sm.StartRequest(ctx, &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_RDONLY}, false)
+	assert.True(t, errors.Is(err, ErrInvalidTabletType), "%v", err)
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+
+	want := map[string]int64{
+		"REPLICA.NotServing":        1,
+		"REPLICA.NoTarget":          1,
+		"REPLICA.InvalidKeyspace":   1,
+		"REPLICA.InvalidShard":      1,
+		"REPLICA.InvalidTabletType": 1,
+		"REPLICA.Accepted":          2,
+	}
+	assert.Equal(t, want, sm.RequestCounts())
+
+	// Counts are kept separately for each tablet type.
+	sm.target.TabletType = topodatapb.TabletType_RDONLY
+	err = sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrInvalidTabletType), "%v", err)
+	want["RDONLY.InvalidTabletType"] = 1
+	assert.Equal(t, want, sm.RequestCounts())
+}
+
+func TestStateManagerDegraded(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.degradedReplicaLag = 10 * time.Second
+	lag, ok := 5*time.Second, true
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return lag, ok })
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+
+	assert.False(t, sm.IsDegraded())
+	assert.Equal(t, "SERVING", sm.StateByName())
+
+	lag = 30 * time.Second
+	assert.True(t, sm.IsDegraded())
+	// The state name and IsServing are unaffected.
+	assert.Equal(t, "SERVING", sm.StateByName())
+	assert.True(t, sm.IsServing())
+	assert.Equal(t, StateServing, sm.State())
+	// Reads are still accepted.
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+
+	// An unknown lag is also degraded.
+	lag, ok = 0, false
+	assert.True(t, sm.IsDegraded())
+
+	lag, ok = 5*time.Second, true
+	assert.False(t, sm.IsDegraded())
+	assert.Equal(t, "SERVING", sm.StateByName())
+
+	// The gate is invoked without sm.mu, so it can call back into sm.
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return lag, sm.State() != StateServing })
+	assert.True(t, sm.IsDegraded())
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return lag, ok })
+
+	// A master is never degraded.
+	lag = 30 * time.Second
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.False(t, sm.IsDegraded())
+}
+
+func TestStateManagerReplicaLagGate(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+	sm.target = *target
+	sm.state = StateServing
+	sm.wantState = StateServing
+	sm.maxReplicaLag = 10 * time.Second
+
+	lag, ok := 30*time.Second, true
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return lag, ok })
+
+	err := sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrReplicaLagged), "%v", err)
+	assert.Equal(t, vtrpcpb.Code_FAILED_PRECONDITION, vterrors.Code(err))
+	assert.EqualError(t, err, "replication lag 30s exceeds 10s")
+
+	ok = false
+	err = sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrReplicaLagged), "%v", err)
+	assert.EqualError(t, err, "replication lag is unknown")
+
+	// The rejected requests are not left in flight.
+	assert.Equal(t, int64(0), sm.InFlightRequests())
+	assert.Equal(t, int64(2), sm.RequestCounts()["REPLICA.ReplicaLagged"])
+
+	lag, ok = 1*time.Second, true
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+	assert.Equal(t, int64(1), sm.RequestCounts()["REPLICA.Accepted"])
+
+	// The gate is invoked without sm.mu, so it can call back into sm.
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return 0, sm.State() == StateServing })
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+
+	// Internal requests and masters are not gated.
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return lag, ok })
+	lag = 30 * time.Second
+	err = sm.StartRequest(tabletenv.LocalContext(), nil, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+
+	target.TabletType = topodatapb.TabletType_MASTER
+	sm.target = *target
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+}
+
+func TestStateManagerValidationErrors(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.target = *target
+
+	err := sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrNotServing), "%v", err)
+	assert.Equal(t, vtrpcpb.Code_FAILED_PRECONDITION, vterrors.Code(err))
+
+	sm.state = StateServing
+	sm.wantState = StateNotServing
+	err = sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrNotServing), "%v", err)
+	assert.EqualError(t, err, "operation not allowed in state SHUTTING_DOWN")
+
+	sm.wantState = StateServing
+	target.Keyspace = "a"
+	err = sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrInvalidKeyspace), "%v", err)
+	assert.Equal(t, vtrpcpb.Code_INVALID_ARGUMENT, vterrors.Code(err))
+	err = sm.VerifyTarget(ctx, target)
+	assert.True(t, errors.Is(err, ErrInvalidKeyspace), "%v", err)
+
+	target.Keyspace = ""
+	target.Shard = "a"
+	err = sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrInvalidShard), "%v", err)
+	err = sm.VerifyTarget(ctx, target)
+	assert.True(t, errors.Is(err, ErrInvalidShard), "%v", err)
+
+	target.Shard = ""
+	target.TabletType = topodatapb.TabletType_REPLICA
+	err = sm.StartRequest(ctx, target, false)
+	assert.True(t, errors.Is(err, ErrInvalidTabletType), "%v", err)
+	assert.Equal(t, vtrpcpb.Code_FAILED_PRECONDITION, vterrors.Code(err))
+	err = sm.VerifyTarget(ctx, target)
+	assert.True(t, errors.Is(err, ErrInvalidTabletType), "%v", err)
+	assert.False(t, errors.Is(err, ErrInvalidKeyspace))
+
+	err = sm.StartRequest(ctx, nil, false)
+	assert.True(t, errors.Is(err, ErrNoTarget), "%v", err)
+	assert.EqualError(t, err, "No target")
+	err = sm.VerifyTarget(ctx, nil)
+	assert.True(t, errors.Is(err, ErrNoTarget), "%v", err)
+}
+
+func TestStateManagerUpdateAllowedTypes(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+
+	var changes sync2.AtomicInt64
+	sm.SubscribeStateChanges(func(from, to servingState, tabletType topodatapb.TabletType) {
+		changes.Add(1)
+	})
+
+	target := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+	err = sm.VerifyTarget(ctx, target)
+	assert.True(t, errors.Is(err, ErrInvalidTabletType), "%v", err)
+
+	// The update must not wait for a transition in progress.
+	sm.transitioning.Acquire()
+	order.Set(0)
+	allowed := []topodatapb.TabletType{topodatapb.TabletType_REPLICA}
+	sm.UpdateAllowedTypes(allowed)
+	sm.transitioning.Release()
+
+	assert.NoError(t, sm.VerifyTarget(ctx, target))
+	assert.NoError(t, sm.StartRequest(ctx, target, false))
+	sm.EndRequest()
+
+	// Nothing was reopened, and no change was signaled.
+	assert.Equal(t, int64(0), order.Get())
+	assert.Equal(t, int64(0), changes.Get())
+	assert.Equal(t, StateServing, sm.State())
+
+	// The caller's slice is not retained.
+	allowed[0] = topodatapb.TabletType_RDONLY
+	assert.NoError(t, sm.VerifyTarget(ctx, target))
+
+	sm.UpdateAllowedTypes(nil)
+	err = sm.VerifyTarget(ctx, target)
+	assert.True(t, errors.Is(err, ErrInvalidTabletType), "%v", err)
+}
+
+func TestStateManagerInFlightRequests(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.target = *target
+	sm.timebombDuration = 10 * time.Second
+
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), sm.InFlightRequests())
+
+	for i := 0; i < 3; i++ {
+		err = sm.StartRequest(ctx, target, false)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(3), sm.InFlightRequests())
+
+	// Rejected requests are not counted.
+	err = sm.StartRequest(ctx, &querypb.Target{Keyspace: "a"}, false)
+	require.Error(t, err)
+	assert.Equal(t, int64(3), sm.InFlightRequests())
+
+	for i := 0; i < 3; i++ {
+		sm.EndRequest()
+	}
+	assert.Equal(t, int64(0), sm.InFlightRequests())
+
+	// The count must agree with the waitgroup, or this would hang.
+	sm.StopService()
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.Equal(t, int64(0), sm.InFlightRequests())
+}
+
+func TestStateManagerDemoteToReadOnly(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	err = sm.DemoteToReadOnly()
+	assert.Contains(t, err.Error(), "is not a serving master")
+
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+
+	order.Set(0)
+	history := len(sm.TransitionHistory())
+	require.NoError(t, sm.DemoteToReadOnly())
+	verifySubcomponent(t, 1, sm.messager, testStateClosed)
+	verifySubcomponent(t, 2, sm.te, testStateAcceptReadOnly)
+	verifySubcomponent(t, 3, sm.tracker, testStateClosed)
+	verifySubcomponent(t, 4, sm.hw, testStateClosed)
+	assert.True(t, sm.se.(*testSchemaEngine).nonMaster)
+	// The read path is untouched.
+	assert.Equal(t, testStateOpen, sm.qe.(*testQueryEngine).State())
+	assert.Equal(t, StateServing, sm.State())
+	assert.True(t, sm.Snapshot().ReadOnly)
+	assert.NoError(t, sm.StartRequest(ctx, target, false))
+	sm.EndRequest()
+
+	// Demoting again is a no-op.
+	require.NoError(t, sm.DemoteToReadOnly())
+	assert.Equal(t, int64(4), order.Get())
+
+	require.NoError(t, sm.PromoteToReadWrite())
+	verifySubcomponent(t, 5, sm.hw, testStateOpen)
+	verifySubcomponent(t, 6, sm.tracker, testStateOpen)
+	verifySubcomponent(t, 7, sm.te, testStateAcceptReadWrite)
+	verifySubcomponent(t, 8, sm.messager, testStateOpen)
+	assert.False(t, sm.Snapshot().ReadOnly)
+	require.NoError(t, sm.PromoteToReadWrite())
+	assert.Equal(t, int64(8), order.Get())
+	// The state never changed, so nothing is recorded.
+	assert.Len(t, sm.TransitionHistory(), history)
+
+	// A transition to master also restores read-write.
+	require.NoError(t, sm.DemoteToReadOnly())
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, stateChanged)
+	assert.Equal(t, testStateAcceptReadWrite, sm.te.(*testTxEngine).State())
+	assert.False(t, sm.Snapshot().ReadOnly)
+}
+
+func TestStateManagerDemoteToReadOnlyFailure(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+
+	// If the tx engine can't switch to read-only, the master is
+	// left fully read-write.
+	sm.te.(*testTxEngine).failAcceptReadOnly = true
+	err = sm.DemoteToReadOnly()
+	assert.EqualError(t, err, "tx_engine accept_read_only: tx engine can't accept read-only")
+	assert.Equal(t, testStateOpen, sm.messager.(orderState).State())
+	assert.Equal(t, testStateOpen, sm.tracker.(orderState).State())
+	assert.Equal(t, testStateOpen, sm.hw.(orderState).State())
+	assert.False(t, sm.se.(*testSchemaEngine).nonMaster)
+	assert.Equal(t, testStateAcceptReadWrite, sm.te.(*testTxEngine).State())
+	assert.False(t, sm.Snapshot().ReadOnly)
+
+	// It can be retried once the tx engine recovers.
+	sm.te.(*testTxEngine).failAcceptReadOnly = false
+	require.NoError(t, sm.DemoteToReadOnly())
+	assert.True(t, sm.Snapshot().ReadOnly)
+}
+
+func TestStateManagerPromoteToMaster(t *testing.T) {
+	promoted := make(chan *MasterPromoted, 1)
+	event.AddListener(func(ev *MasterPromoted) {
+		select {
+		case promoted <- ev:
+		default:
+		}
+	})
+
+	sm := newTestStateManager(t)
+	wantPos, err := mysql.DecodePosition("MySQL56/16b1039f-22b6-11ed-b765-0a43f95f28a3:1-615")
+	require.NoError(t, err)
+	sm.positionProbe = func(ctx context.Context) (mysql.Position, error) { return wantPos, nil }
+
+	// Only a serving tablet can be promoted.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	_, err = sm.PromoteToMaster(context.Background())
+	assert.EqualError(t, err, "cannot promote to master: REPLICA NOT_SERVING is not serving")
+
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.True(t, sm.se.(*testSchemaEngine).nonMaster)
+	pos, err := sm.PromoteToMaster(context.Background())
+	require.NoError(t, err)
+	assert.True(t, pos.Equal(wantPos))
+	assert.Equal(t, topodatapb.TabletType_MASTER, sm.target.TabletType)
+	assert.Equal(t, StateServing, sm.state)
+	assert.Equal(t, testStateAcceptReadWrite, sm.te.(*testTxEngine).State())
+	assert.Equal(t, testStateOpen, sm.hw.(*testSubcomponent).State())
+	assert.Equal(t, testStateOpen, sm.messager.(*testSubcomponent).State())
+	assert.Equal(t, testStateClosed, sm.hr.(*testSubcomponent).State())
+
+	select {
+	case ev := <-promoted:
+		assert.Equal(t, topodatapb.TabletType_REPLICA, ev.From)
+		assert.True(t, ev.Position.Equal(wantPos))
+	default:
+		t.Fatal("no MasterPromoted event")
+	}
+
+	// Promoting a master only reports its position.
+	pos, err = sm.PromoteToMaster(context.Background())
+	require.NoError(t, err)
+	assert.True(t, pos.Equal(wantPos))
+	assert.Len(t, promoted, 0)
+
+	sm.positionProbe = func(ctx context.Context) (mysql.Position, error) { return mysql.Position{}, errors.New("no position") }
+	_, err = sm.PromoteToMaster(context.Background())
+	assert.EqualError(t, err, "could not read the master position: no position")
+}
+
+func TestStateManagerSnapshot(t *testing.T) {
+	sm := newTestStateManager(t)
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, []topodatapb.TabletType{topodatapb.TabletType_RDONLY})
+	require.NoError(t, err)
+
+	snapshot := sm.Snapshot()
+	assert.Equal(t, StateSnapshot{
+		State:          StateServing,
+		WantState:      StateServing,
+		WantTabletType: topodatapb.TabletType_REPLICA,
+		Target:         querypb.Target{TabletType: topodatapb.TabletType_REPLICA},
+		AlsoAllow:      []topodatapb.TabletType{topodatapb.TabletType_RDONLY},
+	}, snapshot)
+	// The snapshot doesn't share the alsoAllow list.
+	snapshot.AlsoAllow[0] = topodatapb.TabletType_BACKUP
+	assert.Equal(t, []topodatapb.TabletType{topodatapb.TabletType_RDONLY}, sm.Snapshot().AlsoAllow)
+
+	// Take a snapshot in the middle of a transition to master.
+	var during StateSnapshot
+	sm.hw = &testHookSubcomponent{onOpen: func() { during = sm.Snapshot() }}
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, StateServing, during.WantState)
+	assert.Equal(t, topodatapb.TabletType_MASTER, during.WantTabletType)
+	assert.Equal(t, topodatapb.TabletType_REPLICA, during.Target.TabletType)
+	assert.Empty(t, during.AlsoAllow)
+	assert.False(t, during.Retrying)
+
+	sm.EnterLameduck()
+	defer sm.ExitLameduck()
+	snapshot = sm.Snapshot()
+	assert.True(t, snapshot.Lameduck)
+	assert.Equal(t, topodatapb.TabletType_MASTER, snapshot.Target.TabletType)
+}
+
+func TestStateManagerHealth(t *testing.T) {
+	sm := newTestStateManager(t)
+	clock := newFakeClock()
+	sm.now = clock.Now
+	assert.Equal(t, HealthStatus{}, sm.Health())
+
+	lag, ok := 5*time.Second, true
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return lag, ok })
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	reachable := clock.Now().Local()
+	clock.Advance(time.Minute)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+	sm.EnterLameduck()
+
+	assert.Equal(t, HealthStatus{
+		State:               StateServing,
+		TabletType:          topodatapb.TabletType_REPLICA,
+		Lameduck:            true,
+		InFlight:            2,
+		LastMySQLReachable:  reachable,
+		ReplicationLag:      5 * time.Second,
+		ReplicationLagKnown: true,
+	}, sm.Health())
+
+	sm.EndRequest()
+	sm.EndRequest()
+	ok = false
+	health := sm.Health()
+	assert.Equal(t, int64(0), health.InFlight)
+	assert.False(t, health.ReplicationLagKnown)
+
+	// The gate is invoked without sm.mu, so it can call back into sm.
+	sm.SetReplicaLagGate(func() (time.Duration, bool) { return time.Second, sm.State() == StateServing })
+	health = sm.Health()
+	assert.Equal(t, time.Second, health.ReplicationLag)
+	assert.True(t, health.ReplicationLagKnown)
+
+	// The replication lag doesn't apply to a master.
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatus{
+		State:              StateServing,
+		TabletType:         topodatapb.TabletType_MASTER,
+		LastMySQLReachable: clock.Now().Local(),
+	}, sm.Health())
+}
+
+func TestStateManagerWaitForRequests(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.target = *target
+	sm.timebombDuration = 10 * time.Second
+
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+
+	// This will go into transition and wait.
+	// Wait for that state.
+	go sm.StopService()
+	for {
+		if !sm.isTransitioning() {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		break
+	}
+
+	// Verify that we're still transitioning.
+	assert.True(t, sm.isTransitioning())
+
+	sm.EndRequest()
+
+	for {
+		if sm.isTransitioning() {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		break
+	}
+	assert.Equal(t, StateNotConnected, sm.State())
+}
+
+func TestStateManagerLameduckPeriodByType(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.lameduckPeriod = 1 * time.Hour
+	sm.lameduckByType = map[topodatapb.TabletType]time.Duration{
+		topodatapb.TabletType_MASTER:  0,
+		topodatapb.TabletType_REPLICA: 50 * time.Millisecond,
+	}
+	assert.Equal(t, time.Duration(0), sm.lameduckPeriodFor(topodatapb.TabletType_MASTER))
+	assert.Equal(t, 50*time.Millisecond, sm.lameduckPeriodFor(topodatapb.TabletType_REPLICA))
+	assert.Equal(t, 1*time.Hour, sm.lameduckPeriodFor(topodatapb.TabletType_RDONLY))
+
+	// MASTER drains immediately.
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	sm.EnterLameduck()
+	start := time.Now()
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+	assert.Equal(t, int32(0), sm.lameduck.Get())
+
+	// REPLICA waits for its lameduck period.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	sm.EnterLameduck()
+	start = time.Now()
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(50*time.Millisecond))
+	assert.Equal(t, int32(0), sm.lameduck.Get())
+}
+
+func TestStateManagerOnLameduck(t *testing.T) {
+	sm := newTestStateManager(t)
+	var calls []bool
+	var lameduck []bool
+	sm.OnLameduck(func(entering bool) {
+		calls = append(calls, entering)
+		// The callback must be able to call back into sm.
+		lameduck = append(lameduck, sm.Snapshot().Lameduck)
+	})
+
+	sm.EnterLameduck()
+	// A redundant call must not invoke the callback again.
+	sm.EnterLameduck()
+	assert.Equal(t, []bool{true}, calls)
+
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, calls)
+	assert.Equal(t, []bool{true, false}, lameduck)
+
+	// SetServingType doesn't invoke the callback outside lameduck.
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, calls)
+}
+
+func TestStateManagerOnRequestRejected(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}
+	sm.target = *target
+
+	var gotTarget *querypb.Target
+	var gotReason error
+	calls := 0
+	sm.OnRequestRejected(func(target *querypb.Target, reason error) {
+		calls++
+		gotTarget, gotReason = target, reason
+		// The callback must be able to call back into sm.
+		_ = sm.Target()
+	})
+
+	err := sm.StartRequest(ctx, target, false)
+	require.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, target, gotTarget)
+	assert.True(t, errors.Is(gotReason, ErrNotServing), "%v", gotReason)
+
+	sm.state = StateServing
+	sm.wantState = StateServing
+	testcases := []struct {
+		target *querypb.Target
+		want   error
+	}{
+		{nil, ErrNoTarget},
+		{&querypb.Target{Keyspace: "a", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}, ErrInvalidKeyspace},
+		{&querypb.Target{Keyspace: "ks", Shard: "1", TabletType: topodatapb.TabletType_REPLICA}, ErrInvalidShard},
+		{&querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_RDONLY}, ErrInvalidTabletType},
+	}
+	for _, tcase := range testcases {
+		calls = 0
+		err := sm.StartRequest(ctx, tcase.target, false)
+		require.Error(t, err)
+		assert.Equal(t, 1, calls, "%v", tcase.want)
+		assert.Equal(t, tcase.target, gotTarget)
+		assert.True(t, errors.Is(gotReason, tcase.want), "%v, want %v", gotReason, tcase.want)
+		assert.Equal(t, err, gotReason)
+	}
+
+	// Accepted requests don't invoke the callback.
+	calls = 0
+	err = sm.StartRequest(ctx, target, false)
+	require.NoError(t, err)
+	sm.EndRequest()
+	assert.Equal(t, 0, calls)
+}
+
+func TestStateManagerTimeInState(t *testing.T) {
+	sm := newTestStateManager(t)
+	clock := newFakeClock()
+	sm.now = clock.Now
+	assert.Equal(t, time.Duration(0), sm.TimeInState())
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, 10*time.Second, sm.TimeInState())
+
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), sm.TimeInState())
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, 3*time.Second, sm.TimeInState())
+
+	sm.StopService()
+	assert.Equal(t, time.Duration(0), sm.TimeInState())
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, 5*time.Second, sm.TimeInState())
+}
+
+func TestStateManagerStateDurations(t *testing.T) {
 	sm := newTestStateManager(t)
-	stateChanged, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	clock := newFakeClock()
+	sm.now = clock.Now
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
 	require.NoError(t, err)
-	assert.True(t, stateChanged)
+	assert.Equal(t, "Serving.REPLICA", sm.CurrentStateLabel())
+	clock.Advance(10 * time.Second)
 
-	stateChanged, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	sm.EnterLameduck()
+	assert.Equal(t, "NotServing.REPLICA", sm.CurrentStateLabel())
+	clock.Advance(2 * time.Second)
+
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
 	require.NoError(t, err)
-	assert.False(t, stateChanged)
+	clock.Advance(5 * time.Second)
 
-	verifySubcomponent(t, 1, sm.messager, testStateClosed)
-	verifySubcomponent(t, 2, sm.tracker, testStateClosed)
-	verifySubcomponent(t, 3, sm.hw, testStateClosed)
-	assert.True(t, sm.se.(*testSchemaEngine).nonMaster)
+	sm.StopService()
+	assert.Equal(t, "NotConnected.MASTER", sm.CurrentStateLabel())
+	clock.Advance(1 * time.Second)
+
+	want := map[string]int64{
+		"Serving.REPLICA":     int64(10 * time.Second),
+		"NotServing.REPLICA":  int64(2 * time.Second),
+		"Serving.MASTER":      int64(5 * time.Second),
+		"NotConnected.MASTER": int64(1 * time.Second),
+	}
+	assert.Equal(t, want, sm.StateDurations())
 
-	verifySubcomponent(t, 4, sm.se, testStateOpen)
-	verifySubcomponent(t, 5, sm.vstreamer, testStateOpen)
-	verifySubcomponent(t, 6, sm.qe, testStateOpen)
-	verifySubcomponent(t, 7, sm.txThrottler, testStateOpen)
-	verifySubcomponent(t, 8, sm.te, testStateAcceptReadOnly)
-	verifySubcomponent(t, 9, sm.hr, testStateOpen)
-	verifySubcomponent(t, 10, sm.watcher, testStateOpen)
+	// The timer for the current state must restart after StopService.
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	clock.Advance(3 * time.Second)
+	want["Serving.MASTER"] += int64(3 * time.Second)
+	assert.Equal(t, want, sm.StateDurations())
+}
 
-	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.target.TabletType)
-	assert.Equal(t, StateServing, sm.state)
+type stateChange struct {
+	from, to   servingState
+	tabletType topodatapb.TabletType
 }
 
-func TestStateManagerTransitionFailRetry(t *testing.T) {
-	defer func(saved time.Duration) { transitionRetryInterval = saved }(transitionRetryInterval)
-	transitionRetryInterval = 10 * time.Millisecond
+func TestStateManagerSubscribeStateChanges(t *testing.T) {
+	sm := newTestStateManager(t)
+
+	var mu sync.Mutex
+	var calls []string
+	var changes []stateChange
+	unsub1 := sm.SubscribeStateChanges(func(from, to servingState, tabletType topodatapb.TabletType) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, "first")
+		changes = append(changes, stateChange{from: from, to: to, tabletType: tabletType})
+	})
+	sm.SubscribeStateChanges(func(from, to servingState, tabletType topodatapb.TabletType) {
+		// Calling back into sm must not deadlock.
+		_ = sm.State()
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, "second")
+	})
+
+	_, err := sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, []stateChange{{from: StateNotConnected, to: StateServing, tabletType: topodatapb.TabletType_REPLICA}}, changes)
+
+	// No change should not notify.
+	_, err = sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	require.NoError(t, err)
+	assert.Len(t, calls, 2)
+
+	calls, changes = nil, nil
+	sm.EnterLameduck()
+	sm.ExitLameduck()
+	assert.Equal(t, []stateChange{
+		{from: StateServing, to: StateNotServing, tabletType: topodatapb.TabletType_REPLICA},
+		{from: StateNotServing, to: StateServing, tabletType: topodatapb.TabletType_REPLICA},
+	}, changes)
+
+	calls, changes = nil, nil
+	unsub1()
+	sm.StopService()
+	assert.Equal(t, []string{"second"}, calls)
+	assert.Nil(t, changes)
+}
 
+func TestStateManagerWaitForState(t *testing.T) {
 	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	go sm.SetServingType(topodatapb.TabletType_REPLICA, StateServing, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sm.WaitForState(ctx, StateServing))
+	assert.Equal(t, StateServing, sm.State())
+
+	// Reaching the state after a retry also wakes up the waiter.
 	sm.qe.(*testQueryEngine).failMySQL = true
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateNotServing, nil)
+	require.Error(t, err)
+	require.NoError(t, sm.WaitForState(ctx, StateNotServing))
 
-	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer shortCancel()
+	err = sm.WaitForState(shortCtx, StateServing)
 	require.Error(t, err)
-	assert.True(t, stateChanged)
+	assert.Contains(t, err.Error(), "gave up waiting for state SERVING, current state: NOT_SERVING")
+	assert.Equal(t, vtrpcpb.Code_DEADLINE_EXCEEDED, vterrors.Code(err))
 
-	// Calling retryTransition while retrying should be a no-op.
-	sm.retryTransition("")
+	// The waiters unsubscribed.
+	sm.listenersMu.Lock()
+	assert.Empty(t, sm.listeners)
+	sm.listenersMu.Unlock()
 
-	// Steal the lock and wait long enough for the retry
-	// to fail, and then release it. The retry will have
-	// to keep retrying.
-	sm.transitioning.Acquire()
-	time.Sleep(30 * time.Millisecond)
-	sm.transitioning.Release()
+	// Wait for the retry to finish so that it doesn't outlive the test.
+	for sm.IsRetrying() {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
 
-	for {
-		sm.mu.Lock()
-		retrying := sm.retrying
-		sm.mu.Unlock()
-		if !retrying {
-			break
+func TestStateManagerIsRetrying(t *testing.T) {
+	sm := newTestStateManager(t)
+	sm.transitionRetryInterval = 10 * time.Millisecond
+	assert.False(t, sm.IsRetrying())
+
+	var failing sync2.AtomicInt32
+	failing.Set(1)
+	sm.SetMySQLProbe(func(ctx context.Context) error {
+		if failing.Get() != 0 {
+			return errors.New("probe error")
 		}
+		return nil
+	})
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.Error(t, err)
+	assert.True(t, sm.IsRetrying())
+
+	// WaitForState reports the retry when it gives up.
+	shortCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = sm.WaitForState(shortCtx, StateServing)
+	assert.Contains(t, err.Error(), "current state: NOT_SERVING (retrying)")
+
+	failing.Set(0)
+	for sm.IsRetrying() {
 		time.Sleep(10 * time.Millisecond)
 	}
-
-	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
 	assert.Equal(t, StateServing, sm.State())
 }
 
-func TestStateManagerRestoreType(t *testing.T) {
+func TestStateManagerSubscribeStateChangesPanic(t *testing.T) {
 	sm := newTestStateManager(t)
-	sm.EnterLameduck()
-	stateChanged, err := sm.SetServingType(topodatapb.TabletType_RESTORE, StateNotServing, nil)
+
+	var got []servingState
+	sm.SubscribeStateChanges(func(from, to servingState, tabletType topodatapb.TabletType) {
+		panic("intentional panic")
+	})
+	sm.SubscribeStateChanges(func(from, to servingState, tabletType topodatapb.TabletType) {
+		got = append(got, to)
+	})
+
+	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
 	require.NoError(t, err)
 	assert.True(t, stateChanged)
+	assert.Equal(t, []servingState{StateServing}, got)
 
-	assert.Equal(t, topodatapb.TabletType_RESTORE, sm.target.TabletType)
-	// RESTORE can only be in StateNotConnected.
-	assert.Equal(t, StateNotConnected, sm.state)
+	// A subsequent transition must still be possible.
+	assert.False(t, sm.isTransitioning())
+	_, err = sm.SetServingType(topodatapb.TabletType_MASTER, StateNotServing, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []servingState{StateServing, StateNotServing}, got)
+	assert.Equal(t, StateNotServing, sm.State())
 }
 
-func TestStateManagerCheckMySQL(t *testing.T) {
-	defer func(saved time.Duration) { transitionRetryInterval = saved }(transitionRetryInterval)
-	transitionRetryInterval = 10 * time.Millisecond
-
+func TestStateManagerStopServiceWithDrain(t *testing.T) {
 	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.target = *target
 
-	stateChanged, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
 	require.NoError(t, err)
-	assert.True(t, stateChanged)
-
-	sm.qe.(*testQueryEngine).failMySQL = true
-	order.Set(0)
-	sm.CheckMySQL()
 
-	// Rechecking immediately should be a no-op:
-	sm.CheckMySQL()
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+	require.NoError(t, sm.StartRequest(ctx, target, false))
 
-	// Wait for closeAll to get under way.
-	for {
-		if order.Get() >= 1 {
-			break
-		}
-		time.Sleep(10 * time.Millisecond)
-	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.StopServiceWithDrain(10 * time.Second)
+	}()
 
-	// Wait to get out of transitioning state.
+	// New requests must be rejected during the drain.
 	for {
-		if !sm.isTransitioning() {
+		if err := sm.StartRequest(ctx, target, false); err != nil {
+			assert.Contains(t, err.Error(), "SHUTTING_DOWN")
 			break
 		}
-		time.Sleep(10 * time.Millisecond)
+		sm.EndRequest()
+		time.Sleep(1 * time.Millisecond)
 	}
+	// The desired state is left to the shutdown transition.
+	sm.mu.Lock()
+	assert.Equal(t, StateServing, sm.wantState)
+	sm.mu.Unlock()
 
-	// Wait for retry to finish.
-	for {
-		sm.mu.Lock()
-		retrying := sm.retrying
-		sm.mu.Unlock()
-		if !retrying {
-			break
-		}
-		time.Sleep(10 * time.Millisecond)
-	}
+	sm.EndRequest()
+	sm.EndRequest()
+	<-done
 
-	assert.Equal(t, topodatapb.TabletType_MASTER, sm.Target().TabletType)
-	assert.Equal(t, StateServing, sm.State())
+	assert.Equal(t, map[string]int64{"Drained": 2, "DrainTimedOut": 0}, sm.DrainCounts())
+	assert.Equal(t, StateNotConnected, sm.State())
 }
 
-func TestStateManagerValidations(t *testing.T) {
+func TestStateManagerInFlightRequestInfo(t *testing.T) {
 	sm := newTestStateManager(t)
+	clock := newFakeClock()
+	sm.now = clock.Now
 	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
 	sm.target = *target
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
 
-	err := sm.StartRequest(ctx, target, false)
-	assert.Contains(t, err.Error(), "operation not allowed")
-
-	sm.state = StateServing
-	sm.wantState = StateNotServing
-	err = sm.StartRequest(ctx, target, false)
-	assert.Contains(t, err.Error(), "operation not allowed")
+	// Requests aren't tracked by default.
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+	untrack := sm.TrackRequest("Execute", target)
+	assert.Empty(t, sm.InFlightRequestInfo())
+	untrack()
+	sm.EndRequest()
 
-	err = sm.StartRequest(ctx, target, true)
-	assert.NoError(t, err)
+	sm.trackRequests = true
+	start1 := clock.Now()
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+	untrack1 := sm.TrackRequest("Execute", target)
+	clock.Advance(time.Second)
+	start2 := clock.Now()
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+	untrack2 := sm.TrackRequest("StreamExecute", target)
+
+	want := []RequestInfo{
+		{Method: "Execute", Start: start1, Target: target},
+		{Method: "StreamExecute", Start: start2, Target: target},
+	}
+	assert.Equal(t, want, sm.InFlightRequestInfo())
 
-	sm.wantState = StateServing
-	target.Keyspace = "a"
-	err = sm.StartRequest(ctx, target, false)
-	assert.Contains(t, err.Error(), "invalid keyspace")
-	err = sm.VerifyTarget(ctx, target)
-	assert.Contains(t, err.Error(), "invalid keyspace")
+	untrack1()
+	sm.EndRequest()
+	assert.Equal(t, want[1:], sm.InFlightRequestInfo())
+	untrack2()
+	sm.EndRequest()
+	assert.Empty(t, sm.InFlightRequestInfo())
+}
 
-	target.Keyspace = ""
-	target.Shard = "a"
-	err = sm.StartRequest(ctx, target, false)
-	assert.Contains(t, err.Error(), "invalid shard")
-	err = sm.VerifyTarget(ctx, target)
-	assert.Contains(t, err.Error(), "invalid shard")
+func TestStateManagerInFlightRequestInfoWhileDraining(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.target = *target
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	require.NoError(t, sm.StartRequest(ctx, target, false))
 
-	target.Shard = ""
-	target.TabletType = topodatapb.TabletType_REPLICA
-	err = sm.StartRequest(ctx, target, false)
-	assert.Contains(t, err.Error(), "invalid tablet type")
-	err = sm.VerifyTarget(ctx, target)
-	assert.Contains(t, err.Error(), "invalid tablet type")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.StopServiceWithDrain(10 * time.Second)
+	}()
+	for !sm.draining.Get() {
+		time.Sleep(1 * time.Millisecond)
+	}
 
-	sm.alsoAllow = []topodatapb.TabletType{topodatapb.TabletType_REPLICA}
-	err = sm.StartRequest(ctx, target, false)
-	assert.NoError(t, err)
-	err = sm.VerifyTarget(ctx, target)
-	assert.NoError(t, err)
+	// Requests allowed on shutdown are tracked during the drain.
+	require.NoError(t, sm.StartRequest(ctx, target, true))
+	untrack := sm.TrackRequest("Commit", target)
+	infos := sm.InFlightRequestInfo()
+	require.Len(t, infos, 1)
+	assert.Equal(t, "Commit", infos[0].Method)
+	assert.Equal(t, target, infos[0].Target)
 
-	err = sm.StartRequest(ctx, nil, false)
-	assert.Contains(t, err.Error(), "No target")
-	err = sm.VerifyTarget(ctx, nil)
-	assert.Contains(t, err.Error(), "No target")
+	untrack()
+	sm.EndRequest()
+	sm.EndRequest()
+	<-done
+	assert.Empty(t, sm.InFlightRequestInfo())
 
-	localctx := tabletenv.LocalContext()
-	err = sm.StartRequest(localctx, nil, false)
-	assert.NoError(t, err)
-	err = sm.VerifyTarget(localctx, nil)
-	assert.NoError(t, err)
+	// Tracking stops with the drain.
+	untrack = sm.TrackRequest("Execute", target)
+	defer untrack()
+	assert.Empty(t, sm.InFlightRequestInfo())
 }
 
-func TestStateManagerWaitForRequests(t *testing.T) {
+func TestStateManagerStopServiceWithDrainTimeout(t *testing.T) {
 	sm := newTestStateManager(t)
 	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
 	sm.target = *target
-	sm.timebombDuration = 10 * time.Second
+	// The timebomb would crash the test.
+	sm.timebombDuration = 0
 
 	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
 	require.NoError(t, err)
+	require.NoError(t, sm.StartRequest(ctx, target, false))
 
-	err = sm.StartRequest(ctx, target, false)
-	require.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.StopServiceWithDrain(10 * time.Millisecond)
+	}()
 
-	// This will go into transition and wait.
-	// Wait for that state.
-	go sm.StopService()
-	for {
-		if !sm.isTransitioning() {
-			time.Sleep(10 * time.Millisecond)
-			continue
-		}
-		break
+	// Wait for the soft drain to expire. The shutdown then
+	// blocks until the request completes.
+	for sm.DrainCounts()["DrainTimedOut"] == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, map[string]int64{"Drained": 0, "DrainTimedOut": 1}, sm.DrainCounts())
+	for !sm.isTransitioning() {
+		time.Sleep(5 * time.Millisecond)
 	}
-
-	// Verify that we're still transitioning.
-	assert.True(t, sm.isTransitioning())
 
 	sm.EndRequest()
+	<-done
+	assert.Equal(t, StateNotConnected, sm.State())
+}
 
-	for {
-		if sm.isTransitioning() {
-			time.Sleep(10 * time.Millisecond)
-			continue
-		}
-		break
+func TestStateManagerTimebombHandler(t *testing.T) {
+	sm := newTestStateManager(t)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.target = *target
+	sm.timebombDuration = 10 * time.Millisecond
+	inflight := make(chan int64, 1)
+	sm.SetTimebombHandler(func(n int64) {
+		inflight <- n
+	})
+	crashed := make(chan struct{})
+	sm.crash = func(args ...interface{}) {
+		close(crashed)
 	}
+
+	_, err := sm.SetServingType(topodatapb.TabletType_MASTER, StateServing, nil)
+	require.NoError(t, err)
+	require.NoError(t, sm.StartRequest(ctx, target, false))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.StopService()
+	}()
+
+	// The request blocks the shutdown until the timebomb fires.
+	assert.Equal(t, int64(1), <-inflight)
+	<-crashed
+
+	sm.EndRequest()
+	<-done
 	assert.Equal(t, StateNotConnected, sm.State())
 }
 
@@ -470,10 +2956,38 @@ func newTestStateManager(t *testing.T) *stateManager {
 		transitioning:       sync2.NewSemaphore(1, 0),
 		checkMySQLThrottler: sync2.NewSemaphore(1, 0),
 		history:             history.New(10),
+		transitions:         history.New(transitionHistorySize),
 		timebombDuration:    time.Duration(10 * time.Millisecond),
+		crash:               log.Fatal,
+		now:                 time.Now,
+
+		transitionRetryInterval: defaultTransitionRetryInterval,
+		restorePollInterval:     defaultRestorePollInterval,
 	}
 }
 
+// fakeClock is a manually advanced clock for tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = fc.now.Add(d)
+}
+
 func (sm *stateManager) isTransitioning() bool {
 	if sm.transitioning.TryAcquire() {
 		sm.transitioning.Release()
@@ -482,6 +2996,92 @@ func (sm *stateManager) isTransitioning() bool {
 	return true
 }
 
+type testTransitionObservation struct {
+	from, to servingState
+	d        time.Duration
+	err      error
+}
+
+type testTransitionSink struct {
+	mu           sync.Mutex
+	observations []testTransitionObservation
+}
+
+func (ts *testTransitionSink) ObserveTransition(from, to servingState, d time.Duration, err error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.observations = append(ts.observations, testTransitionObservation{from: from, to: to, d: d, err: err})
+}
+
+// reset returns the observations, and clears them.
+func (ts *testTransitionSink) reset() []testTransitionObservation {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	observations := ts.observations
+	ts.observations = nil
+	return observations
+}
+
+type testDurationRecorder struct {
+	mu        sync.Mutex
+	durations map[string][]time.Duration
+	// names are the recorded names, in order.
+	names []string
+}
+
+func (tr *testDurationRecorder) Add(name string, elapsed time.Duration) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.durations == nil {
+		tr.durations = make(map[string][]time.Duration)
+	}
+	tr.durations[name] = append(tr.durations[name], elapsed)
+	tr.names = append(tr.names, name)
+}
+
+// reset returns the recorded names, and clears them.
+func (tr *testDurationRecorder) reset() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	names := tr.names
+	tr.names = nil
+	return names
+}
+
+func (tr *testDurationRecorder) get(name string) []time.Duration {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.durations[name]
+}
+
+// testClockSchemaEngine advances the clock when opened or closed.
+type testClockSchemaEngine struct {
+	testSchemaEngine
+	fc          *fakeClock
+	open, close time.Duration
+}
+
+func (te *testClockSchemaEngine) Open() error {
+	te.fc.Advance(te.open)
+	return te.testSchemaEngine.Open()
+}
+
+func (te *testClockSchemaEngine) Close() {
+	te.fc.Advance(te.close)
+	te.testSchemaEngine.Close()
+}
+
+// testHookSubcomponent calls onOpen when opened.
+type testHookSubcomponent struct {
+	testSubcomponent
+	onOpen func()
+}
+
+func (te *testHookSubcomponent) Open() {
+	te.onOpen()
+	te.testSubcomponent.Open()
+}
+
 var order sync2.AtomicInt64
 
 type testState int
@@ -538,9 +3138,14 @@ type testQueryEngine struct {
 	stopServing bool
 
 	failMySQL bool
+	failOpen  bool
 }
 
 func (te *testQueryEngine) Open() error {
+	if te.failOpen {
+		te.failOpen = false
+		return errors.New("intentional open error")
+	}
 	te.order = order.Add(1)
 	te.state = testStateOpen
 	return nil
@@ -566,6 +3171,7 @@ func (te *testQueryEngine) Close() {
 
 type testTxEngine struct {
 	testOrderState
+	failAcceptReadOnly bool
 }
 
 func (te *testTxEngine) AcceptReadWrite() error {
@@ -576,6 +3182,9 @@ func (te *testTxEngine) AcceptReadWrite() error {
 
 func (te *testTxEngine) AcceptReadOnly() error {
 	te.order = order.Add(1)
+	if te.failAcceptReadOnly {
+		return errors.New("tx engine can't accept read-only")
+	}
 	te.state = testStateAcceptReadOnly
 	return nil
 }
@@ -599,6 +3208,17 @@ func (te *testSubcomponent) Close() {
 	te.state = testStateClosed
 }
 
+// testClosesSubcomponent counts how many times it was closed.
+type testClosesSubcomponent struct {
+	testSubcomponent
+	closes int
+}
+
+func (te *testClosesSubcomponent) Close() {
+	te.closes++
+	te.testSubcomponent.Close()
+}
+
 type testTxThrottler struct {
 	testOrderState
 }
@@ -613,3 +3233,36 @@ func (te *testTxThrottler) Close() {
 	te.order = order.Add(1)
 	te.state = testStateClosed
 }
+
+func BenchmarkStateManagerVerifyTarget(b *testing.B) {
+	sm := newTestStateManager(nil)
+	sm.target = querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+	sm.alsoAllow = []topodatapb.TabletType{topodatapb.TabletType_REPLICA}
+	allowed := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+	rejected := &querypb.Target{TabletType: topodatapb.TabletType_RDONLY}
+
+	b.Run("VerifyTarget/allowed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = sm.VerifyTarget(ctx, allowed)
+		}
+	})
+	b.Run("VerifyTarget/rejected", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = sm.VerifyTarget(ctx, rejected)
+		}
+	})
+	b.Run("VerifyTargetOK/allowed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = sm.VerifyTargetOK(ctx, allowed)
+		}
+	})
+	b.Run("VerifyTargetOK/rejected", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = sm.VerifyTargetOK(ctx, rejected)
+		}
+	})
+}
@@ -78,6 +78,15 @@ func (sc *StatefulConnection) IsInTransaction() bool {
 	return sc.txProps != nil
 }
 
+// Warnings returns the warnings raised by the last query executed
+// by Exec.
+func (sc *StatefulConnection) Warnings(ctx context.Context) ([]string, error) {
+	if sc.IsClosed() {
+		return nil, nil
+	}
+	return sc.dbConn.Warnings(ctx)
+}
+
 // Exec executes the statement in the dedicated connection
 func (sc *StatefulConnection) Exec(ctx context.Context, query string, maxrows int, wantfields bool) (*sqltypes.Result, error) {
 	if sc.IsClosed() {
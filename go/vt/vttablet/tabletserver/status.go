@@ -85,12 +85,14 @@ var (
     <th>Time</th>
     <th>Target Tablet Type</th>
     <th>Serving State</th>
+    <th>Reason</th>
   </tr>
   {{range .History}}
   <tr>
     <td>{{.Time.Format "Jan 2, 2006 at 15:04:05 (MST)"}}</td>
     <td>{{.TabletType}}</td>
     <td>{{.ServingState}}</td>
+    <td>{{.Reason}}</td>
   </tr>
   {{end}}
 </table>
@@ -209,6 +211,7 @@ type historyRecord struct {
 	Time         time.Time
 	TabletType   string
 	ServingState string
+	Reason       string
 }
 
 // IsDuplicate implements history.Deduplicable
@@ -0,0 +1,41 @@
+/*
+Copyright 2020 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"golang.org/x/net/context"
+)
+
+type backupEngineKey int
+
+// WithBackupEngine returns a context that carries the name of the
+// backup engine, e.g. builtin or xtrabackup, on behalf of which the
+// requests made with it run. It's recorded in their LogStats, so that
+// queries issued during a backup or restore can be told apart.
+func WithBackupEngine(ctx context.Context, engine string) context.Context {
+	return context.WithValue(ctx, backupEngineKey(0), engine)
+}
+
+// BackupEngineFromContext returns the backup engine name carried by
+// ctx, or "".
+func BackupEngineFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	engine, _ := ctx.Value(backupEngineKey(0)).(string)
+	return engine
+}
@@ -29,6 +29,9 @@ import (
 	"vitess.io/vitess/go/vt/dbconfigs"
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/throttler"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 )
 
 // These constants represent values for various config parameters.
@@ -72,6 +75,7 @@ var (
 	enableConsolidatorReplicas   bool
 	enableHeartbeat              bool
 	heartbeatInterval            time.Duration
+	lameduckPeriodByType         flagutil.StringMapValue
 )
 
 func init() {
@@ -140,6 +144,23 @@ func init() {
 	flag.BoolVar(&enableConsolidator, "enable-consolidator", true, "This option enables the query consolidator.")
 	flag.BoolVar(&enableConsolidatorReplicas, "enable-consolidator-replicas", false, "This option enables the query consolidator only on replicas.")
 	flag.BoolVar(&currentConfig.CacheResultFields, "enable-query-plan-field-caching", defaultConfig.CacheResultFields, "This option fetches & caches fields (columns) when storing query plans")
+
+	flag.Float64Var(&currentConfig.LameduckPeriodSeconds, "queryserver-config-lameduck-period", defaultConfig.LameduckPeriodSeconds, "query server lameduck period (in seconds). After entering lameduck, state transitions are delayed until this period has elapsed, giving clients time to notice the new health status.")
+	flag.Float64Var(&currentConfig.TransitionRetry.InitialSeconds, "queryserver-config-transition-retry-initial", defaultConfig.TransitionRetry.InitialSeconds, "query server initial delay (in seconds) before retrying a failed state transition. If 0, a fixed 1s interval is used.")
+	flag.Float64Var(&currentConfig.TransitionRetry.MaxSeconds, "queryserver-config-transition-retry-max", defaultConfig.TransitionRetry.MaxSeconds, "query server maximum delay (in seconds) between state transition retries. If 0, the delay is not capped.")
+	flag.Float64Var(&currentConfig.TransitionRetry.Multiplier, "queryserver-config-transition-retry-multiplier", defaultConfig.TransitionRetry.Multiplier, "query server multiplier applied to the state transition retry delay after every failed attempt. Values below 1 are treated as 1.")
+	flag.Float64Var(&currentConfig.TransitionRetry.Jitter, "queryserver-config-transition-retry-jitter", defaultConfig.TransitionRetry.Jitter, "query server jitter applied to the state transition retry delay, as a fraction of the delay, between 0 and 1.")
+	flag.Float64Var(&currentConfig.TransitionSLOSeconds, "queryserver-config-transition-slo", defaultConfig.TransitionSLOSeconds, "query server duration (in seconds) beyond which a state transition is counted and logged as slow. If 0, transitions are not checked.")
+	flag.Float64Var(&currentConfig.MaxTransitionWaitSeconds, "queryserver-config-max-transition-wait", defaultConfig.MaxTransitionWaitSeconds, "query server maximum time (in seconds) a serving type change waits for a state transition already in progress. Beyond it, the change fails with a transition busy error. If 0, it waits indefinitely.")
+	flag.BoolVar(&currentConfig.LogTransitionsJSON, "queryserver-config-log-transitions-json", defaultConfig.LogTransitionsJSON, "If true, vttablet logs every state transition attempt as a single line of JSON with its from and to states, tablet type, duration and error, for consumption by log aggregators.")
+	flag.Float64Var(&currentConfig.TransitionLogIntervalSeconds, "queryserver-config-transition-log-interval", defaultConfig.TransitionLogIntervalSeconds, "query server minimum interval (in seconds) between two logs of identical state transitions. Identical transitions within the interval are suppressed, and their number is reported with the next log. If 0, every transition is logged.")
+	flag.BoolVar(&currentConfig.TrackInFlightRequests, "queryserver-config-track-inflight-requests", defaultConfig.TrackInFlightRequests, "If true, vttablet keeps the method, start time and target of every request in flight, so that the requests holding back a drain can be identified. They're only kept while draining otherwise.")
+	flag.Float64Var(&currentConfig.CheckMySQLIntervalSeconds, "queryserver-config-check-mysql-interval", defaultConfig.CheckMySQLIntervalSeconds, "query server minimum interval (in seconds) between two mysql connectivity checks triggered by query errors. If 0, 1s is used.")
+	flag.Float64Var(&currentConfig.CheckMySQLJitter, "queryserver-config-check-mysql-jitter", defaultConfig.CheckMySQLJitter, "query server jitter applied to the mysql connectivity check interval, as a fraction of the interval, between 0 and 1. This spreads out the checks of tablets that lose mysql at the same time.")
+	flag.BoolVar(&currentConfig.VerifyReadOnly, "queryserver-config-verify-read-only", defaultConfig.VerifyReadOnly, "If true, vttablet verifies that mysql has super_read_only set after it starts serving as a non-master, and retries the transition until it does. Requires -use_super_read_only.")
+	flag.Float64Var(&currentConfig.MaxReplicaLagSeconds, "queryserver-config-max-replica-lag", defaultConfig.MaxReplicaLagSeconds, "query server maximum replication lag (in seconds), as measured by heartbeat, beyond which requests to a non-master are rejected. If 0, requests are not rejected for lag. Requires -heartbeat_enable.")
+	flag.Float64Var(&currentConfig.DegradedReplicaLagSeconds, "queryserver-config-degraded-replica-lag", defaultConfig.DegradedReplicaLagSeconds, "query server replication lag (in seconds), as measured by heartbeat, beyond which a serving non-master reports itself as degraded in the TabletServerDegraded gauge. It keeps serving queries. If 0, the tablet never reports degraded. Requires -heartbeat_enable.")
+	flag.Var(&lameduckPeriodByType, "queryserver-config-lameduck-period-by-type", "comma separated list of tablet_type:duration pairs, e.g. master:1s,replica:10s. Overrides -queryserver-config-lameduck-period for the specified tablet types.")
 }
 
 // Init must be called after flag.Parse, and before doing any other operations.
@@ -172,11 +193,23 @@ func Init() {
 		currentConfig.HeartbeatIntervalSeconds = float64(heartbeatInterval) / float64(time.Second)
 	}
 
+	if len(lameduckPeriodByType) != 0 {
+		currentConfig.LameduckPeriodsByType = make(map[string]float64, len(lameduckPeriodByType))
+		for tabletType, value := range lameduckPeriodByType {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				log.Exitf("Invalid queryserver-config-lameduck-period-by-type value for %v: %v", tabletType, err)
+			}
+			currentConfig.LameduckPeriodsByType[tabletType] = d.Seconds()
+		}
+	}
+
 	switch *streamlog.QueryLogFormat {
 	case streamlog.QueryLogFormatText:
 	case streamlog.QueryLogFormatJSON:
+	case streamlog.QueryLogFormatJSON2:
 	default:
-		log.Exitf("Invalid querylog-format value %v: must be either text or json", *streamlog.QueryLogFormat)
+		log.Exitf("Invalid querylog-format value %v: must be either text, json or json2", *streamlog.QueryLogFormat)
 	}
 
 	if *queryLogHandler != "" {
@@ -211,6 +244,44 @@ type TabletConfig struct {
 	TerseErrors                 bool    `json:"terseErrors,omitempty"`
 	MessagePostponeParallelism  int     `json:"messagePostponeParallelism,omitempty"`
 	CacheResultFields           bool    `json:"cacheResultFields,omitempty"`
+	LameduckPeriodSeconds       float64 `json:"lameduckPeriodSeconds,omitempty"`
+
+	// LameduckPeriodsByType overrides LameduckPeriodSeconds for specific
+	// tablet types. The keys are tablet type names.
+	LameduckPeriodsByType map[string]float64 `json:"lameduckPeriodsByType,omitempty"`
+
+	TransitionRetry TransitionRetryConfig `json:"transitionRetry,omitempty"`
+	// TransitionSLOSeconds is the duration beyond which a state
+	// transition is counted as slow. Zero disables the check.
+	TransitionSLOSeconds float64 `json:"transitionSLOSeconds,omitempty"`
+	// MaxTransitionWaitSeconds bounds how long a serving type change
+	// waits for a transition in progress. Zero means no bound.
+	MaxTransitionWaitSeconds float64 `json:"maxTransitionWaitSeconds,omitempty"`
+	// LogTransitionsJSON makes every transition attempt
+	// be logged as a line of JSON.
+	LogTransitionsJSON bool `json:"logTransitionsJSON,omitempty"`
+	// TransitionLogIntervalSeconds is the minimum interval between
+	// two logs of identical transitions. Zero logs all of them.
+	TransitionLogIntervalSeconds float64 `json:"transitionLogIntervalSeconds,omitempty"`
+	// TrackInFlightRequests keeps a description of every request
+	// in flight, not only while draining.
+	TrackInFlightRequests bool `json:"trackInFlightRequests,omitempty"`
+	// CheckMySQLIntervalSeconds is the minimum interval between two
+	// mysql checks triggered by errors. Zero means 1s. CheckMySQLJitter
+	// randomly varies it by that fraction, between 0 and 1.
+	CheckMySQLIntervalSeconds float64 `json:"checkMySQLIntervalSeconds,omitempty"`
+	CheckMySQLJitter          float64 `json:"checkMySQLJitter,omitempty"`
+
+	// VerifyReadOnly makes non-master transitions fail, and retry,
+	// until mysql reports super_read_only.
+	VerifyReadOnly bool `json:"verifyReadOnly,omitempty"`
+	// MaxReplicaLagSeconds makes a non-master reject requests while
+	// its heartbeat lag exceeds it. Zero disables the check.
+	MaxReplicaLagSeconds float64 `json:"maxReplicaLagSeconds,omitempty"`
+	// DegradedReplicaLagSeconds makes a serving non-master report
+	// itself as degraded while its heartbeat lag exceeds it. Zero
+	// disables the check.
+	DegradedReplicaLagSeconds float64 `json:"degradedReplicaLagSeconds,omitempty"`
 
 	ExternalConnections map[string]*dbconfigs.DBConfigs `json:"externalConnections,omitempty"`
 
@@ -255,6 +326,15 @@ type HotRowProtectionConfig struct {
 	MaxConcurrency     int    `json:"maxConcurrency,omitempty"`
 }
 
+// TransitionRetryConfig contains the backoff policy for
+// retrying failed state transitions.
+type TransitionRetryConfig struct {
+	InitialSeconds float64 `json:"initialSeconds,omitempty"`
+	MaxSeconds     float64 `json:"maxSeconds,omitempty"`
+	Multiplier     float64 `json:"multiplier,omitempty"`
+	Jitter         float64 `json:"jitter,omitempty"`
+}
+
 // TransactionLimitConfig captures configuration of transaction pool slots
 // limiter configuration.
 type TransactionLimitConfig struct {
@@ -303,9 +383,81 @@ func (c *TabletConfig) Verify() error {
 	if v := c.HotRowProtection.MaxConcurrency; v <= 0 {
 		return fmt.Errorf("-hot_row_protection_concurrent_transactions must be > 0 (specified value: %v)", v)
 	}
+	if err := c.verifyLameduckConfig(); err != nil {
+		return err
+	}
+	if err := c.verifyTransitionRetryConfig(); err != nil {
+		return err
+	}
+	if v := c.CheckMySQLIntervalSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-check-mysql-interval must be >= 0 (specified value: %v)", v)
+	}
+	if v := c.CheckMySQLJitter; v < 0 || v > 1 {
+		return fmt.Errorf("-queryserver-config-check-mysql-jitter must be within [0, 1] (specified value: %v)", v)
+	}
+	if v := c.TransitionSLOSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-transition-slo must be >= 0 (specified value: %v)", v)
+	}
+	if v := c.MaxTransitionWaitSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-max-transition-wait must be >= 0 (specified value: %v)", v)
+	}
+	if v := c.MaxReplicaLagSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-max-replica-lag must be >= 0 (specified value: %v)", v)
+	}
+	if v := c.DegradedReplicaLagSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-degraded-replica-lag must be >= 0 (specified value: %v)", v)
+	}
 	return nil
 }
 
+// verifyTransitionRetryConfig checks the transition retry backoff policy.
+func (c *TabletConfig) verifyTransitionRetryConfig() error {
+	if v := c.TransitionRetry.InitialSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-transition-retry-initial must be >= 0 (specified value: %v)", v)
+	}
+	if v := c.TransitionRetry.MaxSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-transition-retry-max must be >= 0 (specified value: %v)", v)
+	}
+	if v := c.TransitionRetry.Multiplier; v < 0 {
+		return fmt.Errorf("-queryserver-config-transition-retry-multiplier must be >= 0 (specified value: %v)", v)
+	}
+	if v := c.TransitionRetry.Jitter; v < 0 || v > 1 {
+		return fmt.Errorf("-queryserver-config-transition-retry-jitter must be within [0, 1] (specified value: %v)", v)
+	}
+	return nil
+}
+
+// verifyLameduckConfig checks that lameduck periods are non-negative
+// and that per-type overrides refer to valid tablet types.
+func (c *TabletConfig) verifyLameduckConfig() error {
+	if v := c.LameduckPeriodSeconds; v < 0 {
+		return fmt.Errorf("-queryserver-config-lameduck-period must be >= 0 (specified value: %v)", v)
+	}
+	for tabletType, v := range c.LameduckPeriodsByType {
+		if _, err := topoproto.ParseTabletType(tabletType); err != nil {
+			return fmt.Errorf("-queryserver-config-lameduck-period-by-type: %v", err)
+		}
+		if v < 0 {
+			return fmt.Errorf("-queryserver-config-lameduck-period-by-type must be >= 0 (specified value for %v: %v)", tabletType, v)
+		}
+	}
+	return nil
+}
+
+// LameduckPeriods returns LameduckPeriodsByType keyed by tablet type.
+// Invalid entries are skipped. They're reported by Verify.
+func (c *TabletConfig) LameduckPeriods() map[topodatapb.TabletType]time.Duration {
+	periods := make(map[topodatapb.TabletType]time.Duration, len(c.LameduckPeriodsByType))
+	for name, v := range c.LameduckPeriodsByType {
+		tabletType, err := topoproto.ParseTabletType(name)
+		if err != nil {
+			continue
+		}
+		periods[tabletType] = time.Duration(v * 1e9)
+	}
+	return periods
+}
+
 // verifyTransactionLimitConfig checks TransactionLimitConfig for sanity
 func (c *TabletConfig) verifyTransactionLimitConfig() error {
 	actual, dryRun := c.EnableTransactionLimit, c.EnableTransactionLimitDryRun
@@ -23,6 +23,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"vitess.io/vitess/go/vt/dbconfigs"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	"vitess.io/vitess/go/yaml2"
 )
 
@@ -72,6 +73,7 @@ oltpReadPool:
   prefillParallelism: 30
   size: 16
   timeoutSeconds: 10
+transitionRetry: {}
 txPool: {}
 `
 	assert.Equal(t, wantBytes, string(gotBytes))
@@ -124,6 +126,7 @@ oltpReadPool:
 queryCacheSize: 5000
 schemaReloadIntervalSeconds: 1800
 streamBufferSize: 32768
+transitionRetry: {}
 txPool:
   idleTimeoutSeconds: 1800
   maxWaiters: 5000
@@ -266,3 +269,103 @@ func TestFlags(t *testing.T) {
 	want.HeartbeatIntervalSeconds = 0
 	assert.Equal(t, want, currentConfig)
 }
+
+func TestVerifyLameduckConfig(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.LameduckPeriodSeconds = 1
+	cfg.LameduckPeriodsByType = map[string]float64{
+		"master":  0.5,
+		"REPLICA": 10,
+	}
+	require.NoError(t, cfg.Verify())
+	assert.Equal(t, map[topodatapb.TabletType]time.Duration{
+		topodatapb.TabletType_MASTER:  500 * time.Millisecond,
+		topodatapb.TabletType_REPLICA: 10 * time.Second,
+	}, cfg.LameduckPeriods())
+
+	cfg.LameduckPeriodSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-lameduck-period must be >= 0 (specified value: -1)")
+
+	cfg.LameduckPeriodSeconds = 0
+	cfg.LameduckPeriodsByType = map[string]float64{"replica": -1}
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-lameduck-period-by-type must be >= 0 (specified value for replica: -1)")
+
+	cfg.LameduckPeriodsByType = map[string]float64{"bogus": 1}
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-lameduck-period-by-type: unknown TabletType bogus")
+}
+
+func TestVerifyTransitionRetryConfig(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TransitionRetry = TransitionRetryConfig{
+		InitialSeconds: 0.1,
+		MaxSeconds:     10,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+	require.NoError(t, cfg.Verify())
+
+	cfg.TransitionRetry.InitialSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-transition-retry-initial must be >= 0 (specified value: -1)")
+
+	cfg.TransitionRetry.InitialSeconds = 0.1
+	cfg.TransitionRetry.MaxSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-transition-retry-max must be >= 0 (specified value: -1)")
+
+	cfg.TransitionRetry.MaxSeconds = 10
+	cfg.TransitionRetry.Multiplier = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-transition-retry-multiplier must be >= 0 (specified value: -1)")
+
+	cfg.TransitionRetry.Multiplier = 2
+	cfg.TransitionRetry.Jitter = 1.5
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-transition-retry-jitter must be within [0, 1] (specified value: 1.5)")
+}
+
+func TestVerifyCheckMySQLConfig(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.CheckMySQLIntervalSeconds = 2
+	cfg.CheckMySQLJitter = 0.25
+	require.NoError(t, cfg.Verify())
+
+	cfg.CheckMySQLIntervalSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-check-mysql-interval must be >= 0 (specified value: -1)")
+
+	cfg.CheckMySQLIntervalSeconds = 2
+	cfg.CheckMySQLJitter = 1.5
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-check-mysql-jitter must be within [0, 1] (specified value: 1.5)")
+}
+
+func TestVerifyTransitionSLO(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TransitionSLOSeconds = 2.5
+	require.NoError(t, cfg.Verify())
+
+	cfg.TransitionSLOSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-transition-slo must be >= 0 (specified value: -1)")
+}
+
+func TestVerifyMaxTransitionWait(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.MaxTransitionWaitSeconds = 5
+	require.NoError(t, cfg.Verify())
+
+	cfg.MaxTransitionWaitSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-max-transition-wait must be >= 0 (specified value: -1)")
+}
+
+func TestVerifyMaxReplicaLag(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.MaxReplicaLagSeconds = 30
+	require.NoError(t, cfg.Verify())
+
+	cfg.MaxReplicaLagSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-max-replica-lag must be >= 0 (specified value: -1)")
+}
+
+func TestVerifyDegradedReplicaLag(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.DegradedReplicaLagSeconds = 10
+	require.NoError(t, cfg.Verify())
+
+	cfg.DegradedReplicaLagSeconds = -1
+	assert.EqualError(t, cfg.Verify(), "-queryserver-config-degraded-replica-lag must be >= 0 (specified value: -1)")
+}
@@ -19,6 +19,7 @@ limitations under the License.
 package tabletenv
 
 import (
+	"vitess.io/vitess/go/sync2"
 	"vitess.io/vitess/go/tb"
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/servenv"
@@ -32,12 +33,16 @@ type Env interface {
 	Exporter() *servenv.Exporter
 	Stats() *Stats
 	LogError()
+	// NextRequestSeq returns the next number in a monotonically
+	// increasing sequence, which orders the requests of the tablet.
+	NextRequestSeq() int64
 }
 
 type testEnv struct {
-	config   *TabletConfig
-	exporter *servenv.Exporter
-	stats    *Stats
+	config     *TabletConfig
+	exporter   *servenv.Exporter
+	stats      *Stats
+	requestSeq sync2.AtomicInt64
 }
 
 // NewEnv creates an Env that can be used for tabletserver subcomponents
@@ -55,6 +60,7 @@ func (*testEnv) CheckMySQL()                    {}
 func (te *testEnv) Config() *TabletConfig       { return te.config }
 func (te *testEnv) Exporter() *servenv.Exporter { return te.exporter }
 func (te *testEnv) Stats() *Stats               { return te.stats }
+func (te *testEnv) NextRequestSeq() int64       { return te.requestSeq.Add(1) }
 
 func (te *testEnv) LogError() {
 	if x := recover(); x != nil {
@@ -0,0 +1,61 @@
+/*
+Copyright 2020 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"golang.org/x/net/context"
+)
+
+type logTagsKey int
+
+// LogTagsExtractor returns the tags to attach to the LogStats
+// of a request made with ctx.
+type LogTagsExtractor func(ctx context.Context) map[string]string
+
+// logTagsExtractor is used by NewLogStats.
+var logTagsExtractor LogTagsExtractor = LogTagsFromContext
+
+// SetLogTagsExtractor replaces the function that NewLogStats uses to
+// obtain the tags of a request. The default is LogTagsFromContext.
+// It must be called before serving starts.
+func SetLogTagsExtractor(extractor LogTagsExtractor) {
+	logTagsExtractor = extractor
+}
+
+// WithLogTags returns a context that carries tags in addition to the
+// ones already carried by ctx. A tag of ctx with the same key is
+// overridden.
+func WithLogTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range LogTagsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, logTagsKey(0), merged)
+}
+
+// LogTagsFromContext returns the tags carried by ctx, or nil. The
+// returned map must not be modified.
+func LogTagsFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	tags, _ := ctx.Value(logTagsKey(0)).(map[string]string)
+	return tags
+}
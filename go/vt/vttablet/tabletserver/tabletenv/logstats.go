@@ -17,21 +17,37 @@ limitations under the License.
 package tabletenv
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"math/rand"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 
+	"vitess.io/vitess/go/flagutil"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/streamlog"
 	"vitess.io/vitess/go/vt/callerid"
 	"vitess.io/vitess/go/vt/callinfo"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
 const (
@@ -41,44 +57,404 @@ const (
 	QuerySourceMySQL
 )
 
+// EmitQuerySourceTimings controls whether the time attributed to each
+// query source is appended to the query log. It's off by default to
+// keep the log format unchanged for existing parsers.
+var EmitQuerySourceTimings = flag.Bool("querylog-emit-source-timings", false, "include the time spent in each query source (mysql, consolidator) in the query log")
+
+// suppressedQuerySources is the mask of query source flags that are
+// left out of FmtQuerySources. The flags are still set in QuerySources.
+var suppressedQuerySources byte
+
+// querySourceNames maps the names used in FmtQuerySources to the
+// query source flags.
+var querySourceNames = map[string]byte{
+	"mysql":        QuerySourceMySQL,
+	"consolidator": QuerySourceConsolidator,
+}
+
+// suppressedQuerySourcesFlag sets suppressedQuerySources from a comma
+// separated list of query source names.
+type suppressedQuerySourcesFlag struct {
+	names flagutil.StringListValue
+}
+
+func (f *suppressedQuerySourcesFlag) Set(v string) error {
+	var names flagutil.StringListValue
+	if err := names.Set(v); err != nil {
+		return err
+	}
+	if err := SetSuppressedQuerySources(names); err != nil {
+		return err
+	}
+	f.names = names
+	return nil
+}
+
+func (f *suppressedQuerySourcesFlag) String() string {
+	return f.names.String()
+}
+
+func init() {
+	flag.Var(&suppressedQuerySourcesFlag{}, "querylog-suppressed-sources", "comma separated list of query sources (mysql, consolidator) to leave out of the query sources in the query log, e.g. consolidator to log consolidated reads as plain mysql reads")
+}
+
+// SetSuppressedQuerySources sets the query sources, by name, that are
+// left out of FmtQuerySources. The query source flags themselves are
+// unaffected, so they still show up in metrics. It's not safe to call
+// concurrently with FmtQuerySources.
+func SetSuppressedQuerySources(names []string) error {
+	var mask byte
+	for _, name := range names {
+		source, ok := querySourceNames[name]
+		if !ok {
+			return fmt.Errorf("unknown query source: %q", name)
+		}
+		mask |= source
+	}
+	suppressedQuerySources = mask
+	return nil
+}
+
+// RedactSQL causes the query log to record normalized statements,
+// with literal values replaced by bind variable placeholders, and
+// to suppress bind variables.
+var RedactSQL = flag.Bool("querylog-redact-sql", false, "log normalized statements without literal values, and suppress bind variables in the query log")
+
+var (
+	// LogSampleRate causes only 1 in LogSampleRate queries to be logged.
+	// A rate of 0 disables sampling, in which case only queries matched
+	// by LogSlowThreshold, or that failed, are logged.
+	LogSampleRate = flag.Int("querylog-sample-rate", 1, "log 1 in N queries; 0 logs only slow or failed queries")
+
+	// LogSlowThreshold causes queries that take at least this long
+	// to be logged regardless of sampling.
+	LogSlowThreshold = flag.Duration("querylog-slow-threshold", 0, "log queries that take at least this long regardless of querylog-sample-rate")
+)
+
+// BindVariableMaxLength is the maximum length of a string or bytes
+// bind variable value formatted by FmtBindVariables. Longer values are
+// truncated. A value of 0 disables truncation.
+var BindVariableMaxLength = flag.Int("querylog-bind-variable-max-length", 0, "truncate string and bytes bind variable values longer than this in the query log, 0 disables truncation")
+
+// LogMysqlWarnings makes the tablet fetch the warnings raised by MySQL
+// with SHOW WARNINGS, so they can be included in the query log. This
+// costs an extra round trip for the statements that raise warnings.
+var LogMysqlWarnings = flag.Bool("querylog-mysql-warnings", false, "fetch the warnings raised by mysql and include them in the query log, at the cost of an extra round trip for statements that raise warnings")
+
+// maskedBindVariables matches the bind variable keys whose values
+// are masked by FmtBindVariables. A nil value doesn't match any key.
+var maskedBindVariables *regexp.Regexp
+
+// bindVariableMaskFlag sets maskedBindVariables from a comma separated
+// list of regular expressions.
+type bindVariableMaskFlag struct {
+	patterns flagutil.StringListValue
+}
+
+func (f *bindVariableMaskFlag) Set(v string) error {
+	var patterns flagutil.StringListValue
+	if err := patterns.Set(v); err != nil {
+		return err
+	}
+	if err := SetMaskedBindVariables(patterns); err != nil {
+		return err
+	}
+	f.patterns = patterns
+	return nil
+}
+
+func (f *bindVariableMaskFlag) String() string {
+	return f.patterns.String()
+}
+
+func init() {
+	flag.Var(&bindVariableMaskFlag{}, "querylog-masked-bind-variables", "comma separated list of regular expressions matching the bind variable keys whose values are masked in the query log, e.g. ^token,password$")
+}
+
+// SetMaskedBindVariables sets the regular expressions matching the bind
+// variable keys whose values are masked by FmtBindVariables. A key is
+// masked if it matches any of the patterns. It's not safe to call
+// concurrently with FmtBindVariables.
+func SetMaskedBindVariables(patterns []string) error {
+	if len(patterns) == 0 {
+		maskedBindVariables = nil
+		return nil
+	}
+	parts := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return err
+		}
+		parts = append(parts, "(?:"+pattern+")")
+	}
+	re, err := regexp.Compile(strings.Join(parts, "|"))
+	if err != nil {
+		return err
+	}
+	maskedBindVariables = re
+	return nil
+}
+
+// LogTimeLayout is the layout used for the start and end times
+// in the text and json query log formats.
+var LogTimeLayout = flag.String("querylog-time-layout", "2006-01-02 15:04:05.000000", "time layout, in the go time package syntax, for the start and end times in the text and json query log formats, e.g. 2006-01-02T15:04:05.999999Z07:00")
+
+// logTimeLocation is the time zone in which the start and end times
+// are rendered. A nil value leaves the times unchanged.
+var logTimeLocation *time.Location
+
+// logTimeLocationFlag sets logTimeLocation from a time zone name.
+type logTimeLocationFlag struct {
+	name string
+}
+
+func (f *logTimeLocationFlag) Set(v string) error {
+	if err := SetLogTimeLocation(v); err != nil {
+		return err
+	}
+	f.name = v
+	return nil
+}
+
+func (f *logTimeLocationFlag) String() string {
+	return f.name
+}
+
+func init() {
+	flag.Var(&logTimeLocationFlag{}, "querylog-time-zone", "time zone name, e.g. UTC or America/New_York, in which to render the start and end times in the text and json query log formats; the times are left unconverted if empty")
+}
+
+// SetLogTimeLocation sets the time zone in which the start and end
+// times are rendered in the query log. An empty name leaves the times
+// unconverted. It's not safe to call concurrently with Logf.
+func SetLogTimeLocation(name string) error {
+	if name == "" {
+		logTimeLocation = nil
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	logTimeLocation = loc
+	return nil
+}
+
+// fmtLogTime formats t for the text and json query log formats.
+func fmtLogTime(t time.Time) string {
+	if logTimeLocation != nil {
+		t = t.In(logTimeLocation)
+	}
+	return t.Format(*LogTimeLayout)
+}
+
+// logSampler is the random source for query log sampling.
+var logSampler = struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}{
+	rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+// seedLogSampler reseeds the query log sampler. It's for tests.
+func seedLogSampler(seed int64) {
+	logSampler.mu.Lock()
+	defer logSampler.mu.Unlock()
+	logSampler.rand = rand.New(rand.NewSource(seed))
+}
+
 // LogStats records the stats for a single query
 type LogStats struct {
 	Ctx                  context.Context
 	Method               string
 	Target               *querypb.Target
 	PlanType             string
+	PlanID               string
+	PlanCacheHit         bool
 	OriginalSQL          string
 	BindVariables        map[string]*querypb.BindVariable
 	rewrittenSqls        []string
+	rewrittenTimes       []time.Duration
 	RowsAffected         int
 	NumberOfQueries      int
+	ShardQueries         int
 	StartTime            time.Time
 	EndTime              time.Time
 	MysqlResponseTime    time.Duration
 	WaitingForConnection time.Duration
+	ConsolidatorWaitTime time.Duration
+	PlanTime             time.Duration
+	CommitTime           time.Duration
 	QuerySources         byte
 	Rows                 [][]sqltypes.Value
 	TransactionID        int64
 	ReservedID           int64
 	Error                error
+
+	// ReservedConn is set if the request executed on a reserved
+	// connection, i.e. one that holds session state. ReservedID
+	// identifies the connection.
+	ReservedConn bool
+
+	// ConnWaitCount is the number of times the request waited for a
+	// connection from a pool. WaitingForConnection is the total time
+	// it spent waiting.
+	ConnWaitCount int
+
+	// RequestSeq is the sequence number of the request, as assigned
+	// by Env.NextRequestSeq. It orders the records of a tablet even
+	// if their timestamps are equal.
+	RequestSeq int64
+
+	// TransactionMode is the vtgate transaction mode under which the
+	// statement ran, as supplied by the tx engine. It's SINGLE if the
+	// statement didn't run in a transaction, and UNSPECIFIED if it did,
+	// since vtgate doesn't send its mode to the tablet.
+	TransactionMode vtgatepb.TransactionMode
+
+	// IsolationLevel is the isolation level of the transaction in
+	// which the statement ran, e.g. READ COMMITTED, as supplied by
+	// the tx engine. It's empty outside of a transaction, and if the
+	// transaction used the session default.
+	IsolationLevel string
+
+	// MysqlRoundTrips is the number of round trips to MySQL made on
+	// behalf of the request. Unlike NumberOfQueries, which counts the
+	// statements added with AddRewrittenSQL, it also includes the
+	// auxiliary queries added with AddMysqlRoundTrip, e.g. to fetch
+	// warnings, which exposes the fan-out of a single statement.
+	MysqlRoundTrips int
+
+	// SavepointDepth is the number of savepoints active in the
+	// transaction when the statement executed.
+	SavepointDepth int
+
+	// MysqlRowsSent is the number of rows MySQL returned for the
+	// statements executed on behalf of the request. The number of
+	// rows it examined isn't logged: MySQL doesn't report it in its
+	// response.
+	MysqlRowsSent int
+
+	// MysqlResponseBytes is the size of the row values MySQL returned
+	// for those statements, before the tablet filters or projects them.
+	// Unlike SizeOfResponse, it includes rows that weren't sent to the
+	// client, which helps identify queries that over-fetch.
+	MysqlResponseBytes int
+
+	// WireBytesSent is the size of the response as encoded for the
+	// client, as reported by the serving layer with AddWireResult.
+	// Unlike SizeOfResponse, it accounts for the protocol encoding,
+	// and it's also set for streaming requests. Transport compression
+	// isn't accounted for.
+	WireBytesSent int64
+
+	// Keyspace and Shard are those of the target of the request, which
+	// must match the tablet's, so that records can be filtered per shard.
+	// They're empty for internal requests without a target.
+	Keyspace string
+	Shard    string
+
+	// BackupEngine is the name of the backup engine, e.g. builtin or
+	// xtrabackup, if the request was made on behalf of a backup or a
+	// restore. It's taken from the context with BackupEngineFromContext.
+	BackupEngine string
+
+	// ColumnCount is the number of columns of the result, as given
+	// by its fields. Wide results carry a lot of metadata, so it
+	// helps identify SELECT * queries. It's zero if the fields
+	// weren't returned.
+	ColumnCount int
+
+	// Warnings are the warnings MySQL raised for the statements
+	// executed on behalf of the request, e.g. on truncation. They're
+	// only fetched if LogMysqlWarnings is set.
+	Warnings []string
+
+	// Tags are the key/value pairs attached to the request by
+	// the LogTagsExtractor, for correlation.
+	Tags map[string]string
+
+	// sizeRows identifies the Rows for which responseSize
+	// was computed, by the address of the first row and
+	// the number of rows.
+	sizeRows     *[]sqltypes.Value
+	sizeRowsLen  int
+	responseSize int
+
+	// fingerprintSQL is the OriginalSQL for which fingerprint
+	// was computed, so that it's parsed at most once per record.
+	fingerprintSQL string
+	fingerprint    string
+
+	// noop is set for LogStats created by NewLogStatsNoop.
+	noop bool
 }
 
 // NewLogStats constructs a new LogStats with supplied Method and ctx
 // field values, and the StartTime field set to the present time.
 func NewLogStats(ctx context.Context, methodName string) *LogStats {
+	return &LogStats{
+		Ctx:          ctx,
+		Method:       methodName,
+		StartTime:    time.Now(),
+		Tags:         logTagsExtractor(ctx),
+		BackupEngine: BackupEngineFromContext(ctx),
+
+		TransactionMode: vtgatepb.TransactionMode_SINGLE,
+	}
+}
+
+// NewLogStatsNoop returns a LogStats for internal queries, which are
+// not logged: Send and Logf do nothing. The Add* methods still record
+// the statements and timings, so that they're accounted for in the
+// query stats.
+func NewLogStatsNoop(ctx context.Context, methodName string) *LogStats {
 	return &LogStats{
 		Ctx:       ctx,
 		Method:    methodName,
 		StartTime: time.Now(),
+		noop:      true,
 	}
 }
 
-// Send finalizes a record and sends it
+// Send finalizes a record and sends it, unless ShouldLog rejects it.
 func (stats *LogStats) Send() {
+	if stats.noop {
+		return
+	}
 	stats.EndTime = time.Now()
+	if !stats.ShouldLog() {
+		return
+	}
+	// Compute the fingerprint before the record is shared with
+	// the subscribers, so that they only read the cached value.
+	stats.QueryFingerprint()
 	StatsLogger.Send(stats)
 }
 
+// ShouldLog returns true if the query should be logged. Failed queries
+// and queries slower than LogSlowThreshold are always logged. Others
+// are sampled at 1 in LogSampleRate.
+func (stats *LogStats) ShouldLog() bool {
+	if stats.Error != nil {
+		return true
+	}
+	if *LogSlowThreshold > 0 && stats.TotalTime() >= *LogSlowThreshold {
+		return true
+	}
+	rate := *LogSampleRate
+	switch {
+	case rate <= 0:
+		return false
+	case rate == 1:
+		return true
+	}
+	logSampler.mu.Lock()
+	defer logSampler.mu.Unlock()
+	return logSampler.rand.Intn(rate) == 0
+}
+
 // Context returns the context used by LogStats.
 func (stats *LogStats) Context() context.Context {
 	return stats.Ctx
@@ -86,12 +462,34 @@ func (stats *LogStats) Context() context.Context {
 
 // ImmediateCaller returns the immediate caller stored in LogStats.Ctx
 func (stats *LogStats) ImmediateCaller() string {
-	return callerid.GetUsername(callerid.ImmediateCallerIDFromContext(stats.Ctx))
+	return callerid.GetUsername(stats.ImmediateCallerID())
 }
 
 // EffectiveCaller returns the effective caller stored in LogStats.Ctx
 func (stats *LogStats) EffectiveCaller() string {
-	return callerid.GetPrincipal(callerid.EffectiveCallerIDFromContext(stats.Ctx))
+	return callerid.GetPrincipal(stats.EffectiveCallerID())
+}
+
+// ImmediateCallerID returns the immediate caller id stored in
+// LogStats.Ctx. If there's none, it returns an empty VTGateCallerID.
+func (stats *LogStats) ImmediateCallerID() *querypb.VTGateCallerID {
+	if stats.Ctx != nil {
+		if im := callerid.ImmediateCallerIDFromContext(stats.Ctx); im != nil {
+			return im
+		}
+	}
+	return &querypb.VTGateCallerID{}
+}
+
+// EffectiveCallerID returns the effective caller id stored in
+// LogStats.Ctx. If there's none, it returns an empty CallerID.
+func (stats *LogStats) EffectiveCallerID() *vtrpcpb.CallerID {
+	if stats.Ctx != nil {
+		if ef := callerid.EffectiveCallerIDFromContext(stats.Ctx); ef != nil {
+			return ef
+		}
+	}
+	return &vtrpcpb.CallerID{}
 }
 
 // EventTime returns the time the event was created.
@@ -99,12 +497,79 @@ func (stats *LogStats) EventTime() time.Time {
 	return stats.EndTime
 }
 
-// AddRewrittenSQL adds a single sql statement to the rewritten list
+// AddRewrittenSQL adds a single sql statement to the rewritten list,
+// along with the time it took since start. Requests that execute
+// multiple statements call it once per statement, in order.
+// If RedactSQL is set, the normalized statement is stored instead.
 func (stats *LogStats) AddRewrittenSQL(sql string, start time.Time) {
 	stats.QuerySources |= QuerySourceMySQL
 	stats.NumberOfQueries++
+	stats.MysqlRoundTrips++
+	if *RedactSQL {
+		sql = redactSQL(sql)
+	}
+	elapsed := time.Since(start)
 	stats.rewrittenSqls = append(stats.rewrittenSqls, sql)
-	stats.MysqlResponseTime += time.Since(start)
+	stats.rewrittenTimes = append(stats.rewrittenTimes, elapsed)
+	stats.MysqlResponseTime += elapsed
+}
+
+// AddWireResult adds the encoded size of a result sent to the client
+// to WireBytesSent.
+func (stats *LogStats) AddWireResult(result *sqltypes.Result) {
+	if result == nil {
+		return
+	}
+	stats.WireBytesSent += int64(proto.Size(sqltypes.ResultToProto3(result)))
+}
+
+// AddMysqlRoundTrip counts a round trip to MySQL that isn't for a
+// statement of the request in MysqlRoundTrips.
+func (stats *LogStats) AddMysqlRoundTrip() {
+	stats.MysqlRoundTrips++
+}
+
+// AddWarnings appends the warnings raised by a statement to Warnings.
+func (stats *LogStats) AddWarnings(warnings []string) {
+	stats.Warnings = append(stats.Warnings, warnings...)
+}
+
+// AddMysqlResult accumulates the rows of a result returned by MySQL,
+// and their size. A nil result, e.g. for a failed statement, is ignored.
+func (stats *LogStats) AddMysqlResult(result *sqltypes.Result) {
+	if result == nil {
+		return
+	}
+	stats.MysqlRowsSent += len(result.Rows)
+	for _, row := range result.Rows {
+		for _, field := range row {
+			stats.MysqlResponseBytes += field.Len()
+		}
+	}
+}
+
+// AddConnWait records a wait for a pool connection that started at
+// start.
+func (stats *LogStats) AddConnWait(start time.Time) {
+	stats.ConnWaitCount++
+	stats.WaitingForConnection += time.Since(start)
+}
+
+// AddConsolidatorWait records that the result was obtained from the
+// consolidator, and adds the time spent waiting for it.
+func (stats *LogStats) AddConsolidatorWait(start time.Time) {
+	stats.QuerySources |= QuerySourceConsolidator
+	stats.ConsolidatorWaitTime += time.Since(start)
+}
+
+// AddPlanTime adds the time spent obtaining the query plan since start.
+func (stats *LogStats) AddPlanTime(start time.Time) {
+	stats.PlanTime += time.Since(start)
+}
+
+// AddCommitTime adds the time spent committing since start.
+func (stats *LogStats) AddCommitTime(start time.Time) {
+	stats.CommitTime += time.Since(start)
 }
 
 // TotalTime returns how long this query has been running
@@ -118,42 +583,260 @@ func (stats *LogStats) RewrittenSQL() string {
 	return strings.Join(stats.rewrittenSqls, "; ")
 }
 
+// RewrittenStatement is a statement executed on behalf of a request,
+// and the time it took.
+type RewrittenStatement struct {
+	SQL  string
+	Time time.Duration
+}
+
+// RewrittenStatements returns the SQL statements that were executed,
+// in order, with their timings.
+func (stats *LogStats) RewrittenStatements() []RewrittenStatement {
+	statements := make([]RewrittenStatement, len(stats.rewrittenSqls))
+	for i, sql := range stats.rewrittenSqls {
+		statements[i] = RewrittenStatement{SQL: sql, Time: stats.rewrittenTimes[i]}
+	}
+	return statements
+}
+
+// FmtRewrittenStatements returns the executed statements as a json
+// array of objects with the statement and its time in seconds.
+// The statements are redacted like RewrittenSQL.
+func (stats *LogStats) FmtRewrittenStatements() string {
+	parts := make([]string, 0, len(stats.rewrittenSqls))
+	for _, statement := range stats.RewrittenStatements() {
+		sql := statement.SQL
+		if *streamlog.RedactDebugUIQueries {
+			sql = "[REDACTED]"
+		}
+		parts = append(parts, fmt.Sprintf("{\"SQL\": %q, \"Time\": %.6f}", sql, statement.Time.Seconds()))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// FmtWarnings returns the warnings as a json array of strings.
+func (stats *LogStats) FmtWarnings() string {
+	if len(stats.Warnings) == 0 {
+		return "[]"
+	}
+	b, err := json.Marshal(stats.Warnings)
+	if err != nil {
+		return "[]"
+	}
+	return string(b)
+}
+
+// redactSQL returns sql with its literals replaced by placeholders.
+// Statements that can't be parsed are redacted entirely.
+func redactSQL(sql string) string {
+	redacted, err := sqlparser.RedactSQLQuery(sql)
+	if err != nil {
+		return "[REDACTED]"
+	}
+	return redacted
+}
+
+// QueryFingerprint returns a stable identifier for OriginalSQL with its
+// literals stripped: the first 16 hex digits of its sha256. Queries that
+// differ only in their literals have the same fingerprint. Statements
+// that can't be parsed are hashed as is. It's computed once, and
+// recomputed only if OriginalSQL changes.
+func (stats *LogStats) QueryFingerprint() string {
+	if stats.fingerprint != "" && stats.fingerprintSQL == stats.OriginalSQL {
+		return stats.fingerprint
+	}
+	normalized, err := sqlparser.RedactSQLQuery(stats.OriginalSQL)
+	if err != nil {
+		normalized = stats.OriginalSQL
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	stats.fingerprintSQL = stats.OriginalSQL
+	stats.fingerprint = hex.EncodeToString(sum[:8])
+	return stats.fingerprint
+}
+
+// QueryComments returns the leading and trailing comments of
+// OriginalSQL, e.g. hints or tracing ids injected by the application,
+// separated by a space. Comments inside the statement aren't included.
+func (stats *LogStats) QueryComments() string {
+	_, comments := sqlparser.SplitMarginComments(stats.OriginalSQL)
+	leading, trailing := strings.TrimSpace(comments.Leading), strings.TrimSpace(comments.Trailing)
+	if leading == "" || trailing == "" {
+		return leading + trailing
+	}
+	return leading + " " + trailing
+}
+
+// FmtBindVariables returns the formatted bind variables, or a redacted
+// placeholder if RedactDebugUIQueries or RedactSQL is set. If full is
+// false, long values are truncated. The values of the keys matched by
+// SetMaskedBindVariables are masked as "***".
+func (stats *LogStats) FmtBindVariables(full bool) string {
+	if *streamlog.RedactDebugUIQueries || *RedactSQL {
+		return "\"[REDACTED]\""
+	}
+	var masked func(string) bool
+	if re := maskedBindVariables; re != nil {
+		masked = re.MatchString
+	}
+	return sqltypes.FormatBindVariablesWithMask(
+		truncateBindVariables(stats.BindVariables, *BindVariableMaxLength),
+		full,
+		*streamlog.QueryLogFormat == streamlog.QueryLogFormatJSON || *streamlog.QueryLogFormat == streamlog.QueryLogFormatJSON2,
+		masked,
+	)
+}
+
+// truncateBindVariables returns bindVariables with the string and bytes
+// values longer than maxLength truncated. The values are cut at
+// a utf8 boundary and marked with an ellipsis and the original length.
+// bindVariables is returned as is if nothing needs to be truncated.
+func truncateBindVariables(bindVariables map[string]*querypb.BindVariable, maxLength int) map[string]*querypb.BindVariable {
+	if maxLength <= 0 {
+		return bindVariables
+	}
+	var out map[string]*querypb.BindVariable
+	for k, v := range bindVariables {
+		if len(v.Value) <= maxLength || !(sqltypes.IsText(v.Type) || sqltypes.IsBinary(v.Type)) {
+			continue
+		}
+		if out == nil {
+			out = sqltypes.CopyBindVariables(bindVariables)
+		}
+		n := maxLength
+		// Back off to the start of a utf8 sequence, but not more
+		// than a sequence can span, in case the value is binary.
+		for i := 0; i < utf8.UTFMax-1 && n > 0 && !utf8.RuneStart(v.Value[n]); i++ {
+			n--
+		}
+		truncated := fmt.Sprintf("%s...(len=%d)", v.Value[:n], len(v.Value))
+		out[k] = &querypb.BindVariable{Type: v.Type, Value: []byte(truncated)}
+	}
+	if out == nil {
+		return bindVariables
+	}
+	return out
+}
+
+// RowsReturned returns the number of rows returned by the query.
+// Unlike RowsAffected, which is set for DMLs, it counts the rows
+// of the result.
+func (stats *LogStats) RowsReturned() int {
+	return len(stats.Rows)
+}
+
 // SizeOfResponse returns the approximate size of the response in
 // bytes (this does not take in account protocol encoding). It will return
-// 0 for streaming requests.
+// 0 for streaming requests. The size is computed once and reused until
+// Rows is reassigned or its length changes.
 func (stats *LogStats) SizeOfResponse() int {
-	if stats.Rows == nil {
+	if len(stats.Rows) == 0 {
 		return 0
 	}
+	if stats.sizeRows == &stats.Rows[0] && stats.sizeRowsLen == len(stats.Rows) {
+		return stats.responseSize
+	}
 	size := 0
 	for _, row := range stats.Rows {
 		for _, field := range row {
 			size += field.Len()
 		}
 	}
+	stats.sizeRows = &stats.Rows[0]
+	stats.sizeRowsLen = len(stats.Rows)
+	stats.responseSize = size
 	return size
 }
 
 // FmtQuerySources returns a comma separated list of query
-// sources. If there were no query sources, it returns the string
-// "none".
+// sources, leaving out the suppressed ones. If there were no query
+// sources left, it returns the string "none".
 func (stats *LogStats) FmtQuerySources() string {
-	if stats.QuerySources == 0 {
+	querySources := stats.QuerySources &^ suppressedQuerySources
+	if querySources == 0 {
 		return "none"
 	}
 	sources := make([]string, 2)
 	n := 0
-	if stats.QuerySources&QuerySourceMySQL != 0 {
+	if querySources&QuerySourceMySQL != 0 {
 		sources[n] = "mysql"
 		n++
 	}
-	if stats.QuerySources&QuerySourceConsolidator != 0 {
+	if querySources&QuerySourceConsolidator != 0 {
 		sources[n] = "consolidator"
 		n++
 	}
 	return strings.Join(sources[:n], ",")
 }
 
+// QuerySourceTimings returns the elapsed time attributed to each query
+// source that was used, keyed by the names used in FmtQuerySources.
+func (stats *LogStats) QuerySourceTimings() map[string]time.Duration {
+	timings := make(map[string]time.Duration, 2)
+	if stats.QuerySources&QuerySourceMySQL != 0 {
+		timings["mysql"] = stats.MysqlResponseTime
+	}
+	if stats.QuerySources&QuerySourceConsolidator != 0 {
+		timings["consolidator"] = stats.ConsolidatorWaitTime
+	}
+	return timings
+}
+
+// FmtQuerySourceTimings returns the query source timings in seconds,
+// in the same order as FmtQuerySources. The output is a json object
+// if json is true, and a comma separated list of source:seconds
+// otherwise.
+func (stats *LogStats) FmtQuerySourceTimings(json bool) string {
+	timings := stats.QuerySourceTimings()
+	parts := make([]string, 0, 2)
+	for _, source := range []string{"mysql", "consolidator"} {
+		d, ok := timings[source]
+		if !ok {
+			continue
+		}
+		if json {
+			parts = append(parts, fmt.Sprintf("%q: %.6f", source, d.Seconds()))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s:%.6f", source, d.Seconds()))
+		}
+	}
+	if json {
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
+
+// FmtTags returns the tags sorted by key, as a JSON object if
+// jsonFormat is set, and as a comma separated list of key=value
+// pairs otherwise.
+func (stats *LogStats) FmtTags(jsonFormat bool) string {
+	if jsonFormat {
+		if len(stats.Tags) == 0 {
+			return "{}"
+		}
+		// encoding/json sorts the keys of maps.
+		b, err := json.Marshal(stats.Tags)
+		if err != nil {
+			return "{}"
+		}
+		return string(b)
+	}
+	keys := make([]string, 0, len(stats.Tags))
+	for k := range stats.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+stats.Tags[k])
+	}
+	return strings.Join(parts, ",")
+}
+
 // ContextHTML returns the HTML version of the context that was used, or "".
 // This is a method on LogStats instead of a field so that it doesn't need
 // to be passed by value everywhere.
@@ -169,6 +852,16 @@ func (stats *LogStats) ErrorStr() string {
 	return ""
 }
 
+// ErrorCode returns the canonical vtrpc code of the error, e.g.
+// INVALID_ARGUMENT, or "" if there is no error. Errors that don't
+// carry a code are classified as UNKNOWN.
+func (stats *LogStats) ErrorCode() string {
+	if stats.Error == nil {
+		return ""
+	}
+	return vterrors.Code(stats.Error).String()
+}
+
 // CallInfo returns some parts of CallInfo if set
 func (stats *LogStats) CallInfo() (string, string) {
 	ci, ok := callinfo.FromContext(stats.Ctx)
@@ -178,61 +871,326 @@ func (stats *LogStats) CallInfo() (string, string) {
 	return ci.Text(), ci.Username()
 }
 
-// Logf formats the log record to the given writer, either as
-// tab-separated list of logged fields or as JSON.
-func (stats *LogStats) Logf(w io.Writer, params url.Values) error {
-	if !streamlog.ShouldEmitLog(stats.OriginalSQL) {
-		return nil
+// loggedOriginalSQL returns OriginalSQL as it should be logged.
+func (stats *LogStats) loggedOriginalSQL() string {
+	if *RedactSQL {
+		return redactSQL(stats.OriginalSQL)
+	}
+	return stats.OriginalSQL
+}
+
+// loggedPlanID returns PlanID as it should be logged. The plan
+// cache key is the query, so it's redacted like OriginalSQL.
+func (stats *LogStats) loggedPlanID() string {
+	if *RedactSQL && stats.PlanID != "" {
+		return redactSQL(stats.PlanID)
+	}
+	return stats.PlanID
+}
+
+// loggedRewrittenSQL returns RewrittenSQL as it should be logged.
+func (stats *LogStats) loggedRewrittenSQL() string {
+	if *streamlog.RedactDebugUIQueries {
+		return "[REDACTED]"
+	}
+	return stats.RewrittenSQL()
+}
+
+// ToProto returns the log record as a QueryLogRecord. The SQL and
+// bind variables are redacted the same way as they are by Logf.
+func (stats *LogStats) ToProto() *querypb.QueryLogRecord {
+	record := &querypb.QueryLogRecord{
+		Method:               stats.Method,
+		Target:               stats.Target,
+		PlanType:             stats.PlanType,
+		OriginalSql:          stats.loggedOriginalSQL(),
+		RewrittenSql:         stats.loggedRewrittenSQL(),
+		NumberOfQueries:      int64(stats.NumberOfQueries),
+		StartTime:            stats.StartTime.UnixNano(),
+		EndTime:              stats.EndTime.UnixNano(),
+		TotalTime:            stats.TotalTime().Nanoseconds(),
+		MysqlTime:            stats.MysqlResponseTime.Nanoseconds(),
+		ConnWaitTime:         stats.WaitingForConnection.Nanoseconds(),
+		ConsolidatorWaitTime: stats.ConsolidatorWaitTime.Nanoseconds(),
+		QuerySources:         uint32(stats.QuerySources),
+		RowsAffected:         int64(stats.RowsAffected),
+		RowsReturned:         int64(stats.RowsReturned()),
+		ResponseSize:         int64(stats.SizeOfResponse()),
+		TransactionId:        stats.TransactionID,
+		ReservedId:           stats.ReservedID,
+		Error:                stats.ErrorStr(),
+		ImmediateCaller:      stats.ImmediateCaller(),
+		EffectiveCaller:      stats.EffectiveCaller(),
+	}
+	if !*streamlog.RedactDebugUIQueries && !*RedactSQL {
+		record.BindVariables = stats.BindVariables
+	}
+	return record
+}
+
+// logStatsJSON2 is the query log record for the json2 format.
+// Unlike the json format, durations are in nanoseconds.
+type logStatsJSON2 struct {
+	Method             string
+	CallInfo           string
+	Username           string
+	ImmediateCaller    string
+	EffectiveCaller    string
+	Start              time.Time
+	End                time.Time
+	TotalTime          int64
+	PlanType           string
+	OriginalSQL        string
+	BindVars           json.RawMessage
+	Queries            int
+	RewrittenSQL       string
+	QuerySources       string
+	MysqlTime          int64
+	ConnWaitTime       int64
+	RowsAffected       int
+	ResponseSize       int
+	Error              string
+	ShardQueries       int
+	Fingerprint        string
+	PlanTime           int64
+	CommitTime         int64
+	PlanID             string
+	RowsReturned       int
+	TransactionID      int64
+	SavepointDepth     int
+	MysqlRowsSent      int
+	ErrorCode          string
+	PlanCacheHit       bool
+	MysqlResponseBytes int
+	ReservedConn       bool
+	ReservedID         int64
+	ConnWaitCount      int
+	RequestSeq         int64
+	TransactionMode    string
+	WireBytesSent      int64
+	Keyspace           string
+	Shard              string
+	IsolationLevel     string
+	MysqlRoundTrips    int
+	QueryComments      string
+	BackupEngine       string
+	ColumnCount        int
+	Warnings           []string
+	Statements         []rewrittenStatementJSON2
+	Tags               map[string]string
+	QuerySourceTimings map[string]int64 `json:",omitempty"`
+}
+
+// rewrittenStatementJSON2 is a RewrittenStatement with its time
+// in nanoseconds, as logged by the json2 format.
+type rewrittenStatementJSON2 struct {
+	SQL  string
+	Time int64
+}
+
+// LogStatsFormatter formats a LogStats record for the query log. The
+// returned string is written as is, so it should end with a newline.
+type LogStatsFormatter interface {
+	Format(stats *LogStats, params url.Values) string
+}
+
+// LogStatsFormatterFunc adapts a function to the LogStatsFormatter interface.
+type LogStatsFormatterFunc func(stats *LogStats, params url.Values) string
+
+// Format calls f(stats, params).
+func (f LogStatsFormatterFunc) Format(stats *LogStats, params url.Values) string {
+	return f(stats, params)
+}
+
+// logStatsFormatters is a registry of LogStatsFormatter by querylog-format name.
+var logStatsFormatters = make(map[string]LogStatsFormatter)
+
+// RegisterLogStatsFormatter registers a LogStatsFormatter that's used when
+// querylog-format is set to name.
+func RegisterLogStatsFormatter(name string, f LogStatsFormatter) {
+	if _, ok := logStatsFormatters[name]; ok {
+		log.Fatalf("LogStatsFormatter named %v already exists", name)
 	}
+	logStatsFormatters[name] = f
+}
 
-	rewrittenSQL := "[REDACTED]"
-	formattedBindVars := "\"[REDACTED]\""
+func init() {
+	RegisterLogStatsFormatter(streamlog.QueryLogFormatText, LogStatsFormatterFunc(formatText))
+	RegisterLogStatsFormatter(streamlog.QueryLogFormatJSON, LogStatsFormatterFunc(formatJSON))
+	RegisterLogStatsFormatter(streamlog.QueryLogFormatJSON2, LogStatsFormatterFunc(formatJSON2))
+}
 
-	if !*streamlog.RedactDebugUIQueries {
-		rewrittenSQL = stats.RewrittenSQL()
+// Logf formats the log record to the given writer, using the
+// LogStatsFormatter registered for querylog-format.
+func (stats *LogStats) Logf(w io.Writer, params url.Values) error {
+	if stats.noop || !streamlog.ShouldEmitLog(stats.OriginalSQL) {
+		return nil
+	}
 
-		_, fullBindParams := params["full"]
-		formattedBindVars = sqltypes.FormatBindVariables(
-			stats.BindVariables,
-			fullBindParams,
-			*streamlog.QueryLogFormat == streamlog.QueryLogFormatJSON,
-		)
+	f, ok := logStatsFormatters[*streamlog.QueryLogFormat]
+	if !ok {
+		return fmt.Errorf("unknown querylog-format %q", *streamlog.QueryLogFormat)
 	}
+	_, err := io.WriteString(w, f.Format(stats, params))
+	return err
+}
 
+// formatJSON2 formats the record as JSON with typed fields and durations
+// in nanoseconds.
+func formatJSON2(stats *LogStats, params url.Values) string {
+	_, fullBindParams := params["full"]
 	// TODO: remove username here we fully enforce immediate caller id
 	callInfo, username := stats.CallInfo()
+	record := &logStatsJSON2{
+		Method:             stats.Method,
+		CallInfo:           callInfo,
+		Username:           username,
+		ImmediateCaller:    stats.ImmediateCaller(),
+		EffectiveCaller:    stats.EffectiveCaller(),
+		Start:              stats.StartTime,
+		End:                stats.EndTime,
+		TotalTime:          stats.TotalTime().Nanoseconds(),
+		PlanType:           stats.PlanType,
+		OriginalSQL:        stats.loggedOriginalSQL(),
+		BindVars:           json.RawMessage(stats.FmtBindVariables(fullBindParams)),
+		Queries:            stats.NumberOfQueries,
+		RewrittenSQL:       stats.loggedRewrittenSQL(),
+		QuerySources:       stats.FmtQuerySources(),
+		MysqlTime:          stats.MysqlResponseTime.Nanoseconds(),
+		ConnWaitTime:       stats.WaitingForConnection.Nanoseconds(),
+		RowsAffected:       stats.RowsAffected,
+		ResponseSize:       stats.SizeOfResponse(),
+		Error:              stats.ErrorStr(),
+		ShardQueries:       stats.ShardQueries,
+		Fingerprint:        stats.QueryFingerprint(),
+		PlanTime:           stats.PlanTime.Nanoseconds(),
+		CommitTime:         stats.CommitTime.Nanoseconds(),
+		PlanID:             stats.loggedPlanID(),
+		RowsReturned:       stats.RowsReturned(),
+		TransactionID:      stats.TransactionID,
+		SavepointDepth:     stats.SavepointDepth,
+		MysqlRowsSent:      stats.MysqlRowsSent,
+		ErrorCode:          stats.ErrorCode(),
+		PlanCacheHit:       stats.PlanCacheHit,
+		MysqlResponseBytes: stats.MysqlResponseBytes,
+		ReservedConn:       stats.ReservedConn,
+		ReservedID:         stats.ReservedID,
+		ConnWaitCount:      stats.ConnWaitCount,
+		RequestSeq:         stats.RequestSeq,
+		TransactionMode:    stats.TransactionMode.String(),
+		WireBytesSent:      stats.WireBytesSent,
+		Keyspace:           stats.Keyspace,
+		Shard:              stats.Shard,
+		IsolationLevel:     stats.IsolationLevel,
+		MysqlRoundTrips:    stats.MysqlRoundTrips,
+		QueryComments:      stats.QueryComments(),
+		BackupEngine:       stats.BackupEngine,
+		ColumnCount:        stats.ColumnCount,
+		Warnings:           stats.Warnings,
+		Tags:               stats.Tags,
+	}
+	if record.Warnings == nil {
+		record.Warnings = []string{}
+	}
+	record.Statements = make([]rewrittenStatementJSON2, 0, len(stats.rewrittenSqls))
+	for _, statement := range stats.RewrittenStatements() {
+		sql := statement.SQL
+		if *streamlog.RedactDebugUIQueries {
+			sql = "[REDACTED]"
+		}
+		record.Statements = append(record.Statements, rewrittenStatementJSON2{SQL: sql, Time: statement.Time.Nanoseconds()})
+	}
+	if record.Tags == nil {
+		record.Tags = map[string]string{}
+	}
+	if *EmitQuerySourceTimings {
+		record.QuerySourceTimings = make(map[string]int64)
+		for source, d := range stats.QuerySourceTimings() {
+			record.QuerySourceTimings[source] = d.Nanoseconds()
+		}
+	}
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(record); err != nil {
+		return fmt.Sprintf("Error: cannot encode query log record: %v\n", err)
+	}
+	return buf.String()
+}
 
-	// Valid options for the QueryLogFormat are text or json
-	var fmtString string
-	switch *streamlog.QueryLogFormat {
-	case streamlog.QueryLogFormatText:
-		fmtString = "%v\t%v\t%v\t'%v'\t'%v'\t%v\t%v\t%.6f\t%v\t%q\t%v\t%v\t%q\t%v\t%.6f\t%.6f\t%v\t%v\t%q\t\n"
-	case streamlog.QueryLogFormatJSON:
-		fmtString = "{\"Method\": %q, \"CallInfo\": %q, \"Username\": %q, \"ImmediateCaller\": %q, \"Effective Caller\": %q, \"Start\": \"%v\", \"End\": \"%v\", \"TotalTime\": %.6f, \"PlanType\": %q, \"OriginalSQL\": %q, \"BindVars\": %v, \"Queries\": %v, \"RewrittenSQL\": %q, \"QuerySources\": %q, \"MysqlTime\": %.6f, \"ConnWaitTime\": %.6f, \"RowsAffected\": %v, \"ResponseSize\": %v, \"Error\": %q}\n"
+// formatText formats the record as a tab-separated list of logged fields.
+func formatText(stats *LogStats, params url.Values) string {
+	fmtString := "%v\t%v\t%v\t'%v'\t'%v'\t%v\t%v\t%.6f\t%v\t%q\t%v\t%v\t%q\t%v\t%.6f\t%.6f\t%v\t%v\t%q\t%v\t%v\t%.6f\t%.6f\t%q\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%q\t%q\t%q\t%v\t%q\t%q\t%v\t%v\t%q\t\n"
+	args := append(stats.logArgs(params), len(stats.Warnings), stats.FmtTags(false))
+	if *EmitQuerySourceTimings {
+		fmtString = strings.TrimSuffix(fmtString, "\n") + "%v\t\n"
+		args = append(args, stats.FmtQuerySourceTimings(false))
 	}
+	return fmt.Sprintf(fmtString, args...)
+}
 
-	_, err := fmt.Fprintf(
-		w,
-		fmtString,
+// formatJSON formats the record as JSON, with durations in seconds.
+func formatJSON(stats *LogStats, params url.Values) string {
+	fmtString := "{\"Method\": %q, \"CallInfo\": %q, \"Username\": %q, \"ImmediateCaller\": %q, \"Effective Caller\": %q, \"Start\": \"%v\", \"End\": \"%v\", \"TotalTime\": %.6f, \"PlanType\": %q, \"OriginalSQL\": %q, \"BindVars\": %v, \"Queries\": %v, \"RewrittenSQL\": %q, \"QuerySources\": %q, \"MysqlTime\": %.6f, \"ConnWaitTime\": %.6f, \"RowsAffected\": %v, \"ResponseSize\": %v, \"Error\": %q, \"ShardQueries\": %v, \"Fingerprint\": %q, \"PlanTime\": %.6f, \"CommitTime\": %.6f, \"PlanID\": %q, \"RowsReturned\": %v, \"TransactionID\": %v, \"SavepointDepth\": %v, \"MysqlRowsSent\": %v, \"ErrorCode\": %q, \"PlanCacheHit\": %v, \"MysqlResponseBytes\": %v, \"ReservedConn\": %v, \"ReservedID\": %v, \"ConnWaitCount\": %v, \"RequestSeq\": %v, \"TransactionMode\": %q, \"WireBytesSent\": %v, \"Keyspace\": %q, \"Shard\": %q, \"IsolationLevel\": %q, \"MysqlRoundTrips\": %v, \"QueryComments\": %q, \"BackupEngine\": %q, \"ColumnCount\": %v, \"Warnings\": %v, \"Statements\": %v, \"Tags\": %v}\n"
+	args := append(stats.logArgs(params), stats.FmtWarnings(), stats.FmtRewrittenStatements(), stats.FmtTags(true))
+	if *EmitQuerySourceTimings {
+		fmtString = strings.TrimSuffix(fmtString, "}\n") + ", \"QuerySourceTimings\": %v}\n"
+		args = append(args, stats.FmtQuerySourceTimings(true))
+	}
+	return fmt.Sprintf(fmtString, args...)
+}
+
+// logArgs returns the logged fields shared by the text and json formats,
+// in column order. The formats append the tags, which they render
+// differently.
+func (stats *LogStats) logArgs(params url.Values) []interface{} {
+	_, fullBindParams := params["full"]
+	// TODO: remove username here we fully enforce immediate caller id
+	callInfo, username := stats.CallInfo()
+	return []interface{}{
 		stats.Method,
 		callInfo,
 		username,
 		stats.ImmediateCaller(),
 		stats.EffectiveCaller(),
-		stats.StartTime.Format("2006-01-02 15:04:05.000000"),
-		stats.EndTime.Format("2006-01-02 15:04:05.000000"),
+		fmtLogTime(stats.StartTime),
+		fmtLogTime(stats.EndTime),
 		stats.TotalTime().Seconds(),
 		stats.PlanType,
-		stats.OriginalSQL,
-		formattedBindVars,
+		stats.loggedOriginalSQL(),
+		stats.FmtBindVariables(fullBindParams),
 		stats.NumberOfQueries,
-		rewrittenSQL,
+		stats.loggedRewrittenSQL(),
 		stats.FmtQuerySources(),
 		stats.MysqlResponseTime.Seconds(),
 		stats.WaitingForConnection.Seconds(),
 		stats.RowsAffected,
 		stats.SizeOfResponse(),
 		stats.ErrorStr(),
-	)
-	return err
+		stats.ShardQueries,
+		stats.QueryFingerprint(),
+		stats.PlanTime.Seconds(),
+		stats.CommitTime.Seconds(),
+		stats.loggedPlanID(),
+		stats.RowsReturned(),
+		stats.TransactionID,
+		stats.SavepointDepth,
+		stats.MysqlRowsSent,
+		stats.ErrorCode(),
+		stats.PlanCacheHit,
+		stats.MysqlResponseBytes,
+		stats.ReservedConn,
+		stats.ReservedID,
+		stats.ConnWaitCount,
+		stats.RequestSeq,
+		stats.TransactionMode,
+		stats.WireBytesSent,
+		stats.Keyspace,
+		stats.Shard,
+		stats.IsolationLevel,
+		stats.MysqlRoundTrips,
+		stats.QueryComments(),
+		stats.BackupEngine,
+		stats.ColumnCount,
+	}
 }
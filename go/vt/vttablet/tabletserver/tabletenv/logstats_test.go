@@ -20,18 +20,27 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/streamlog"
+	"vitess.io/vitess/go/vt/callerid"
 	"vitess.io/vitess/go/vt/callinfo"
 	"vitess.io/vitess/go/vt/callinfo/fakecallinfo"
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/vterrors"
 )
 
 func TestLogStats(t *testing.T) {
@@ -52,6 +61,36 @@ func TestLogStats(t *testing.T) {
 	}
 }
 
+func TestLogStatsSizeOfResponseCache(t *testing.T) {
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.Rows = [][]sqltypes.Value{{sqltypes.NewVarBinary("abc")}}
+	if got := logStats.SizeOfResponse(); got != 3 {
+		t.Fatalf("SizeOfResponse: %d, want 3", got)
+	}
+
+	// Modifying a value in place is not detected, which shows
+	// that the cached size is being returned.
+	logStats.Rows[0][0] = sqltypes.NewVarBinary("abcdef")
+	if got := logStats.SizeOfResponse(); got != 3 {
+		t.Errorf("SizeOfResponse should be cached: %d, want 3", got)
+	}
+
+	logStats.Rows = [][]sqltypes.Value{{sqltypes.NewVarBinary("abcd")}}
+	if got := logStats.SizeOfResponse(); got != 4 {
+		t.Errorf("SizeOfResponse should be recomputed when Rows is reassigned: %d, want 4", got)
+	}
+
+	logStats.Rows = append(logStats.Rows[:1:1], []sqltypes.Value{sqltypes.NewVarBinary("a")})
+	if got := logStats.SizeOfResponse(); got != 5 {
+		t.Errorf("SizeOfResponse should be recomputed when rows are added: %d, want 5", got)
+	}
+
+	logStats.Rows = nil
+	if got := logStats.SizeOfResponse(); got != 0 {
+		t.Errorf("SizeOfResponse: %d, want 0", got)
+	}
+}
+
 func testFormat(stats *LogStats, params url.Values) string {
 	var b bytes.Buffer
 	stats.Logf(&b, params)
@@ -65,6 +104,7 @@ func TestLogStatsFormat(t *testing.T) {
 	logStats.OriginalSQL = "sql"
 	logStats.BindVariables = map[string]*querypb.BindVariable{"intVal": sqltypes.Int64BindVariable(1)}
 	logStats.AddRewrittenSQL("sql with pii", time.Now())
+	logStats.rewrittenTimes[0] = 0
 	logStats.MysqlResponseTime = 0
 	logStats.Rows = [][]sqltypes.Value{{sqltypes.NewVarBinary("a")}}
 	params := map[string][]string{"full": {}}
@@ -72,7 +112,7 @@ func TestLogStatsFormat(t *testing.T) {
 	*streamlog.RedactDebugUIQueries = false
 	*streamlog.QueryLogFormat = "text"
 	got := testFormat(logStats, url.Values(params))
-	want := "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql\"\tmap[intVal:type:INT64 value:\"1\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t\n"
+	want := "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql\"\tmap[intVal:type:INT64 value:\"1\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t0\t4ec7c53222c8a758\t0.000000\t0.000000\t\"\"\t1\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"\"\t\"\"\t0\t0\t\"\"\t\n"
 	if got != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%q\n", got, want)
 	}
@@ -80,7 +120,7 @@ func TestLogStatsFormat(t *testing.T) {
 	*streamlog.RedactDebugUIQueries = true
 	*streamlog.QueryLogFormat = "text"
 	got = testFormat(logStats, url.Values(params))
-	want = "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql\"\t\"[REDACTED]\"\t1\t\"[REDACTED]\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t\n"
+	want = "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql\"\t\"[REDACTED]\"\t1\t\"[REDACTED]\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t0\t4ec7c53222c8a758\t0.000000\t0.000000\t\"\"\t1\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"\"\t\"\"\t0\t0\t\"\"\t\n"
 	if got != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%q\n", got, want)
 	}
@@ -97,7 +137,7 @@ func TestLogStatsFormat(t *testing.T) {
 	if err != nil {
 		t.Errorf("logstats format: error marshaling json: %v -- got:\n%v", err, got)
 	}
-	want = "{\n    \"BindVars\": {\n        \"intVal\": {\n            \"type\": \"INT64\",\n            \"value\": 1\n        }\n    },\n    \"CallInfo\": \"\",\n    \"ConnWaitTime\": 0,\n    \"Effective Caller\": \"\",\n    \"End\": \"2017-01-01 01:02:04.000001\",\n    \"Error\": \"\",\n    \"ImmediateCaller\": \"\",\n    \"Method\": \"test\",\n    \"MysqlTime\": 0,\n    \"OriginalSQL\": \"sql\",\n    \"PlanType\": \"\",\n    \"Queries\": 1,\n    \"QuerySources\": \"mysql\",\n    \"ResponseSize\": 1,\n    \"RewrittenSQL\": \"sql with pii\",\n    \"RowsAffected\": 0,\n    \"Start\": \"2017-01-01 01:02:03.000000\",\n    \"TotalTime\": 1.000001,\n    \"Username\": \"\"\n}"
+	want = "{\n    \"BackupEngine\": \"\",\n    \"BindVars\": {\n        \"intVal\": {\n            \"type\": \"INT64\",\n            \"value\": 1\n        }\n    },\n    \"CallInfo\": \"\",\n    \"ColumnCount\": 0,\n    \"CommitTime\": 0,\n    \"ConnWaitCount\": 0,\n    \"ConnWaitTime\": 0,\n    \"Effective Caller\": \"\",\n    \"End\": \"2017-01-01 01:02:04.000001\",\n    \"Error\": \"\",\n    \"ErrorCode\": \"\",\n    \"Fingerprint\": \"4ec7c53222c8a758\",\n    \"ImmediateCaller\": \"\",\n    \"IsolationLevel\": \"\",\n    \"Keyspace\": \"\",\n    \"Method\": \"test\",\n    \"MysqlResponseBytes\": 0,\n    \"MysqlRoundTrips\": 1,\n    \"MysqlRowsSent\": 0,\n    \"MysqlTime\": 0,\n    \"OriginalSQL\": \"sql\",\n    \"PlanCacheHit\": false,\n    \"PlanID\": \"\",\n    \"PlanTime\": 0,\n    \"PlanType\": \"\",\n    \"Queries\": 1,\n    \"QueryComments\": \"\",\n    \"QuerySources\": \"mysql\",\n    \"RequestSeq\": 0,\n    \"ReservedConn\": false,\n    \"ReservedID\": 0,\n    \"ResponseSize\": 1,\n    \"RewrittenSQL\": \"sql with pii\",\n    \"RowsAffected\": 0,\n    \"RowsReturned\": 1,\n    \"SavepointDepth\": 0,\n    \"Shard\": \"\",\n    \"ShardQueries\": 0,\n    \"Start\": \"2017-01-01 01:02:03.000000\",\n    \"Statements\": [\n        {\n            \"SQL\": \"sql with pii\",\n            \"Time\": 0\n        }\n    ],\n    \"Tags\": {},\n    \"TotalTime\": 1.000001,\n    \"TransactionID\": 0,\n    \"TransactionMode\": \"SINGLE\",\n    \"Username\": \"\",\n    \"Warnings\": [],\n    \"WireBytesSent\": 0\n}"
 	if string(formatted) != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%v\n", string(formatted), want)
 	}
@@ -113,7 +153,7 @@ func TestLogStatsFormat(t *testing.T) {
 	if err != nil {
 		t.Errorf("logstats format: error marshaling json: %v -- got:\n%v", err, got)
 	}
-	want = "{\n    \"BindVars\": \"[REDACTED]\",\n    \"CallInfo\": \"\",\n    \"ConnWaitTime\": 0,\n    \"Effective Caller\": \"\",\n    \"End\": \"2017-01-01 01:02:04.000001\",\n    \"Error\": \"\",\n    \"ImmediateCaller\": \"\",\n    \"Method\": \"test\",\n    \"MysqlTime\": 0,\n    \"OriginalSQL\": \"sql\",\n    \"PlanType\": \"\",\n    \"Queries\": 1,\n    \"QuerySources\": \"mysql\",\n    \"ResponseSize\": 1,\n    \"RewrittenSQL\": \"[REDACTED]\",\n    \"RowsAffected\": 0,\n    \"Start\": \"2017-01-01 01:02:03.000000\",\n    \"TotalTime\": 1.000001,\n    \"Username\": \"\"\n}"
+	want = "{\n    \"BackupEngine\": \"\",\n    \"BindVars\": \"[REDACTED]\",\n    \"CallInfo\": \"\",\n    \"ColumnCount\": 0,\n    \"CommitTime\": 0,\n    \"ConnWaitCount\": 0,\n    \"ConnWaitTime\": 0,\n    \"Effective Caller\": \"\",\n    \"End\": \"2017-01-01 01:02:04.000001\",\n    \"Error\": \"\",\n    \"ErrorCode\": \"\",\n    \"Fingerprint\": \"4ec7c53222c8a758\",\n    \"ImmediateCaller\": \"\",\n    \"IsolationLevel\": \"\",\n    \"Keyspace\": \"\",\n    \"Method\": \"test\",\n    \"MysqlResponseBytes\": 0,\n    \"MysqlRoundTrips\": 1,\n    \"MysqlRowsSent\": 0,\n    \"MysqlTime\": 0,\n    \"OriginalSQL\": \"sql\",\n    \"PlanCacheHit\": false,\n    \"PlanID\": \"\",\n    \"PlanTime\": 0,\n    \"PlanType\": \"\",\n    \"Queries\": 1,\n    \"QueryComments\": \"\",\n    \"QuerySources\": \"mysql\",\n    \"RequestSeq\": 0,\n    \"ReservedConn\": false,\n    \"ReservedID\": 0,\n    \"ResponseSize\": 1,\n    \"RewrittenSQL\": \"[REDACTED]\",\n    \"RowsAffected\": 0,\n    \"RowsReturned\": 1,\n    \"SavepointDepth\": 0,\n    \"Shard\": \"\",\n    \"ShardQueries\": 0,\n    \"Start\": \"2017-01-01 01:02:03.000000\",\n    \"Statements\": [\n        {\n            \"SQL\": \"[REDACTED]\",\n            \"Time\": 0\n        }\n    ],\n    \"Tags\": {},\n    \"TotalTime\": 1.000001,\n    \"TransactionID\": 0,\n    \"TransactionMode\": \"SINGLE\",\n    \"Username\": \"\",\n    \"Warnings\": [],\n    \"WireBytesSent\": 0\n}"
 	if string(formatted) != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%v\n", string(formatted), want)
 	}
@@ -126,7 +166,7 @@ func TestLogStatsFormat(t *testing.T) {
 
 	*streamlog.QueryLogFormat = "text"
 	got = testFormat(logStats, url.Values(params))
-	want = "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql\"\tmap[strVal:type:VARBINARY value:\"abc\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t\n"
+	want = "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql\"\tmap[strVal:type:VARBINARY value:\"abc\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t0\t4ec7c53222c8a758\t0.000000\t0.000000\t\"\"\t1\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"\"\t\"\"\t0\t0\t\"\"\t\n"
 	if got != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%q\n", got, want)
 	}
@@ -141,7 +181,7 @@ func TestLogStatsFormat(t *testing.T) {
 	if err != nil {
 		t.Errorf("logstats format: error marshaling json: %v -- got:\n%v", err, got)
 	}
-	want = "{\n    \"BindVars\": {\n        \"strVal\": {\n            \"type\": \"VARBINARY\",\n            \"value\": \"abc\"\n        }\n    },\n    \"CallInfo\": \"\",\n    \"ConnWaitTime\": 0,\n    \"Effective Caller\": \"\",\n    \"End\": \"2017-01-01 01:02:04.000001\",\n    \"Error\": \"\",\n    \"ImmediateCaller\": \"\",\n    \"Method\": \"test\",\n    \"MysqlTime\": 0,\n    \"OriginalSQL\": \"sql\",\n    \"PlanType\": \"\",\n    \"Queries\": 1,\n    \"QuerySources\": \"mysql\",\n    \"ResponseSize\": 1,\n    \"RewrittenSQL\": \"sql with pii\",\n    \"RowsAffected\": 0,\n    \"Start\": \"2017-01-01 01:02:03.000000\",\n    \"TotalTime\": 1.000001,\n    \"Username\": \"\"\n}"
+	want = "{\n    \"BackupEngine\": \"\",\n    \"BindVars\": {\n        \"strVal\": {\n            \"type\": \"VARBINARY\",\n            \"value\": \"abc\"\n        }\n    },\n    \"CallInfo\": \"\",\n    \"ColumnCount\": 0,\n    \"CommitTime\": 0,\n    \"ConnWaitCount\": 0,\n    \"ConnWaitTime\": 0,\n    \"Effective Caller\": \"\",\n    \"End\": \"2017-01-01 01:02:04.000001\",\n    \"Error\": \"\",\n    \"ErrorCode\": \"\",\n    \"Fingerprint\": \"4ec7c53222c8a758\",\n    \"ImmediateCaller\": \"\",\n    \"IsolationLevel\": \"\",\n    \"Keyspace\": \"\",\n    \"Method\": \"test\",\n    \"MysqlResponseBytes\": 0,\n    \"MysqlRoundTrips\": 1,\n    \"MysqlRowsSent\": 0,\n    \"MysqlTime\": 0,\n    \"OriginalSQL\": \"sql\",\n    \"PlanCacheHit\": false,\n    \"PlanID\": \"\",\n    \"PlanTime\": 0,\n    \"PlanType\": \"\",\n    \"Queries\": 1,\n    \"QueryComments\": \"\",\n    \"QuerySources\": \"mysql\",\n    \"RequestSeq\": 0,\n    \"ReservedConn\": false,\n    \"ReservedID\": 0,\n    \"ResponseSize\": 1,\n    \"RewrittenSQL\": \"sql with pii\",\n    \"RowsAffected\": 0,\n    \"RowsReturned\": 1,\n    \"SavepointDepth\": 0,\n    \"Shard\": \"\",\n    \"ShardQueries\": 0,\n    \"Start\": \"2017-01-01 01:02:03.000000\",\n    \"Statements\": [\n        {\n            \"SQL\": \"sql with pii\",\n            \"Time\": 0\n        }\n    ],\n    \"Tags\": {},\n    \"TotalTime\": 1.000001,\n    \"TransactionID\": 0,\n    \"TransactionMode\": \"SINGLE\",\n    \"Username\": \"\",\n    \"Warnings\": [],\n    \"WireBytesSent\": 0\n}"
 	if string(formatted) != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%v\n", string(formatted), want)
 	}
@@ -149,6 +189,160 @@ func TestLogStatsFormat(t *testing.T) {
 	*streamlog.QueryLogFormat = "text"
 }
 
+func TestLogStatsFormatJSON2(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 1234, time.UTC)
+	logStats.OriginalSQL = "select * from t where a < :a"
+	logStats.BindVariables = map[string]*querypb.BindVariable{"a": sqltypes.Int64BindVariable(1)}
+	logStats.AddRewrittenSQL("select * from t where a < 1", time.Now())
+	logStats.rewrittenTimes[0] = 1500
+	logStats.MysqlResponseTime = 1500
+	logStats.WaitingForConnection = 25
+	logStats.Rows = [][]sqltypes.Value{{sqltypes.NewVarBinary("a")}}
+	params := map[string][]string{"full": {}}
+
+	*streamlog.QueryLogFormat = "json2"
+	got := testFormat(logStats, url.Values(params))
+	want := `{"Method":"test","CallInfo":"","Username":"","ImmediateCaller":"","EffectiveCaller":"","Start":"2017-01-01T01:02:03Z","End":"2017-01-01T01:02:04.000001234Z","TotalTime":1000001234,"PlanType":"","OriginalSQL":"select * from t where a < :a","BindVars":{"a":{"type":"INT64","value":1}},"Queries":1,"RewrittenSQL":"select * from t where a < 1","QuerySources":"mysql","MysqlTime":1500,"ConnWaitTime":25,"RowsAffected":0,"ResponseSize":1,"Error":"","ShardQueries":0,"Fingerprint":"37222e40236100e2","PlanTime":0,"CommitTime":0,"PlanID":"","RowsReturned":1,"TransactionID":0,"SavepointDepth":0,"MysqlRowsSent":0,"ErrorCode":"","PlanCacheHit":false,"MysqlResponseBytes":0,"ReservedConn":false,"ReservedID":0,"ConnWaitCount":0,"RequestSeq":0,"TransactionMode":"SINGLE","WireBytesSent":0,"Keyspace":"","Shard":"","IsolationLevel":"","MysqlRoundTrips":1,"QueryComments":"","BackupEngine":"","ColumnCount":0,"Warnings":[],"Statements":[{"SQL":"select * from t where a < 1","Time":1500}],"Tags":{}}` + "\n"
+	if got != want {
+		t.Errorf("logstats format: got:\n%q\nwant:\n%q\n", got, want)
+	}
+
+	var decoded logStatsJSON2
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("logstats format: error unmarshaling json: %v -- got:\n%v", err, got)
+	}
+	if !decoded.Start.Equal(logStats.StartTime) || !decoded.End.Equal(logStats.EndTime) {
+		t.Errorf("decoded times: %v %v, want %v %v", decoded.Start, decoded.End, logStats.StartTime, logStats.EndTime)
+	}
+	if time.Duration(decoded.TotalTime) != logStats.TotalTime() || time.Duration(decoded.MysqlTime) != logStats.MysqlResponseTime {
+		t.Errorf("decoded durations: %v %v", decoded.TotalTime, decoded.MysqlTime)
+	}
+
+	*EmitQuerySourceTimings = true
+	got = testFormat(logStats, url.Values(params))
+	*EmitQuerySourceTimings = false
+	if !strings.HasSuffix(got, `"Error":"","ShardQueries":0,"Fingerprint":"37222e40236100e2","PlanTime":0,"CommitTime":0,"PlanID":"","RowsReturned":1,"TransactionID":0,"SavepointDepth":0,"MysqlRowsSent":0,"ErrorCode":"","PlanCacheHit":false,"MysqlResponseBytes":0,"ReservedConn":false,"ReservedID":0,"ConnWaitCount":0,"RequestSeq":0,"TransactionMode":"SINGLE","WireBytesSent":0,"Keyspace":"","Shard":"","IsolationLevel":"","MysqlRoundTrips":1,"QueryComments":"","BackupEngine":"","ColumnCount":0,"Warnings":[],"Statements":[{"SQL":"select * from t where a < 1","Time":1500}],"Tags":{},"QuerySourceTimings":{"mysql":1500}}`+"\n") {
+		t.Errorf("logstats format with query source timings: %q", got)
+	}
+
+	*streamlog.RedactDebugUIQueries = true
+	got = testFormat(logStats, url.Values(params))
+	*streamlog.RedactDebugUIQueries = false
+	if !strings.Contains(got, `"BindVars":"[REDACTED]"`) || !strings.Contains(got, `"RewrittenSQL":"[REDACTED]"`) || !strings.Contains(got, `"Statements":[{"SQL":"[REDACTED]","Time":1500}]`) {
+		t.Errorf("logstats format should be redacted: %q", got)
+	}
+}
+
+func TestLogStatsMultipleStatements(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "sql"
+	now := time.Now()
+	logStats.AddRewrittenSQL("select 1", now)
+	logStats.AddRewrittenSQL("select 2", now)
+	logStats.AddRewrittenSQL("select 3", now)
+	logStats.rewrittenTimes = []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}
+	logStats.EndTime = time.Now()
+
+	wantStatements := []RewrittenStatement{
+		{SQL: "select 1", Time: time.Millisecond},
+		{SQL: "select 2", Time: 2 * time.Millisecond},
+		{SQL: "select 3", Time: 3 * time.Millisecond},
+	}
+	if got := logStats.RewrittenStatements(); !reflect.DeepEqual(got, wantStatements) {
+		t.Errorf("RewrittenStatements: %v, want %v", got, wantStatements)
+	}
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, url.Values{})
+	if !strings.Contains(got, "\t3\t\"select 1; select 2; select 3\"\t") {
+		t.Errorf("text format: %q", got)
+	}
+
+	*streamlog.QueryLogFormat = "json"
+	got = testFormat(logStats, url.Values{})
+	var parsed struct {
+		Queries      int
+		RewrittenSQL string
+		Statements   []struct {
+			SQL  string
+			Time float64
+		}
+	}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("json format: error unmarshaling json: %v -- got:\n%v", err, got)
+	}
+	if parsed.Queries != 3 || parsed.RewrittenSQL != "select 1; select 2; select 3" {
+		t.Errorf("json format: Queries %d, RewrittenSQL %q", parsed.Queries, parsed.RewrittenSQL)
+	}
+	if !strings.Contains(got, `"Statements": [{"SQL": "select 1", "Time": 0.001000}, {"SQL": "select 2", "Time": 0.002000}, {"SQL": "select 3", "Time": 0.003000}]`) {
+		t.Errorf("json format: %q", got)
+	}
+
+	*streamlog.QueryLogFormat = "json2"
+	got = testFormat(logStats, url.Values{})
+	if !strings.Contains(got, `"Statements":[{"SQL":"select 1","Time":1000000},{"SQL":"select 2","Time":2000000},{"SQL":"select 3","Time":3000000}]`) {
+		t.Errorf("json2 format: %q", got)
+	}
+}
+
+func TestLogStatsCallerIDs(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := &LogStats{Method: "test"}
+	if got := logStats.ImmediateCallerID(); got == nil || got.Username != "" {
+		t.Errorf("ImmediateCallerID without context: %v, want empty", got)
+	}
+	if got := logStats.EffectiveCallerID(); got == nil || got.Principal != "" {
+		t.Errorf("EffectiveCallerID without context: %v, want empty", got)
+	}
+
+	logStats = NewLogStats(context.Background(), "test")
+	if logStats.ImmediateCaller() != "" || logStats.EffectiveCaller() != "" {
+		t.Errorf("callers without caller ids: %q %q, want empty", logStats.ImmediateCaller(), logStats.EffectiveCaller())
+	}
+
+	ctx := callerid.NewContext(
+		context.Background(),
+		callerid.NewEffectiveCallerID("effective-user", "component", "subcomponent"),
+		callerid.NewImmediateCallerID("immediate-user"),
+	)
+	logStats = NewLogStats(ctx, "test")
+	if got := logStats.ImmediateCallerID().Username; got != "immediate-user" {
+		t.Errorf("ImmediateCallerID: %q, want immediate-user", got)
+	}
+	if got := logStats.EffectiveCallerID(); got.Principal != "effective-user" || got.Component != "component" || got.Subcomponent != "subcomponent" {
+		t.Errorf("EffectiveCallerID: %v", got)
+	}
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if !strings.Contains(got, "\t'immediate-user'\t'effective-user'\t") {
+		t.Errorf("text format does not contain the callers: %q", got)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("%s format: error unmarshaling json: %v -- got:\n%v", format, err, got)
+		}
+		effectiveKey := "EffectiveCaller"
+		if format == "json" {
+			effectiveKey = "Effective Caller"
+		}
+		if parsed["ImmediateCaller"] != "immediate-user" || parsed[effectiveKey] != "effective-user" {
+			t.Errorf("%s format does not contain the callers: %v", format, got)
+		}
+	}
+}
+
 func TestLogStatsFilter(t *testing.T) {
 	defer func() { *streamlog.QueryLogFilterTag = "" }()
 
@@ -163,14 +357,14 @@ func TestLogStatsFilter(t *testing.T) {
 	params := map[string][]string{"full": {}}
 
 	got := testFormat(logStats, url.Values(params))
-	want := "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql /* LOG_THIS_QUERY */\"\tmap[intVal:type:INT64 value:\"1\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t\n"
+	want := "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql /* LOG_THIS_QUERY */\"\tmap[intVal:type:INT64 value:\"1\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t0\tee9b53d729e7549b\t0.000000\t0.000000\t\"\"\t1\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"/* LOG_THIS_QUERY */\"\t\"\"\t0\t0\t\"\"\t\n"
 	if got != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%q\n", got, want)
 	}
 
 	*streamlog.QueryLogFilterTag = "LOG_THIS_QUERY"
 	got = testFormat(logStats, url.Values(params))
-	want = "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql /* LOG_THIS_QUERY */\"\tmap[intVal:type:INT64 value:\"1\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t\n"
+	want = "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql /* LOG_THIS_QUERY */\"\tmap[intVal:type:INT64 value:\"1\" ]\t1\t\"sql with pii\"\tmysql\t0.000000\t0.000000\t0\t1\t\"\"\t0\tee9b53d729e7549b\t0.000000\t0.000000\t\"\"\t1\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"/* LOG_THIS_QUERY */\"\t\"\"\t0\t0\t\"\"\t\n"
 	if got != want {
 		t.Errorf("logstats format: got:\n%q\nwant:\n%q\n", got, want)
 	}
@@ -184,6 +378,281 @@ func TestLogStatsFilter(t *testing.T) {
 
 }
 
+func TestLogStatsRedactSQL(t *testing.T) {
+	defer func() { *RedactSQL = false }()
+	*RedactSQL = true
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 1234, time.UTC)
+	logStats.OriginalSQL = "select name from t where id = 'secret1'"
+	logStats.BindVariables = map[string]*querypb.BindVariable{"strVal": sqltypes.StringBindVariable("secret2")}
+	logStats.AddRewrittenSQL("select name from t where id = 'secret1' limit 10001", time.Now())
+	logStats.AddRewrittenSQL("not valid sql 'secret3'", time.Now())
+
+	want := "select name from t where id = :redacted2 limit :redacted1; [REDACTED]"
+	if got := logStats.RewrittenSQL(); got != want {
+		t.Errorf("RewrittenSQL: got %q, want %q", got, want)
+	}
+	if got, want := logStats.FmtBindVariables(true), "\"[REDACTED]\""; got != want {
+		t.Errorf("FmtBindVariables: got %q, want %q", got, want)
+	}
+
+	for _, format := range []string{"text", "json"} {
+		*streamlog.QueryLogFormat = format
+		got := testFormat(logStats, url.Values(map[string][]string{"full": {}}))
+		if strings.Contains(got, "secret") {
+			t.Errorf("logstats format %s: literal leaked: %v", format, got)
+		}
+	}
+	*streamlog.QueryLogFormat = "text"
+
+	*RedactSQL = false
+	if got := logStats.FmtBindVariables(true); !strings.Contains(got, "secret2") {
+		t.Errorf("FmtBindVariables should not be redacted by default: %v", got)
+	}
+}
+
+func TestLogStatsMaskedBindVariables(t *testing.T) {
+	defer SetMaskedBindVariables(nil)
+
+	if err := SetMaskedBindVariables([]string{"("}); err == nil {
+		t.Errorf("SetMaskedBindVariables should fail for an invalid pattern")
+	}
+	if err := SetMaskedBindVariables([]string{"^token", "password$"}); err != nil {
+		t.Fatal(err)
+	}
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.BindVariables = map[string]*querypb.BindVariable{
+		"token_id":      sqltypes.StringBindVariable("secret1"),
+		"user_password": sqltypes.StringBindVariable("secret2"),
+		"name":          sqltypes.StringBindVariable("visible"),
+		"id":            sqltypes.Int64BindVariable(12345),
+	}
+
+	testCases := []struct {
+		format  string
+		full    bool
+		masked  []string
+		visible []string
+	}{{
+		format:  "text",
+		full:    true,
+		masked:  []string{`token_id:type:VARBINARY value:"***"`, `user_password:type:VARBINARY value:"***"`},
+		visible: []string{`name:type:VARBINARY value:"visible"`, "12345"},
+	}, {
+		format:  "text",
+		full:    false,
+		masked:  []string{`token_id:type:VARBINARY value:"***"`, `user_password:type:VARBINARY value:"***"`},
+		visible: []string{`name:type:VARBINARY value:"7 bytes"`, "12345"},
+	}, {
+		format:  "json",
+		full:    true,
+		masked:  []string{`"token_id": {"type": "VARBINARY", "value": "***"}`, `"user_password": {"type": "VARBINARY", "value": "***"}`},
+		visible: []string{`"name": {"type": "VARBINARY", "value": "visible"}`, `"id": {"type": "INT64", "value": 12345}`},
+	}, {
+		format:  "json",
+		full:    false,
+		masked:  []string{`"token_id": {"type": "VARBINARY", "value": "***"}`, `"user_password": {"type": "VARBINARY", "value": "***"}`},
+		visible: []string{`"name": {"type": "VARBINARY", "value": "7 bytes"}`, `"id": {"type": "INT64", "value": 12345}`},
+	}}
+	for _, tc := range testCases {
+		*streamlog.QueryLogFormat = tc.format
+		got := logStats.FmtBindVariables(tc.full)
+		for _, want := range append(tc.masked, tc.visible...) {
+			if !strings.Contains(got, want) {
+				t.Errorf("FmtBindVariables(%v) %s: %q does not contain %q", tc.full, tc.format, got, want)
+			}
+		}
+		if strings.Contains(got, "secret") {
+			t.Errorf("FmtBindVariables(%v) %s: masked value leaked: %q", tc.full, tc.format, got)
+		}
+	}
+	*streamlog.QueryLogFormat = "text"
+
+	// The original bind variables must not be modified.
+	if got := string(logStats.BindVariables["token_id"].Value); got != "secret1" {
+		t.Errorf("BindVariables were modified: %v", got)
+	}
+}
+
+func TestLogStatsTruncateBindVariables(t *testing.T) {
+	defer func() { *BindVariableMaxLength = 0 }()
+	*BindVariableMaxLength = 10
+
+	testCases := []struct {
+		bv   *querypb.BindVariable
+		want string
+	}{{
+		bv:   sqltypes.StringBindVariable("abcdefghi"),
+		want: "abcdefghi",
+	}, {
+		bv:   sqltypes.StringBindVariable("abcdefghij"),
+		want: "abcdefghij",
+	}, {
+		bv:   sqltypes.StringBindVariable("abcdefghijk"),
+		want: "abcdefghij...(len=11)",
+	}, {
+		bv:   sqltypes.BytesBindVariable([]byte("abcdefghijk")),
+		want: "abcdefghij...(len=11)",
+	}, {
+		// é is two bytes and ends at the limit.
+		bv:   sqltypes.StringBindVariable("abcdefghé"),
+		want: "abcdefghé",
+	}, {
+		// é straddles the limit and must not be split.
+		bv:   sqltypes.StringBindVariable("abcdefghiéx"),
+		want: "abcdefghi...(len=12)",
+	}, {
+		// Numbers are not truncated. json decodes them as floats.
+		bv:   sqltypes.Int64BindVariable(12345678901),
+		want: "1.2345678901e+10",
+	}}
+	*streamlog.QueryLogFormat = "json"
+	for _, tc := range testCases {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.BindVariables = map[string]*querypb.BindVariable{"v": tc.bv}
+		var parsed map[string]map[string]interface{}
+		got := logStats.FmtBindVariables(true)
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("FmtBindVariables: %v: %s", err, got)
+		}
+		if gotValue := fmt.Sprint(parsed["v"]["value"]); gotValue != tc.want {
+			t.Errorf("FmtBindVariables(%q): %q, want %q", tc.bv.Value, gotValue, tc.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("FmtBindVariables(%q) is not valid utf8: %q", tc.bv.Value, got)
+		}
+		if string(logStats.BindVariables["v"].Value) != string(tc.bv.Value) {
+			t.Errorf("BindVariables were modified: %q", logStats.BindVariables["v"].Value)
+		}
+	}
+	*streamlog.QueryLogFormat = "text"
+}
+
+func TestLogStatsShouldLog(t *testing.T) {
+	defer func() {
+		*LogSampleRate = 1
+		*LogSlowThreshold = 0
+	}()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.EndTime = logStats.StartTime.Add(time.Millisecond)
+	if !logStats.ShouldLog() {
+		t.Fatalf("all queries should be logged by default")
+	}
+
+	sample := func() []bool {
+		seedLogSampler(1)
+		results := make([]bool, 1000)
+		for i := range results {
+			results[i] = logStats.ShouldLog()
+		}
+		return results
+	}
+	*LogSampleRate = 10
+	first := sample()
+	second := sample()
+	logged := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("sampling should be deterministic for a seed, differs at %d", i)
+		}
+		if first[i] {
+			logged++
+		}
+	}
+	if logged < 50 || logged > 150 {
+		t.Errorf("logged %d of 1000 queries, want about 100", logged)
+	}
+
+	*LogSampleRate = 0
+	if logStats.ShouldLog() {
+		t.Errorf("fast query should not be logged with a rate of 0")
+	}
+
+	*LogSlowThreshold = time.Millisecond
+	if !logStats.ShouldLog() {
+		t.Errorf("slow query should bypass sampling")
+	}
+
+	*LogSlowThreshold = time.Second
+	if logStats.ShouldLog() {
+		t.Errorf("fast query should not bypass sampling")
+	}
+
+	logStats.Error = errors.New("err")
+	if !logStats.ShouldLog() {
+		t.Errorf("failed query should bypass sampling")
+	}
+}
+
+func TestLogStatsToProto(t *testing.T) {
+	callerID := callerid.NewEffectiveCallerID("effective", "", "")
+	immediateID := callerid.NewImmediateCallerID("immediate")
+	ctx := callerid.NewContext(context.Background(), callerID, immediateID)
+	logStats := NewLogStats(ctx, "test")
+	logStats.Target = &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}
+	logStats.PlanType = "Select"
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 1234, time.UTC)
+	logStats.OriginalSQL = "select * from t where id = 1"
+	logStats.BindVariables = map[string]*querypb.BindVariable{"intVal": sqltypes.Int64BindVariable(1)}
+	logStats.AddRewrittenSQL("select * from t where id = 1 limit 10001", time.Now())
+	logStats.MysqlResponseTime = 2 * time.Millisecond
+	logStats.WaitingForConnection = 3 * time.Millisecond
+	logStats.QuerySources |= QuerySourceConsolidator
+	logStats.ConsolidatorWaitTime = 4 * time.Millisecond
+	logStats.RowsAffected = 5
+	logStats.Rows = [][]sqltypes.Value{{sqltypes.NewVarBinary("a")}, {sqltypes.NewVarBinary("bc")}}
+	logStats.TransactionID = 6
+	logStats.ReservedID = 7
+	logStats.Error = errors.New("err")
+
+	want := &querypb.QueryLogRecord{
+		Method:               "test",
+		Target:               logStats.Target,
+		PlanType:             "Select",
+		OriginalSql:          "select * from t where id = 1",
+		BindVariables:        logStats.BindVariables,
+		RewrittenSql:         "select * from t where id = 1 limit 10001",
+		NumberOfQueries:      1,
+		StartTime:            logStats.StartTime.UnixNano(),
+		EndTime:              logStats.EndTime.UnixNano(),
+		TotalTime:            1000001234,
+		MysqlTime:            2000000,
+		ConnWaitTime:         3000000,
+		ConsolidatorWaitTime: 4000000,
+		QuerySources:         QuerySourceMySQL | QuerySourceConsolidator,
+		RowsAffected:         5,
+		RowsReturned:         2,
+		ResponseSize:         3,
+		TransactionId:        6,
+		ReservedId:           7,
+		Error:                "err",
+		ImmediateCaller:      "immediate",
+		EffectiveCaller:      "effective",
+	}
+	if got := logStats.ToProto(); !proto.Equal(got, want) {
+		t.Errorf("ToProto:\n%v, want\n%v", got, want)
+	}
+
+	*streamlog.RedactDebugUIQueries = true
+	got := logStats.ToProto()
+	*streamlog.RedactDebugUIQueries = false
+	if got.BindVariables != nil || got.RewrittenSql != "[REDACTED]" || got.OriginalSql != "select * from t where id = 1" {
+		t.Errorf("ToProto with RedactDebugUIQueries: %v", got)
+	}
+
+	*RedactSQL = true
+	got = logStats.ToProto()
+	*RedactSQL = false
+	if got.BindVariables != nil || got.OriginalSql != "select * from t where id = :redacted1" {
+		t.Errorf("ToProto with RedactSQL: %v", got)
+	}
+}
+
 func TestLogStatsFormatQuerySources(t *testing.T) {
 	logStats := NewLogStats(context.Background(), "test")
 	if logStats.FmtQuerySources() != "none" {
@@ -201,6 +670,785 @@ func TestLogStatsFormatQuerySources(t *testing.T) {
 	}
 }
 
+func TestLogStatsSuppressedQuerySources(t *testing.T) {
+	defer SetSuppressedQuerySources(nil)
+
+	if err := SetSuppressedQuerySources([]string{"cache"}); err == nil {
+		t.Errorf("SetSuppressedQuerySources should fail for an unknown query source")
+	}
+	if err := SetSuppressedQuerySources([]string{"consolidator"}); err != nil {
+		t.Fatalf("SetSuppressedQuerySources failed: %v", err)
+	}
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.QuerySources |= QuerySourceConsolidator
+	if got := logStats.FmtQuerySources(); got != "none" {
+		t.Errorf("got %q, want none since consolidator is suppressed", got)
+	}
+	if logStats.QuerySources&QuerySourceConsolidator == 0 {
+		t.Errorf("consolidator flag should still be set in query sources")
+	}
+
+	logStats.QuerySources |= QuerySourceMySQL
+	if got := logStats.FmtQuerySources(); got != "mysql" {
+		t.Errorf("got %q, want mysql", got)
+	}
+
+	SetSuppressedQuerySources(nil)
+	if got := logStats.FmtQuerySources(); got != "mysql,consolidator" {
+		t.Errorf("got %q, want mysql,consolidator", got)
+	}
+}
+
+func TestLogStatsQuerySourceTimings(t *testing.T) {
+	logStats := NewLogStats(context.Background(), "test")
+	if len(logStats.QuerySourceTimings()) != 0 {
+		t.Fatalf("should not have timings without query sources, but got: %v", logStats.QuerySourceTimings())
+	}
+
+	now := time.Now()
+	logStats.StartTime = now.Add(-100 * time.Millisecond)
+	logStats.AddRewrittenSQL("sql", now.Add(-60*time.Millisecond))
+	logStats.AddConsolidatorWait(now.Add(-30 * time.Millisecond))
+	logStats.EndTime = time.Now()
+
+	timings := logStats.QuerySourceTimings()
+	if len(timings) != 2 {
+		t.Fatalf("expected timings for mysql and consolidator, but got: %v", timings)
+	}
+	sum := timings["mysql"] + timings["consolidator"]
+	if sum < 90*time.Millisecond || sum > logStats.TotalTime() {
+		t.Errorf("timings should sum to roughly TotalTime: sum %v, TotalTime %v", sum, logStats.TotalTime())
+	}
+	if logStats.QuerySources&QuerySourceConsolidator == 0 {
+		t.Errorf("AddConsolidatorWait should set the consolidator query source")
+	}
+}
+
+func TestLogStatsShardQueries(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 1234, time.UTC)
+	logStats.OriginalSQL = "sql"
+	logStats.ShardQueries = 4
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if !strings.Contains(got, "\t\"\"\t4\t") {
+		t.Errorf("text format: %q", got)
+	}
+
+	*streamlog.QueryLogFormat = "json"
+	got = testFormat(logStats, nil)
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("logstats is not valid json: %v (%s)", err, got)
+	}
+	if parsed["ShardQueries"] != 4.0 {
+		t.Errorf("json ShardQueries: %v", parsed["ShardQueries"])
+	}
+
+	*streamlog.QueryLogFormat = "json2"
+	got = testFormat(logStats, nil)
+	if !strings.Contains(got, `"ShardQueries":4`) {
+		t.Errorf("json2 format: %q", got)
+	}
+}
+
+func TestLogStatsQueryFingerprint(t *testing.T) {
+	fingerprint := func(sql string) string {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = sql
+		return logStats.QueryFingerprint()
+	}
+
+	got := fingerprint("select * from t where id = 1 and name = 'a'")
+	if len(got) != 16 {
+		t.Errorf("fingerprint length: %q", got)
+	}
+	if other := fingerprint("select * from t where id = 42 and name = 'bcd'"); other != got {
+		t.Errorf("fingerprints differ only in literals: %q != %q", other, got)
+	}
+	if other := fingerprint("select * from t where id = 1 and other = 'a'"); other == got {
+		t.Errorf("fingerprints of different queries match: %q", other)
+	}
+	// The fingerprint must not depend on the process.
+	if got != "9591db9abda527d5" {
+		t.Errorf("fingerprint: %q", got)
+	}
+
+	// Unparseable statements get distinct fingerprints.
+	if fingerprint("not sql 1") == fingerprint("not sql 2") {
+		t.Errorf("fingerprints of unparseable statements match")
+	}
+
+	// The cached fingerprint follows changes to OriginalSQL.
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select * from t where id = 1 and other = 'a'"
+	first := logStats.QueryFingerprint()
+	if again := logStats.QueryFingerprint(); again != first {
+		t.Errorf("cached fingerprint: %q != %q", again, first)
+	}
+	logStats.OriginalSQL = "select * from t where id = 1 and name = 'a'"
+	if got := logStats.QueryFingerprint(); got != "9591db9abda527d5" {
+		t.Errorf("fingerprint after OriginalSQL changed: %q", got)
+	}
+}
+
+func TestLogStatsPhaseTimes(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	// The phases are consecutive, starting a second in the past.
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.StartTime = logStats.StartTime.Add(-time.Second)
+	logStats.OriginalSQL = "sql"
+	logStats.AddPlanTime(logStats.StartTime)
+	logStats.AddRewrittenSQL("sql", time.Now())
+	logStats.AddCommitTime(time.Now())
+	logStats.EndTime = time.Now()
+
+	if logStats.PlanTime < time.Second {
+		t.Errorf("PlanTime: %v, want >= 1s", logStats.PlanTime)
+	}
+	if sum := logStats.PlanTime + logStats.MysqlResponseTime + logStats.CommitTime; sum > logStats.TotalTime() {
+		t.Errorf("sum of phases %v exceeds TotalTime %v", sum, logStats.TotalTime())
+	}
+
+	*streamlog.QueryLogFormat = "json"
+	got := testFormat(logStats, nil)
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("logstats is not valid json: %v (%s)", err, got)
+	}
+	if parsed["PlanTime"].(float64) < 1 {
+		t.Errorf("json PlanTime: %v", parsed["PlanTime"])
+	}
+	if _, ok := parsed["CommitTime"]; !ok {
+		t.Errorf("json CommitTime missing: %s", got)
+	}
+
+	*streamlog.QueryLogFormat = "json2"
+	got = testFormat(logStats, nil)
+	var decoded struct{ PlanTime, CommitTime int64 }
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("logstats is not valid json: %v (%s)", err, got)
+	}
+	if time.Duration(decoded.PlanTime) != logStats.PlanTime || time.Duration(decoded.CommitTime) != logStats.CommitTime {
+		t.Errorf("json2 phase times: %v %v", decoded.PlanTime, decoded.CommitTime)
+	}
+}
+
+func TestLogStatsTimeLayout(t *testing.T) {
+	defer func(saved string) { *LogTimeLayout = saved }(*LogTimeLayout)
+	defer SetLogTimeLocation("")
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 1234, time.UTC)
+	logStats.OriginalSQL = "sql"
+
+	*LogTimeLayout = time.RFC3339Nano
+	if err := SetLogTimeLocation("America/New_York"); err != nil {
+		t.Fatal(err)
+	}
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	want := "\t2016-12-31T20:02:03-05:00\t2016-12-31T20:02:04.000001234-05:00\t"
+	if !strings.Contains(got, want) {
+		t.Errorf("text format: %q, want %q", got, want)
+	}
+
+	*streamlog.QueryLogFormat = "json"
+	got = testFormat(logStats, nil)
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("logstats is not valid json: %v (%s)", err, got)
+	}
+	if parsed["Start"] != "2016-12-31T20:02:03-05:00" || parsed["End"] != "2016-12-31T20:02:04.000001234-05:00" {
+		t.Errorf("json times: %v %v", parsed["Start"], parsed["End"])
+	}
+
+	if err := SetLogTimeLocation("Not/AZone"); err == nil {
+		t.Errorf("SetLogTimeLocation accepted an invalid zone")
+	}
+}
+
+func TestLogStatsPlanID(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 1234, time.UTC)
+	logStats.OriginalSQL = "select * from t where id = 1"
+	logStats.PlanID = "select * from t where id = 1"
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if !strings.HasSuffix(got, "\t\"select * from t where id = 1\"\t0\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n") {
+		t.Errorf("text format: %q", got)
+	}
+
+	*streamlog.QueryLogFormat = "json"
+	got = testFormat(logStats, nil)
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("logstats is not valid json: %v (%s)", err, got)
+	}
+	if parsed["PlanID"] != "select * from t where id = 1" {
+		t.Errorf("json PlanID: %v", parsed["PlanID"])
+	}
+
+	*streamlog.QueryLogFormat = "json2"
+	*RedactSQL = true
+	got = testFormat(logStats, nil)
+	*RedactSQL = false
+	if !strings.Contains(got, `"PlanID":"select * from t where id = :redacted1"`) {
+		t.Errorf("json2 format: %q", got)
+	}
+}
+
+func TestLogStatsPlanCacheHit(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	for _, hit := range []bool{false, true} {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = "select * from t"
+		logStats.PlanCacheHit = hit
+
+		*streamlog.QueryLogFormat = "text"
+		got := testFormat(logStats, nil)
+		if want := fmt.Sprintf("\t%v\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n", hit); !strings.HasSuffix(got, want) {
+			t.Errorf("text format: %q, want suffix %q", got, want)
+		}
+
+		*streamlog.QueryLogFormat = "json"
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid json: %v (%s)", err, got)
+		}
+		if parsed["PlanCacheHit"] != hit {
+			t.Errorf("json PlanCacheHit: %v, want %v", parsed["PlanCacheHit"], hit)
+		}
+
+		*streamlog.QueryLogFormat = "json2"
+		got = testFormat(logStats, nil)
+		if want := fmt.Sprintf(`"PlanCacheHit":%v,`, hit); !strings.Contains(got, want) {
+			t.Errorf("json2 format: %q, want %q", got, want)
+		}
+	}
+}
+
+func TestLogStatsReservedConn(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	testcases := []struct {
+		name       string
+		reserved   bool
+		reservedID int64
+	}{{
+		name: "not reserved",
+	}, {
+		name:       "reserved",
+		reserved:   true,
+		reservedID: 42,
+	}}
+	for _, tcase := range testcases {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = "select * from t"
+		logStats.ReservedConn = tcase.reserved
+		logStats.ReservedID = tcase.reservedID
+
+		*streamlog.QueryLogFormat = "text"
+		got := testFormat(logStats, nil)
+		if want := fmt.Sprintf("\t%v\t%d\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n", tcase.reserved, tcase.reservedID); !strings.HasSuffix(got, want) {
+			t.Errorf("%s: text format: %q, want suffix %q", tcase.name, got, want)
+		}
+
+		for _, format := range []string{"json", "json2"} {
+			*streamlog.QueryLogFormat = format
+			got = testFormat(logStats, nil)
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+				t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+			}
+			if parsed["ReservedConn"] != tcase.reserved || parsed["ReservedID"] != float64(tcase.reservedID) {
+				t.Errorf("%s: %s ReservedConn: %v, ReservedID: %v", tcase.name, format, parsed["ReservedConn"], parsed["ReservedID"])
+			}
+		}
+	}
+}
+
+func TestLogStatsConnWait(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select * from t"
+	logStats.AddConnWait(time.Now())
+	logStats.AddConnWait(time.Now())
+	if logStats.ConnWaitCount != 2 {
+		t.Errorf("ConnWaitCount: %d, want 2", logStats.ConnWaitCount)
+	}
+
+	logStats.WaitingForConnection = 1500 * time.Millisecond
+	logStats.ConnWaitCount = 3
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\t0.000000\t1.500000\t"; !strings.Contains(got, want) {
+		t.Errorf("text format: %q, want ConnWaitTime %q", got, want)
+	}
+	if want := "\t3\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	wantTime := map[string]float64{"json": 1.5, "json2": float64(1500 * time.Millisecond)}
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["ConnWaitCount"] != float64(3) || parsed["ConnWaitTime"] != wantTime[format] {
+			t.Errorf("%s ConnWaitCount: %v, ConnWaitTime: %v", format, parsed["ConnWaitCount"], parsed["ConnWaitTime"])
+		}
+	}
+}
+
+func TestLogStatsRequestSeq(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	env := NewEnv(NewDefaultConfig(), "LogStatsRequestSeqTest")
+	var last int64
+	for i := 0; i < 3; i++ {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = "select * from t"
+		logStats.RequestSeq = env.NextRequestSeq()
+		if logStats.RequestSeq <= last {
+			t.Errorf("RequestSeq: %d, want > %d", logStats.RequestSeq, last)
+		}
+		last = logStats.RequestSeq
+
+		*streamlog.QueryLogFormat = "text"
+		got := testFormat(logStats, nil)
+		if want := fmt.Sprintf("\t%d\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n", logStats.RequestSeq); !strings.HasSuffix(got, want) {
+			t.Errorf("text format: %q, want suffix %q", got, want)
+		}
+
+		for _, format := range []string{"json", "json2"} {
+			*streamlog.QueryLogFormat = format
+			got = testFormat(logStats, nil)
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+				t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+			}
+			if parsed["RequestSeq"] != float64(logStats.RequestSeq) {
+				t.Errorf("%s RequestSeq: %v, want %d", format, parsed["RequestSeq"], logStats.RequestSeq)
+			}
+		}
+	}
+}
+
+func TestLogStatsTransactionMode(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	for _, mode := range []vtgatepb.TransactionMode{
+		vtgatepb.TransactionMode_SINGLE,
+		vtgatepb.TransactionMode_UNSPECIFIED,
+		vtgatepb.TransactionMode_TWOPC,
+	} {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = "select * from t"
+		logStats.TransactionMode = mode
+
+		*streamlog.QueryLogFormat = "text"
+		got := testFormat(logStats, nil)
+		if want := fmt.Sprintf("\t0\t%v\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n", mode); !strings.HasSuffix(got, want) {
+			t.Errorf("text format: %q, want suffix %q", got, want)
+		}
+
+		for _, format := range []string{"json", "json2"} {
+			*streamlog.QueryLogFormat = format
+			got = testFormat(logStats, nil)
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+				t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+			}
+			if parsed["TransactionMode"] != mode.String() {
+				t.Errorf("%s TransactionMode: %v, want %v", format, parsed["TransactionMode"], mode)
+			}
+		}
+	}
+
+	// Statements outside of a transaction default to SINGLE.
+	if got := NewLogStats(context.Background(), "test").TransactionMode; got != vtgatepb.TransactionMode_SINGLE {
+		t.Errorf("TransactionMode: %v, want SINGLE", got)
+	}
+}
+
+func TestLogStatsWireBytesSent(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "a", Type: sqltypes.VarChar}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar("abc")}, {sqltypes.NewVarChar("de")}},
+	}
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select a from t"
+	logStats.Rows = result.Rows
+	logStats.AddWireResult(result)
+	logStats.AddWireResult(nil)
+	if logStats.WireBytesSent <= int64(logStats.SizeOfResponse()) {
+		t.Errorf("WireBytesSent: %d, want more than the ResponseSize of %d", logStats.WireBytesSent, logStats.SizeOfResponse())
+	}
+	logStats.WireBytesSent = 1234
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if !strings.Contains(got, "\t0\t5\t\"\"\t") {
+		t.Errorf("text format: %q, want a ResponseSize of 5", got)
+	}
+	if want := "\tSINGLE\t1234\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["WireBytesSent"] != float64(1234) {
+			t.Errorf("%s WireBytesSent: %v, want 1234", format, parsed["WireBytesSent"])
+		}
+		if parsed["ResponseSize"] != float64(5) {
+			t.Errorf("%s ResponseSize: %v, want 5", format, parsed["ResponseSize"])
+		}
+	}
+
+	// Internal queries are accounted for, even though they're not logged.
+	noop := NewLogStatsNoop(context.Background(), "test")
+	noop.AddWireResult(result)
+	if noop.WireBytesSent == 0 {
+		t.Errorf("noop WireBytesSent: %d, want more than 0", noop.WireBytesSent)
+	}
+}
+
+func TestLogStatsKeyspaceShard(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select * from t"
+	logStats.Keyspace = "ks"
+	logStats.Shard = "-80"
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\t\"ks\"\t\"-80\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["Keyspace"] != "ks" || parsed["Shard"] != "-80" {
+			t.Errorf("%s Keyspace, Shard: %v, %v, want ks, -80", format, parsed["Keyspace"], parsed["Shard"])
+		}
+	}
+}
+
+func TestLogStatsIsolationLevel(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select * from t"
+	logStats.IsolationLevel = "READ COMMITTED"
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\t\"\"\t\"\"\t\"READ COMMITTED\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["IsolationLevel"] != "READ COMMITTED" {
+			t.Errorf("%s IsolationLevel: %v, want READ COMMITTED", format, parsed["IsolationLevel"])
+		}
+	}
+}
+
+func TestLogStatsMysqlRoundTrips(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "insert into t values (1)"
+	logStats.AddRewrittenSQL("insert into t values (1)", time.Now())
+	logStats.AddMysqlRoundTrip()
+	logStats.AddMysqlRoundTrip()
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	fields := strings.Split(got, "\t")
+	// Queries is the 12th field, and MysqlRoundTrips comes before
+	// the query comments, the backup engine, the column count, the
+	// warning count and the tags.
+	if fields[11] != "1" || fields[len(fields)-7] != "3" {
+		t.Errorf("text format: %q, want 1 query and 3 round trips", got)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["Queries"] != 1.0 || parsed["MysqlRoundTrips"] != 3.0 {
+			t.Errorf("%s Queries, MysqlRoundTrips: %v, %v, want 1, 3", format, parsed["Queries"], parsed["MysqlRoundTrips"])
+		}
+	}
+
+	noop := NewLogStatsNoop(context.Background(), "test")
+	noop.AddMysqlRoundTrip()
+	if noop.MysqlRoundTrips != 1 {
+		t.Errorf("noop MysqlRoundTrips: %d, want 1", noop.MysqlRoundTrips)
+	}
+}
+
+func TestLogStatsQueryComments(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	testcases := []struct {
+		sql  string
+		want string
+	}{
+		{"select 1", ""},
+		{"/*+ VT_SPREAD */ select 1", "/*+ VT_SPREAD */"},
+		{"select 1 /* trace_id=abc */", "/* trace_id=abc */"},
+		{"/* app=web */ select /* inner */ 1 /* trace_id=abc */", "/* app=web */ /* trace_id=abc */"},
+	}
+	for _, tcase := range testcases {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = tcase.sql
+		if got := logStats.QueryComments(); got != tcase.want {
+			t.Errorf("QueryComments(%q): %q, want %q", tcase.sql, got, tcase.want)
+		}
+
+		*streamlog.QueryLogFormat = "text"
+		got := testFormat(logStats, nil)
+		if want := fmt.Sprintf("\t%q\t\"\"\t0\t0\t\"\"\t\n", tcase.want); !strings.HasSuffix(got, want) {
+			t.Errorf("text format: %q, want suffix %q", got, want)
+		}
+
+		for _, format := range []string{"json", "json2"} {
+			*streamlog.QueryLogFormat = format
+			got = testFormat(logStats, nil)
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+				t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+			}
+			if parsed["QueryComments"] != tcase.want {
+				t.Errorf("%s QueryComments: %v, want %q", format, parsed["QueryComments"], tcase.want)
+			}
+		}
+	}
+}
+
+func TestLogStatsBackupEngine(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	if got := NewLogStats(context.Background(), "test").BackupEngine; got != "" {
+		t.Errorf("BackupEngine without engine: %q, want empty", got)
+	}
+
+	ctx := WithBackupEngine(context.Background(), "xtrabackup")
+	logStats := NewLogStats(ctx, "test")
+	logStats.OriginalSQL = "select 1"
+	if logStats.BackupEngine != "xtrabackup" {
+		t.Errorf("BackupEngine: %q, want xtrabackup", logStats.BackupEngine)
+	}
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\t\"\"\t\"xtrabackup\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["BackupEngine"] != "xtrabackup" {
+			t.Errorf("%s BackupEngine: %v, want xtrabackup", format, parsed["BackupEngine"])
+		}
+	}
+
+	// An inner context overrides the engine.
+	ctx = WithBackupEngine(ctx, "builtin")
+	if got := BackupEngineFromContext(ctx); got != "builtin" {
+		t.Errorf("BackupEngineFromContext: %q, want builtin", got)
+	}
+}
+
+func TestLogStatsColumnCount(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select * from t"
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields("a|b|c", "int64|varchar|int64"), "1|aaa|2")
+	logStats.ColumnCount = len(result.Fields)
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\t\"\"\t\"\"\t3\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["ColumnCount"] != float64(3) {
+			t.Errorf("%s ColumnCount: %v, want 3", format, parsed["ColumnCount"])
+		}
+	}
+}
+
+func TestLogStatsWarnings(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "insert into t values ('abc')"
+	logStats.AddWarnings([]string{"Warning 1265: Data truncated for column 'a' at row 1"})
+	logStats.AddWarnings(nil)
+	logStats.AddWarnings([]string{"Note 1051: Unknown table 'b'"})
+	want := []string{
+		"Warning 1265: Data truncated for column 'a' at row 1",
+		"Note 1051: Unknown table 'b'",
+	}
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if suffix := "\t2\t\"\"\t\n"; !strings.HasSuffix(got, suffix) {
+		t.Errorf("text format: %q, want suffix %q", got, suffix)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed struct {
+			Warnings []string
+		}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if !reflect.DeepEqual(parsed.Warnings, want) {
+			t.Errorf("%s Warnings: %v, want %v", format, parsed.Warnings, want)
+		}
+	}
+}
+
+func TestLogStatsRowsReturned(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	testcases := []struct {
+		sql          string
+		rows         [][]sqltypes.Value
+		rowsAffected int
+	}{{
+		sql:  "select a from t",
+		rows: [][]sqltypes.Value{{sqltypes.NewInt64(1)}, {sqltypes.NewInt64(2)}},
+	}, {
+		sql:          "update t set a = 1",
+		rowsAffected: 3,
+	}}
+	for _, tcase := range testcases {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = tcase.sql
+		logStats.Rows = tcase.rows
+		logStats.RowsAffected = tcase.rowsAffected
+		if got, want := logStats.RowsReturned(), len(tcase.rows); got != want {
+			t.Errorf("%s: RowsReturned: %d, want %d", tcase.sql, got, want)
+		}
+
+		*streamlog.QueryLogFormat = "json"
+		got := testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid json: %v (%s)", err, got)
+		}
+		if parsed["RowsReturned"] != float64(len(tcase.rows)) || parsed["RowsAffected"] != float64(tcase.rowsAffected) {
+			t.Errorf("%s: json RowsReturned: %v, RowsAffected: %v", tcase.sql, parsed["RowsReturned"], parsed["RowsAffected"])
+		}
+
+		*streamlog.QueryLogFormat = "text"
+		got = testFormat(logStats, nil)
+		if want := fmt.Sprintf("\t%d\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n", len(tcase.rows)); !strings.HasSuffix(got, want) {
+			t.Errorf("%s: text format: %q, want suffix %q", tcase.sql, got, want)
+		}
+	}
+}
+
+func TestLogStatsFormatQuerySourceTimings(t *testing.T) {
+	defer func() { *EmitQuerySourceTimings = false }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 1234, time.UTC)
+	logStats.OriginalSQL = "sql"
+	logStats.AddRewrittenSQL("sql", time.Now())
+	logStats.MysqlResponseTime = 500 * time.Millisecond
+	logStats.QuerySources |= QuerySourceConsolidator
+	logStats.ConsolidatorWaitTime = 250 * time.Millisecond
+
+	*EmitQuerySourceTimings = true
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	want := "test\t\t\t''\t''\t2017-01-01 01:02:03.000000\t2017-01-01 01:02:04.000001\t1.000001\t\t\"sql\"\tmap[]\t1\t\"sql\"\tmysql,consolidator\t0.500000\t0.000000\t0\t0\t\"\"\t0\t4ec7c53222c8a758\t0.000000\t0.000000\t\"\"\t0\t0\t0\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t1\t\"\"\t\"\"\t0\t0\t\"\"\tmysql:0.500000,consolidator:0.250000\t\n"
+	if got != want {
+		t.Errorf("logstats format: got:\n%q\nwant:\n%q\n", got, want)
+	}
+
+	*streamlog.QueryLogFormat = "json"
+	got = testFormat(logStats, nil)
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("logstats format: error unmarshaling json: %v -- got:\n%v", err, got)
+	}
+	timings, ok := parsed["QuerySourceTimings"].(map[string]interface{})
+	if !ok || timings["mysql"] != 0.5 || timings["consolidator"] != 0.25 {
+		t.Errorf("logstats format: unexpected QuerySourceTimings: %v", parsed["QuerySourceTimings"])
+	}
+
+	*EmitQuerySourceTimings = false
+	got = testFormat(logStats, nil)
+	if strings.Contains(got, "QuerySourceTimings") {
+		t.Errorf("logstats format: QuerySourceTimings should not be emitted by default: %v", got)
+	}
+	*streamlog.QueryLogFormat = "text"
+}
+
 func TestLogStatsContextHTML(t *testing.T) {
 	html := "HtmlContext"
 	callInfo := &fakecallinfo.FakeCallInfo{
@@ -253,3 +1501,269 @@ func TestLogStatsCallInfo(t *testing.T) {
 		t.Fatalf("expected to get username: %s, but got: %s", username, user)
 	}
 }
+
+func TestLogStatsRegisterFormatter(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	RegisterLogStatsFormatter("logfmt", LogStatsFormatterFunc(func(stats *LogStats, params url.Values) string {
+		_, full := params["full"]
+		return fmt.Sprintf("method=%s sql=%q full=%v\n", stats.Method, stats.OriginalSQL, full)
+	}))
+	defer delete(logStatsFormatters, "logfmt")
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select 1"
+
+	*streamlog.QueryLogFormat = "logfmt"
+	got := testFormat(logStats, url.Values{"full": {}})
+	want := "method=test sql=\"select 1\" full=true\n"
+	if got != want {
+		t.Errorf("logfmt format: %q, want %q", got, want)
+	}
+
+	*streamlog.QueryLogFormat = "unknown"
+	var buf bytes.Buffer
+	err := logStats.Logf(&buf, nil)
+	want = "unknown querylog-format \"unknown\""
+	if err == nil || err.Error() != want {
+		t.Errorf("Logf with unknown format: %v, want %s", err, want)
+	}
+}
+
+func TestLogStatsTransaction(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	testcases := []struct {
+		name           string
+		transactionID  int64
+		savepointDepth int
+	}{{
+		name: "autocommit",
+	}, {
+		name:           "in transaction",
+		transactionID:  1234,
+		savepointDepth: 2,
+	}}
+	for _, tcase := range testcases {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = "select 1"
+		logStats.TransactionID = tcase.transactionID
+		logStats.SavepointDepth = tcase.savepointDepth
+
+		*streamlog.QueryLogFormat = "text"
+		got := testFormat(logStats, nil)
+		if want := fmt.Sprintf("\t%d\t%d\t0\t\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n", tcase.transactionID, tcase.savepointDepth); !strings.HasSuffix(got, want) {
+			t.Errorf("%s: text format: %q, want suffix %q", tcase.name, got, want)
+		}
+
+		for _, format := range []string{"json", "json2"} {
+			*streamlog.QueryLogFormat = format
+			got = testFormat(logStats, nil)
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+				t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+			}
+			if parsed["TransactionID"] != float64(tcase.transactionID) || parsed["SavepointDepth"] != float64(tcase.savepointDepth) {
+				t.Errorf("%s: %s TransactionID: %v, SavepointDepth: %v", tcase.name, format, parsed["TransactionID"], parsed["SavepointDepth"])
+			}
+		}
+	}
+}
+
+func TestLogStatsMysqlRows(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select a from t"
+	logStats.AddMysqlResult(nil)
+	if logStats.MysqlRowsSent != 0 {
+		t.Errorf("MysqlRowsSent after nil result: %d, want 0", logStats.MysqlRowsSent)
+	}
+	logStats.AddMysqlResult(&sqltypes.Result{Rows: [][]sqltypes.Value{{sqltypes.NewInt64(1)}, {sqltypes.NewInt64(2)}}})
+	logStats.AddMysqlResult(&sqltypes.Result{Rows: [][]sqltypes.Value{{sqltypes.NewInt64(3)}}})
+	if logStats.MysqlRowsSent != 3 {
+		t.Errorf("MysqlRowsSent: %d, want 3", logStats.MysqlRowsSent)
+	}
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\t0\t3\t\tfalse\t3\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["MysqlRowsSent"] != float64(3) {
+			t.Errorf("%s MysqlRowsSent: %v, want 3", format, parsed["MysqlRowsSent"])
+		}
+		if _, ok := parsed["MysqlRowsExamined"]; ok {
+			t.Errorf("%s MysqlRowsExamined is logged: %s", format, got)
+		}
+	}
+}
+
+func TestLogStatsMysqlResponseBytes(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStats(context.Background(), "test")
+	logStats.OriginalSQL = "select a from t"
+	logStats.AddMysqlResult(&sqltypes.Result{Rows: [][]sqltypes.Value{
+		{sqltypes.NewVarBinary("abcd"), sqltypes.NewInt64(12)},
+		{sqltypes.NewVarBinary("efgh"), sqltypes.NewInt64(345)},
+	}})
+	if logStats.MysqlResponseBytes != 13 {
+		t.Errorf("MysqlResponseBytes: %d, want 13", logStats.MysqlResponseBytes)
+	}
+	// The client only gets a projection of the first row.
+	logStats.Rows = [][]sqltypes.Value{{sqltypes.NewVarBinary("abcd")}}
+	if got := logStats.SizeOfResponse(); got != 4 {
+		t.Errorf("SizeOfResponse: %d, want 4", got)
+	}
+
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\t0\t4\t\"\"\t"; !strings.Contains(got, want) {
+		t.Errorf("text format: %q, want ResponseSize %q", got, want)
+	}
+	if want := "\tfalse\t13\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+
+	for _, format := range []string{"json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		got = testFormat(logStats, nil)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+			t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+		}
+		if parsed["MysqlResponseBytes"] != float64(13) || parsed["ResponseSize"] != float64(4) {
+			t.Errorf("%s MysqlResponseBytes: %v, ResponseSize: %v", format, parsed["MysqlResponseBytes"], parsed["ResponseSize"])
+		}
+	}
+}
+
+func TestLogStatsErrorCode(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	testcases := []struct {
+		name string
+		err  error
+		want string
+	}{{
+		name: "no error",
+	}, {
+		name: "coded error",
+		err:  vterrors.Errorf(vtrpcpb.Code_RESOURCE_EXHAUSTED, "pool full"),
+		want: "RESOURCE_EXHAUSTED",
+	}, {
+		name: "plain error",
+		err:  errors.New("plain"),
+		want: "UNKNOWN",
+	}}
+	for _, tcase := range testcases {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = "select 1"
+		logStats.Error = tcase.err
+		if got := logStats.ErrorCode(); got != tcase.want {
+			t.Errorf("%s: ErrorCode: %q, want %q", tcase.name, got, tcase.want)
+		}
+
+		*streamlog.QueryLogFormat = "text"
+		got := testFormat(logStats, nil)
+		if want := "\t" + tcase.want + "\tfalse\t0\tfalse\t0\t0\t0\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"\"\t\n"; !strings.HasSuffix(got, want) {
+			t.Errorf("%s: text format: %q, want suffix %q", tcase.name, got, want)
+		}
+
+		for _, format := range []string{"json", "json2"} {
+			*streamlog.QueryLogFormat = format
+			got = testFormat(logStats, nil)
+			var parsed map[string]interface{}
+			if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+				t.Fatalf("logstats is not valid %s: %v (%s)", format, err, got)
+			}
+			if parsed["ErrorCode"] != tcase.want {
+				t.Errorf("%s: %s ErrorCode: %v, want %s", tcase.name, format, parsed["ErrorCode"], tcase.want)
+			}
+		}
+	}
+}
+
+func TestLogStatsTags(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	ctx := WithLogTags(context.Background(), map[string]string{"tenant": "acme", "feature": "old"})
+	ctx = WithLogTags(ctx, map[string]string{"feature": "new", "zone": "a"})
+	logStats := NewLogStats(ctx, "test")
+	logStats.OriginalSQL = "select 1"
+	want := map[string]string{"feature": "new", "tenant": "acme", "zone": "a"}
+	if !reflect.DeepEqual(logStats.Tags, want) {
+		t.Errorf("Tags: %v, want %v", logStats.Tags, want)
+	}
+
+	// The rendering is sorted by key.
+	*streamlog.QueryLogFormat = "text"
+	got := testFormat(logStats, nil)
+	if want := "\tSINGLE\t0\t\"\"\t\"\"\t\"\"\t0\t\"\"\t\"\"\t0\t0\t\"feature=new,tenant=acme,zone=a\"\t\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("text format: %q, want suffix %q", got, want)
+	}
+	*streamlog.QueryLogFormat = "json"
+	got = testFormat(logStats, nil)
+	if want := `"Tags": {"feature":"new","tenant":"acme","zone":"a"}}` + "\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("json format: %q, want suffix %q", got, want)
+	}
+	*streamlog.QueryLogFormat = "json2"
+	got = testFormat(logStats, nil)
+	if want := `"Tags":{"feature":"new","tenant":"acme","zone":"a"}}` + "\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("json2 format: %q, want suffix %q", got, want)
+	}
+
+	// A custom extractor can derive the tags from other values.
+	defer SetLogTagsExtractor(LogTagsFromContext)
+	SetLogTagsExtractor(func(ctx context.Context) map[string]string {
+		return map[string]string{"method": "custom"}
+	})
+	logStats = NewLogStats(ctx, "test")
+	if want := map[string]string{"method": "custom"}; !reflect.DeepEqual(logStats.Tags, want) {
+		t.Errorf("Tags: %v, want %v", logStats.Tags, want)
+	}
+}
+
+func TestLogStatsNoop(t *testing.T) {
+	defer func() { *streamlog.QueryLogFormat = "text" }()
+
+	logStats := NewLogStatsNoop(LocalContext(), "test")
+	logStats.OriginalSQL = "select 1"
+	start := time.Now().Add(-time.Second)
+	logStats.AddRewrittenSQL("select 1 from dual", start)
+	logStats.AddConsolidatorWait(start)
+	logStats.AddPlanTime(start)
+	logStats.AddCommitTime(start)
+	// The query is still accounted for.
+	if got := logStats.RewrittenSQL(); got != "select 1 from dual" {
+		t.Errorf("RewrittenSQL: %q, want select 1 from dual", got)
+	}
+	if logStats.NumberOfQueries != 1 || logStats.QuerySources != QuerySourceMySQL|QuerySourceConsolidator || logStats.MysqlResponseTime < time.Second || logStats.PlanTime < time.Second || logStats.CommitTime < time.Second {
+		t.Errorf("noop LogStats didn't record the query: %+v", logStats)
+	}
+
+	for _, format := range []string{"text", "json", "json2"} {
+		*streamlog.QueryLogFormat = format
+		if got := testFormat(logStats, url.Values{"full": {}}); got != "" {
+			t.Errorf("%s format: %q, want empty", format, got)
+		}
+	}
+
+	ch := StatsLogger.Subscribe("test")
+	defer StatsLogger.Unsubscribe(ch)
+	logStats.Send()
+	select {
+	case <-ch:
+		t.Errorf("noop LogStats was sent")
+	default:
+	}
+}
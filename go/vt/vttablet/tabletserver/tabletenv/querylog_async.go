@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"flag"
+	"io"
+	"sync"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/sync2"
+)
+
+var (
+	// QueryLogAsyncQueueSize is the number of query log records that can be
+	// waiting to be written to the file. If 0, records are written synchronously.
+	QueryLogAsyncQueueSize = flag.Int("querylog-async-queue-size", 0, "number of query log records queued for a background writer, records are dropped when the queue is full; 0 writes synchronously")
+
+	asyncLogDropped = stats.NewCounter("QueryLogAsyncDropped", "Number of query log records dropped because the write queue was full")
+)
+
+// AsyncLogWriter hands the records written to it to a background
+// goroutine that writes them to the underlying writer, so that a slow
+// writer doesn't hold up the caller. When the queue is full, records
+// are dropped and counted instead of blocking.
+type AsyncLogWriter struct {
+	w       io.Writer
+	queue   chan []byte
+	dropped sync2.AtomicInt64
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewAsyncLogWriter returns an AsyncLogWriter that queues up to
+// queueSize records for w.
+func NewAsyncLogWriter(w io.Writer, queueSize int) *AsyncLogWriter {
+	aw := &AsyncLogWriter{
+		w:     w,
+		queue: make(chan []byte, queueSize),
+	}
+	aw.wg.Add(1)
+	go func() {
+		defer aw.wg.Done()
+		for record := range aw.queue {
+			aw.w.Write(record)
+		}
+	}()
+	return aw
+}
+
+// Write queues a copy of p. It never blocks: if the queue is full or the
+// writer is closed, the record is dropped. Errors of the underlying
+// writer are not reported.
+func (aw *AsyncLogWriter) Write(p []byte) (int, error) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	if aw.closed {
+		aw.drop()
+		return len(p), nil
+	}
+	select {
+	case aw.queue <- append([]byte(nil), p...):
+	default:
+		aw.drop()
+	}
+	return len(p), nil
+}
+
+func (aw *AsyncLogWriter) drop() {
+	aw.dropped.Add(1)
+	asyncLogDropped.Add(1)
+}
+
+// Dropped returns the number of records dropped by this writer.
+func (aw *AsyncLogWriter) Dropped() int64 {
+	return aw.dropped.Get()
+}
+
+// Close waits for the queued records to be written. It does not close
+// the underlying writer.
+func (aw *AsyncLogWriter) Close() error {
+	aw.mu.Lock()
+	if !aw.closed {
+		aw.closed = true
+		close(aw.queue)
+	}
+	aw.mu.Unlock()
+	aw.wg.Wait()
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2020 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter blocks every write until release is closed.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	out     syncBuffer
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case bw.started <- struct{}{}:
+	default:
+	}
+	<-bw.release
+	return bw.out.Write(p)
+}
+
+func TestAsyncLogWriter(t *testing.T) {
+	bw := &blockingWriter{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	aw := NewAsyncLogWriter(bw, 2)
+	droppedBefore := asyncLogDropped.Get()
+
+	records := logRecords(t, 5)
+	// The first record is taken by the writer goroutine, which then blocks.
+	_, err := aw.Write([]byte(records[0]))
+	require.NoError(t, err)
+	<-bw.started
+	// The next two fill the queue, and the rest are dropped.
+	for _, record := range records[1:] {
+		n, err := aw.Write([]byte(record))
+		require.NoError(t, err)
+		assert.Equal(t, len(record), n)
+	}
+	assert.EqualValues(t, 2, aw.Dropped())
+	assert.EqualValues(t, 2, asyncLogDropped.Get()-droppedBefore)
+
+	close(bw.release)
+	require.NoError(t, aw.Close())
+	assert.Equal(t, strings.Join(records[:3], ""), string(bw.out.Bytes()))
+
+	// Writes after Close are dropped.
+	_, err = aw.Write([]byte(records[0]))
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, aw.Dropped())
+	require.NoError(t, aw.Close())
+}
+
+func TestQueryLogWriterWrapperAsync(t *testing.T) {
+	defer func() {
+		*QueryLogGzip = false
+		*QueryLogAsyncQueueSize = 0
+	}()
+
+	*QueryLogAsyncQueueSize = 10
+	wrap := QueryLogWriterWrapper()
+	require.NotNil(t, wrap)
+	var out bytes.Buffer
+	w := wrap(&out)
+	_, err := w.Write([]byte("test\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, "test\n", out.String())
+
+	// With compression, the queue is drained before the gzip stream ends.
+	*QueryLogGzip = true
+	wrap = QueryLogWriterWrapper()
+	out.Reset()
+	w = wrap(&out)
+	for i := 0; i < 3; i++ {
+		_, err := w.Write([]byte("test\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	gz, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "test\ntest\ntest\n", string(got))
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"compress/gzip"
+	"flag"
+	"io"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/streamlog"
+)
+
+var (
+	// QueryLogGzip controls whether the query log written to a file is gzip compressed.
+	QueryLogGzip = flag.Bool("querylog-gzip", false, "gzip compress the query log written to a file")
+
+	// QueryLogGzipFlushInterval is how often the compressed query log is flushed to the file.
+	QueryLogGzipFlushInterval = flag.Duration("querylog-gzip-flush-interval", 1*time.Second, "how often the gzip compressed query log is flushed to the file, 0 flushes after every record")
+)
+
+// QueryLogWriterWrapper returns the streamlog.WriterWrapper to use for the
+// query log file, based on the querylog-gzip and querylog-async flags, or
+// nil if the query log is written uncompressed and synchronously.
+func QueryLogWriterWrapper() streamlog.WriterWrapper {
+	gzipped, queueSize := *QueryLogGzip, *QueryLogAsyncQueueSize
+	if !gzipped && queueSize <= 0 {
+		return nil
+	}
+	interval := *QueryLogGzipFlushInterval
+	return func(w io.Writer) io.WriteCloser {
+		var closers chainedClosers
+		if gzipped {
+			gw := NewGzipLogWriter(w, interval)
+			closers = append(closers, gw)
+			w = gw
+		}
+		if queueSize > 0 {
+			aw := NewAsyncLogWriter(w, queueSize)
+			// The queue must be drained before the gzip footer is written.
+			closers = append(chainedClosers{aw}, closers...)
+			w = aw
+		}
+		return struct {
+			io.Writer
+			io.Closer
+		}{w, closers}
+	}
+}
+
+// chainedClosers closes its members in order.
+type chainedClosers []io.Closer
+
+func (cc chainedClosers) Close() error {
+	var firstErr error
+	for _, c := range cc {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// GzipLogWriter gzip compresses the query log records written to it.
+// Compressed data is flushed to the underlying writer every flushInterval,
+// so that records are not buffered indefinitely on a quiet tablet. Callers
+// are expected to write whole records, which is what streamlog does.
+type GzipLogWriter struct {
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	gz      *gzip.Writer
+	flushed bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewGzipLogWriter returns a GzipLogWriter that writes to w. If
+// flushInterval is 0, every write is flushed immediately.
+func NewGzipLogWriter(w io.Writer, flushInterval time.Duration) *GzipLogWriter {
+	gw := &GzipLogWriter{
+		flushInterval: flushInterval,
+		gz:            gzip.NewWriter(w),
+		flushed:       true,
+		done:          make(chan struct{}),
+	}
+	if flushInterval <= 0 {
+		return gw
+	}
+	gw.wg.Add(1)
+	go func() {
+		defer gw.wg.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				gw.Flush()
+			case <-gw.done:
+				return
+			}
+		}
+	}()
+	return gw
+}
+
+// Write compresses p. It's flushed on the next tick, or immediately if
+// there is no flush interval.
+func (gw *GzipLogWriter) Write(p []byte) (int, error) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	n, err := gw.gz.Write(p)
+	if err != nil {
+		return n, err
+	}
+	gw.flushed = false
+	if gw.flushInterval <= 0 {
+		return n, gw.flushLocked()
+	}
+	return n, nil
+}
+
+// Flush writes any pending compressed data to the underlying writer.
+func (gw *GzipLogWriter) Flush() error {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.flushLocked()
+}
+
+func (gw *GzipLogWriter) flushLocked() error {
+	if gw.flushed {
+		return nil
+	}
+	gw.flushed = true
+	return gw.gz.Flush()
+}
+
+// Close stops the periodic flush and writes the gzip footer. It does
+// not close the underlying writer.
+func (gw *GzipLogWriter) Close() error {
+	close(gw.done)
+	gw.wg.Wait()
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.gz.Close()
+}
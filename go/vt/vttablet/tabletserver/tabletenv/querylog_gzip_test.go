@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tabletenv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer that can be read while the flusher writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) Bytes() []byte {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return append([]byte(nil), sb.buf.Bytes()...)
+}
+
+func logRecords(t *testing.T, n int) []string {
+	t.Helper()
+	var records []string
+	for i := 0; i < n; i++ {
+		logStats := NewLogStats(context.Background(), "test")
+		logStats.OriginalSQL = "select * from t where id = 1"
+		var buf bytes.Buffer
+		require.NoError(t, logStats.Logf(&buf, nil))
+		records = append(records, buf.String())
+	}
+	return records
+}
+
+func TestGzipLogWriter(t *testing.T) {
+	var out bytes.Buffer
+	gw := NewGzipLogWriter(&out, 0)
+	records := logRecords(t, 10)
+	for _, record := range records {
+		_, err := gw.Write([]byte(record))
+		require.NoError(t, err)
+	}
+	require.NoError(t, gw.Close())
+
+	gz, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	want := ""
+	for _, record := range records {
+		want += record
+	}
+	assert.Equal(t, want, string(got))
+}
+
+func TestGzipLogWriterFlushInterval(t *testing.T) {
+	out := &syncBuffer{}
+	gw := NewGzipLogWriter(out, 10*time.Millisecond)
+	defer gw.Close()
+	records := logRecords(t, 3)
+	for _, record := range records {
+		_, err := gw.Write([]byte(record))
+		require.NoError(t, err)
+	}
+
+	// Without closing the writer, every record must become readable
+	// once the flusher has run, and no record may be cut short.
+	var lines []string
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		gz, err := gzip.NewReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			continue
+		}
+		lines = nil
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text()+"\n")
+		}
+		if len(lines) == len(records) {
+			break
+		}
+	}
+	assert.Equal(t, records, lines)
+}
+
+func TestQueryLogWriterWrapper(t *testing.T) {
+	defer func() { *QueryLogGzip = false }()
+
+	assert.Nil(t, QueryLogWriterWrapper())
+
+	*QueryLogGzip = true
+	wrap := QueryLogWriterWrapper()
+	require.NotNil(t, wrap)
+	var out bytes.Buffer
+	w := wrap(&out)
+	_, err := w.Write([]byte("test\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	gz, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "test\n", string(got))
+}
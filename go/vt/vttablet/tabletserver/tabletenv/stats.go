@@ -30,6 +30,9 @@ type Stats struct {
 	QueryTimings           *servenv.TimingsWrapper        // Query timings
 	QPSRates               *stats.Rates                   // Human readable QPS rates
 	WaitTimings            *servenv.TimingsWrapper        // waits like Consolidations etc
+	ComponentTimings       *servenv.TimingsWrapper        // Subcomponent open and close durations
+	TransitionTimings      *servenv.TimingsWrapper        // State transition durations and counts
+	TransitionErrors       *stats.CountersWithSingleLabel // Failed state transitions
 	KillCounters           *stats.CountersWithSingleLabel // Connection and transaction kills
 	ErrorCounters          *stats.CountersWithSingleLabel
 	InternalErrors         *stats.CountersWithSingleLabel
@@ -52,10 +55,13 @@ type Stats struct {
 // NewStats instantiates a new set of stats scoped by exporter.
 func NewStats(exporter *servenv.Exporter) *Stats {
 	stats := &Stats{
-		MySQLTimings: exporter.NewTimings("Mysql", "MySQl query time", "operation"),
-		QueryTimings: exporter.NewTimings("Queries", "MySQL query timings", "plan_type"),
-		WaitTimings:  exporter.NewTimings("Waits", "Wait operations", "type"),
-		KillCounters: exporter.NewCountersWithSingleLabel("Kills", "Number of connections being killed", "query_type", "Transactions", "Queries", "ReservedConnection"),
+		MySQLTimings:      exporter.NewTimings("Mysql", "MySQl query time", "operation"),
+		QueryTimings:      exporter.NewTimings("Queries", "MySQL query timings", "plan_type"),
+		WaitTimings:       exporter.NewTimings("Waits", "Wait operations", "type"),
+		ComponentTimings:  exporter.NewTimings("ComponentTimings", "Subcomponent open and close durations", "operation"),
+		TransitionTimings: exporter.NewTimings("StateTransitionTimings", "State transition durations", "transition"),
+		TransitionErrors:  exporter.NewCountersWithSingleLabel("StateTransitionErrors", "Failed state transitions", "transition"),
+		KillCounters:      exporter.NewCountersWithSingleLabel("Kills", "Number of connections being killed", "query_type", "Transactions", "Queries", "ReservedConnection"),
 		ErrorCounters: exporter.NewCountersWithSingleLabel(
 			"Errors",
 			"Critical errors",
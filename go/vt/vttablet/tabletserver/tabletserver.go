@@ -45,6 +45,7 @@ import (
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/servenv"
 	"vitess.io/vitess/go/vt/sqlparser"
@@ -116,6 +117,9 @@ type TabletServer struct {
 
 	// alias is used for identifying this tabletserver in healthcheck responses.
 	alias topodatapb.TabletAlias
+
+	// requestSeq is the last sequence number assigned to a request.
+	requestSeq sync2.AtomicInt64
 }
 
 var _ queryservice.QueryService = (*TabletServer)(nil)
@@ -179,7 +183,36 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 		transitioning:       sync2.NewSemaphore(1, 0),
 		checkMySQLThrottler: sync2.NewSemaphore(1, 0),
 		history:             history.New(10),
+		transitions:         history.New(transitionHistorySize),
 		timebombDuration:    time.Duration(config.OltpReadPool.TimeoutSeconds * 10),
+		crash:               log.Fatal,
+
+		lameduckPeriod: time.Duration(config.LameduckPeriodSeconds * 1e9),
+		lameduckByType: config.LameduckPeriods(),
+		retryBackoff:   newBackoffPolicy(config.TransitionRetry),
+		transitionSLO:  time.Duration(config.TransitionSLOSeconds * 1e9),
+
+		maxTransitionWait:       time.Duration(config.MaxTransitionWaitSeconds * 1e9),
+		logTransitionsJSON:      config.LogTransitionsJSON,
+		transitionLogInterval:   time.Duration(config.TransitionLogIntervalSeconds * 1e9),
+		trackRequests:           config.TrackInFlightRequests,
+		transitionRetryInterval: defaultTransitionRetryInterval,
+		restorePollInterval:     defaultRestorePollInterval,
+		checkMySQLBackoff: backoffPolicy{
+			initial: time.Duration(config.CheckMySQLIntervalSeconds * 1e9),
+			jitter:  config.CheckMySQLJitter,
+		},
+		verifyReadOnly:     config.VerifyReadOnly,
+		readOnlyProbe:      tsv.qe.IsSuperReadOnly,
+		positionProbe:      tsv.qe.MasterPosition,
+		maxReplicaLag:      time.Duration(config.MaxReplicaLagSeconds * 1e9),
+		replicaLagGate:     tsv.replicaLag,
+		degradedReplicaLag: time.Duration(config.DegradedReplicaLagSeconds * 1e9),
+		now:                time.Now,
+
+		componentTimings: tsv.stats.ComponentTimings,
+		waitTimings:      tsv.stats.WaitTimings,
+		metricsSink:      &statsTransitionSink{stats: tsv.stats},
 	}
 
 	tsv.exporter.NewGaugeFunc("TabletState", "Tablet server state", func() int64 { return int64(tsv.sm.State()) })
@@ -190,9 +223,36 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 	tsv.exporter.NewGaugesFuncWithMultiLabels("TabletServerState", "Tablet server state labeled by state name", []string{"name"}, func() map[string]int64 {
 		return map[string]int64{tsv.sm.StateByName(): 1}
 	})
+	tsv.exporter.NewGaugeFunc("TabletServerDegraded", "Whether a serving non-master reports degraded health because of replication lag", func() int64 {
+		if tsv.sm.IsDegraded() {
+			return 1
+		}
+		return 0
+	})
+	tsv.exporter.NewCountersFuncWithMultiLabels("TabletStateDurationNs", "Cumulative time spent in each serving state", []string{"state", "tablet_type"}, tsv.sm.StateDurations)
+	tsv.exporter.NewGaugesFuncWithMultiLabels("TabletStateByType", "Current serving state labeled by state and tablet type", []string{"state", "tablet_type"}, func() map[string]int64 {
+		return map[string]int64{tsv.sm.CurrentStateLabel(): 1}
+	})
+	tsv.exporter.NewCounterFunc("MySQLProbeFailures", "Number of failures of the custom mysql probe", tsv.sm.MySQLProbeFailures)
+	tsv.exporter.NewCountersFuncWithMultiLabels("CheckMySQL", "CheckMySQL calls by outcome", []string{"outcome"}, tsv.sm.CheckMySQLCounts)
+	tsv.exporter.NewGaugeFunc("CheckMySQLLastFailure", "Unix time in seconds at which CheckMySQL last found mysql unhealthy, 0 if never", func() int64 {
+		if last := tsv.sm.LastCheckMySQLFailure(); !last.IsZero() {
+			return last.Unix()
+		}
+		return 0
+	})
+	tsv.exporter.NewGaugeFunc("InFlightRequests", "Number of requests currently being served", tsv.sm.InFlightRequests)
+	tsv.exporter.NewCounterFunc("SlowTransitions", "Number of state transitions that exceeded the transition SLO", tsv.sm.SlowTransitions)
+	tsv.exporter.NewCounterFunc("StateTransitions", "Number of successful state transitions", tsv.sm.TransitionCount)
+	tsv.exporter.NewGaugeFunc("TimeInStateNs", "Time since the last successful transition into the current state", func() int64 { return tsv.sm.TimeInState().Nanoseconds() })
+	tsv.exporter.NewCounterFunc("FailedStateTransitions", "Number of failed state transitions", tsv.sm.FailedTransitionCount)
+	tsv.exporter.NewCounterFunc("RedundantStopServiceRequests", "Number of StopService calls ignored because the service was already stopped", tsv.sm.RedundantStops)
+	tsv.exporter.NewCountersFuncWithMultiLabels("StopServiceRequests", "Requests that drained or were still in flight when the soft drain timed out during shutdown", []string{"outcome"}, tsv.sm.DrainCounts)
+	tsv.exporter.NewCountersFuncWithMultiLabels("StartRequests", "Requests accepted or rejected by the state manager", []string{"tablet_type", "outcome"}, tsv.sm.RequestCounts)
 	tsv.exporter.NewGaugeDurationFunc("QueryTimeout", "Tablet server query timeout", tsv.QueryTimeout.Get)
 
 	tsv.registerDebugHealthHandler()
+	tsv.registerDebugReadyHandler()
 	tsv.registerQueryzHandler()
 	tsv.registerStreamQueryzHandlers()
 	tsv.registerTwopczHandler()
@@ -247,6 +307,11 @@ func (tsv *TabletServer) LogError() {
 	}
 }
 
+// NextRequestSeq satisfies tabletenv.Env.
+func (tsv *TabletServer) NextRequestSeq() int64 {
+	return tsv.requestSeq.Add(1)
+}
+
 // RegisterQueryRuleSource registers ruleSource for setting query rules.
 func (tsv *TabletServer) RegisterQueryRuleSource(ruleSource string) {
 	tsv.qe.queryRuleSources.RegisterSource(ruleSource)
@@ -318,6 +383,22 @@ func (tsv *TabletServer) SetServingType(tabletType topodatapb.TabletType, servin
 	return tsv.sm.SetServingType(tabletType, state, alsoAllow)
 }
 
+// SetServingTypeWithReason is like SetServingType, but it also records
+// why the change was requested, e.g. for the status page.
+func (tsv *TabletServer) SetServingTypeWithReason(tabletType topodatapb.TabletType, serving bool, alsoAllow []topodatapb.TabletType, reason string) (stateChanged bool, err error) {
+	state := StateNotServing
+	if serving {
+		state = StateServing
+	}
+	return tsv.sm.SetServingTypeWithReason(context.Background(), tabletType, state, alsoAllow, reason)
+}
+
+// UpdateAllowedTypes changes the tablet types that are allowed
+// in addition to the serving type, without a state transition.
+func (tsv *TabletServer) UpdateAllowedTypes(alsoAllow []topodatapb.TabletType) {
+	tsv.sm.UpdateAllowedTypes(alsoAllow)
+}
+
 // StartService is a convenience function for InitDBConfig->SetServingType
 // with serving=true.
 func (tsv *TabletServer) StartService(target querypb.Target, dbcfgs *dbconfigs.DBConfigs) error {
@@ -337,6 +418,13 @@ func (tsv *TabletServer) StopService() {
 	tsv.sm.StopService()
 }
 
+// ResetState closes the query service, and clears its serving state
+// and counters so that StartService can be called again in-process.
+// It fails if a state transition is in progress.
+func (tsv *TabletServer) ResetState() error {
+	return tsv.sm.Reset()
+}
+
 // IsHealthy returns nil for non-serving types or if the query service is healthy (able to
 // connect to the database and serving traffic), or an error explaining
 // the unhealthiness otherwise.
@@ -399,6 +487,7 @@ func (tsv *TabletServer) begin(ctx context.Context, target *querypb.Target, preQ
 			var beginSQL string
 			transactionID, beginSQL, err = tsv.te.Begin(ctx, preQueries, reservedID, options)
 			logStats.TransactionID = transactionID
+			logStats.TransactionMode = vtgatepb.TransactionMode_UNSPECIFIED
 			logStats.ReservedID = reservedID
 
 			// Record the actual statements that were executed in the logStats.
@@ -426,9 +515,11 @@ func (tsv *TabletServer) Commit(ctx context.Context, target *querypb.Target, tra
 		func(ctx context.Context, logStats *tabletenv.LogStats) error {
 			startTime := time.Now()
 			logStats.TransactionID = transactionID
+			logStats.TransactionMode = vtgatepb.TransactionMode_UNSPECIFIED
 
 			var commitSQL string
 			newReservedID, commitSQL, err = tsv.te.Commit(ctx, transactionID)
+			logStats.AddCommitTime(startTime)
 			if newReservedID > 0 {
 				// commit executed on old reserved id.
 				logStats.ReservedID = transactionID
@@ -457,6 +548,7 @@ func (tsv *TabletServer) Rollback(ctx context.Context, target *querypb.Target, t
 		func(ctx context.Context, logStats *tabletenv.LogStats) error {
 			defer tsv.stats.QueryTimings.Record("ROLLBACK", time.Now())
 			logStats.TransactionID = transactionID
+			logStats.TransactionMode = vtgatepb.TransactionMode_UNSPECIFIED
 			newReservedID, err = tsv.te.Rollback(ctx, transactionID)
 			if newReservedID > 0 {
 				// rollback executed on old reserved id.
@@ -629,7 +721,9 @@ func (tsv *TabletServer) Execute(ctx context.Context, target *querypb.Target, sq
 				bindVariables = make(map[string]*querypb.BindVariable)
 			}
 			query, comments := sqlparser.SplitMarginComments(sql)
+			planStart := time.Now()
 			plan, err := tsv.qe.GetPlan(ctx, logStats, query, skipQueryPlanCache(options))
+			logStats.AddPlanTime(planStart)
 			if err != nil {
 				return err
 			}
@@ -657,6 +751,7 @@ func (tsv *TabletServer) Execute(ctx context.Context, target *querypb.Target, sq
 				return err
 			}
 			result = result.StripMetadata(sqltypes.IncludeFieldsOrDefault(options))
+			logStats.AddWireResult(result)
 			return nil
 		},
 	)
@@ -676,7 +771,9 @@ func (tsv *TabletServer) StreamExecute(ctx context.Context, target *querypb.Targ
 				bindVariables = make(map[string]*querypb.BindVariable)
 			}
 			query, comments := sqlparser.SplitMarginComments(sql)
+			planStart := time.Now()
 			plan, err := tsv.qe.GetStreamPlan(query)
+			logStats.AddPlanTime(planStart)
 			if err != nil {
 				return err
 			}
@@ -691,7 +788,10 @@ func (tsv *TabletServer) StreamExecute(ctx context.Context, target *querypb.Targ
 				logStats:       logStats,
 				tsv:            tsv,
 			}
-			return qre.Stream(callback)
+			return qre.Stream(func(result *sqltypes.Result) error {
+				logStats.AddWireResult(result)
+				return callback(result)
+			})
 		},
 	)
 }
@@ -1042,6 +1142,7 @@ func (tsv *TabletServer) ReserveBeginExecute(ctx context.Context, target *queryp
 			}
 			logStats.TransactionID = connID
 			logStats.ReservedID = connID
+			logStats.ReservedConn = true
 			return nil
 		},
 	)
@@ -1071,6 +1172,7 @@ func (tsv *TabletServer) ReserveExecute(ctx context.Context, target *querypb.Tar
 			}
 			logStats.TransactionID = transactionID
 			logStats.ReservedID = connID
+			logStats.ReservedConn = true
 			return nil
 		},
 	)
@@ -1127,8 +1229,19 @@ func (tsv *TabletServer) execRequest(
 	}
 	defer span.Finish()
 
-	logStats := tabletenv.NewLogStats(ctx, requestName)
+	// Internal queries are not logged, and don't need to pay for it.
+	var logStats *tabletenv.LogStats
+	if tabletenv.IsLocalContext(ctx) {
+		logStats = tabletenv.NewLogStatsNoop(ctx, requestName)
+	} else {
+		logStats = tabletenv.NewLogStats(ctx, requestName)
+		logStats.RequestSeq = tsv.NextRequestSeq()
+	}
 	logStats.Target = target
+	if target != nil {
+		// StartRequest verifies that they match those of the tablet.
+		logStats.Keyspace, logStats.Shard = target.Keyspace, target.Shard
+	}
 	logStats.OriginalSQL = sql
 	logStats.BindVariables = bindVariables
 	defer tsv.handlePanicAndSendLogStats(sql, bindVariables, logStats)
@@ -1136,9 +1249,11 @@ func (tsv *TabletServer) execRequest(
 		return err
 	}
 
+	untrack := tsv.sm.TrackRequest(requestName, target)
 	ctx, cancel := withTimeout(ctx, timeout, options)
 	defer func() {
 		cancel()
+		untrack()
 		tsv.sm.EndRequest()
 	}()
 
@@ -1434,6 +1549,13 @@ func (tsv *TabletServer) BroadcastHealth(terTimestamp int64, stats *querypb.Real
 	tsv.lastStreamHealthExpiration = time.Now().Add(maxCache)
 }
 
+// replicaLag returns the lag measured by the heartbeat reader for the
+// replica lag gate. The lag is unknown if the last heartbeat read failed.
+func (tsv *TabletServer) replicaLag() (time.Duration, bool) {
+	lag, err := tsv.hr.GetLatest()
+	return lag, err == nil
+}
+
 // HeartbeatLag returns the current lag as calculated by the heartbeat
 // package, if heartbeat is enabled. Otherwise returns 0.
 func (tsv *TabletServer) HeartbeatLag() (time.Duration, error) {
@@ -1456,6 +1578,65 @@ func (tsv *TabletServer) EnterLameduck() {
 	tsv.sm.EnterLameduck()
 }
 
+// ForceNotServing causes the tabletserver to stop serving without
+// retries, until ClearForcedNotServing is called.
+func (tsv *TabletServer) ForceNotServing(reason string) {
+	tsv.sm.ForceNotServing(reason)
+}
+
+// ClearForcedNotServing undoes ForceNotServing, and transitions to the
+// most recently requested serving type.
+func (tsv *TabletServer) ClearForcedNotServing() (stateChanged bool, err error) {
+	return tsv.sm.ClearForcedNotServing()
+}
+
+// FreezeTransitions makes subsequent SetServingType calls block until
+// UnfreezeTransitions is called, e.g. during a coordinated maintenance.
+func (tsv *TabletServer) FreezeTransitions() {
+	tsv.sm.Freeze()
+}
+
+// UnfreezeTransitions undoes FreezeTransitions. The blocked calls are
+// executed in order before it returns.
+func (tsv *TabletServer) UnfreezeTransitions() {
+	tsv.sm.Unfreeze()
+}
+
+// DemoteToReadOnly causes a serving master to stop accepting writes
+// while it continues to serve reads.
+func (tsv *TabletServer) DemoteToReadOnly() error {
+	return tsv.sm.DemoteToReadOnly()
+}
+
+// PromoteToReadWrite undoes DemoteToReadOnly.
+func (tsv *TabletServer) PromoteToReadWrite() error {
+	return tsv.sm.PromoteToReadWrite()
+}
+
+// SetTimebombHandler installs a function that's called with the number
+// of in-flight requests when a shutdown takes too long, before the
+// process is terminated.
+func (tsv *TabletServer) SetTimebombHandler(handler func(inflight int64)) {
+	tsv.sm.SetTimebombHandler(handler)
+}
+
+// PromoteToMaster makes a serving replica a serving master, and returns
+// the replication position at which it started accepting writes.
+func (tsv *TabletServer) PromoteToMaster(ctx context.Context) (mysql.Position, error) {
+	return tsv.sm.PromoteToMaster(ctx)
+}
+
+// EnterMaintenance causes the tabletserver to reject requests with
+// msg, without changing its serving state.
+func (tsv *TabletServer) EnterMaintenance(msg string) {
+	tsv.sm.EnterMaintenance(msg)
+}
+
+// ExitMaintenance undoes EnterMaintenance.
+func (tsv *TabletServer) ExitMaintenance() {
+	tsv.sm.ExitMaintenance()
+}
+
 // ExitLameduck causes the tabletserver to exit the lameduck mode.
 func (tsv *TabletServer) ExitLameduck() {
 	tsv.sm.ExitLameduck()
@@ -1466,6 +1647,12 @@ func (tsv *TabletServer) IsServing() bool {
 	return tsv.sm.IsServing()
 }
 
+// IsReady returns true if TabletServer is serving and not about to
+// stop serving. If not, it also returns the reason.
+func (tsv *TabletServer) IsReady() (bool, string) {
+	return tsv.sm.IsReady()
+}
+
 // CheckMySQL initiates a check to see if MySQL is reachable.
 // If not, it shuts down the query service. The check is rate-limited
 // to no more than once per second.
@@ -1506,6 +1693,21 @@ func (tsv *TabletServer) registerDebugHealthHandler() {
 	})
 }
 
+func (tsv *TabletServer) registerDebugReadyHandler() {
+	tsv.exporter.HandleFunc("/debug/ready", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.MONITORING); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		if ready, reason := tsv.IsReady(); !ready {
+			http.Error(w, fmt.Sprintf("not ready: %s", reason), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+}
+
 func (tsv *TabletServer) registerQueryzHandler() {
 	tsv.exporter.HandleFunc("/queryz", func(w http.ResponseWriter, r *http.Request) {
 		queryzHandler(tsv.qe, w, r)
@@ -1526,7 +1728,7 @@ func (tsv *TabletServer) registerTwopczHandler() {
 		ctx := tabletenv.LocalContext()
 		txe := &TxExecutor{
 			ctx:      ctx,
-			logStats: tabletenv.NewLogStats(ctx, "twopcz"),
+			logStats: tabletenv.NewLogStatsNoop(ctx, "twopcz"),
 			te:       tsv.te,
 		}
 		twopczHandler(txe, w, r)
@@ -35,6 +35,7 @@ import (
 	"vitess.io/vitess/go/mysql/fakesqldb"
 	"vitess.io/vitess/go/test/utils"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/stretchr/testify/require"
@@ -52,6 +53,7 @@ import (
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
@@ -121,6 +123,19 @@ func TestTabletServerMasterToReplica(t *testing.T) {
 	<-ch
 }
 
+func TestTabletServerTransitionStats(t *testing.T) {
+	db, tsv := setupTabletServerTest(t)
+	defer tsv.StopService()
+	defer db.Close()
+
+	// The transitions are observed by the default sink.
+	key := "TabletServerTest.ServingToNotServing"
+	countStart := tsv.stats.TransitionTimings.Counts()[key]
+	_, err := tsv.SetServingType(topodatapb.TabletType_REPLICA, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, countStart+1, tsv.stats.TransitionTimings.Counts()[key])
+}
+
 func TestTabletServerRedoLogIsKeptBetweenRestarts(t *testing.T) {
 	// Reuse code from tx_executor_test.
 	_, tsv, db := newTestTxExecutor(t)
@@ -672,6 +687,152 @@ func TestTabletServerStreamExecuteComments(t *testing.T) {
 		t.Fatal("stats are empty")
 	}
 }
+func TestTabletServerRequestSeq(t *testing.T) {
+	db, tsv := setupTabletServerTest(t)
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{})
+	target := querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+
+	ch := tabletenv.StatsLogger.Subscribe("test request seq")
+	defer tabletenv.StatsLogger.Unsubscribe(ch)
+
+	var last int64
+	for i := 0; i < 3; i++ {
+		_, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, nil)
+		require.NoError(t, err)
+		stats := (<-ch).(*tabletenv.LogStats)
+		assert.Greater(t, stats.RequestSeq, last)
+		last = stats.RequestSeq
+	}
+}
+
+func TestTabletServerLocalContextStats(t *testing.T) {
+	db, tsv := setupTabletServerTest(t)
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{})
+	target := querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+
+	ch := tabletenv.StatsLogger.Subscribe("test local context")
+	defer tabletenv.StatsLogger.Unsubscribe(ch)
+
+	// Internal queries are not logged, but their mysql time is
+	// still accounted for in the plan stats.
+	localCtx := tabletenv.LocalContext()
+	_, err := tsv.Execute(localCtx, &target, executeSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	select {
+	case stats := <-ch:
+		t.Errorf("internal query was logged: %v", stats)
+	default:
+	}
+	plan, err := tsv.qe.GetPlan(localCtx, tabletenv.NewLogStats(localCtx, "test"), executeSQL, false)
+	require.NoError(t, err)
+	queryCount, _, mysqlTime, _, _ := plan.Stats()
+	assert.Equal(t, int64(1), queryCount)
+	assert.Greater(t, int64(mysqlTime), int64(0))
+}
+
+func TestTabletServerLogKeyspaceShard(t *testing.T) {
+	db := setupFakeDB(t)
+	defer db.Close()
+	tsv := NewTabletServer("TabletServerTest", tabletenv.NewDefaultConfig(), memorytopo.NewServer(""), topodatapb.TabletAlias{})
+	target := querypb.Target{Keyspace: "test_keyspace", Shard: "-80", TabletType: topodatapb.TabletType_MASTER}
+	require.NoError(t, tsv.StartService(target, newDBConfigs(db)))
+	defer tsv.StopService()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{})
+
+	ch := tabletenv.StatsLogger.Subscribe("test keyspace shard")
+	defer tabletenv.StatsLogger.Unsubscribe(ch)
+
+	_, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	stats := (<-ch).(*tabletenv.LogStats)
+	assert.Equal(t, "test_keyspace", stats.Keyspace)
+	assert.Equal(t, "-80", stats.Shard)
+}
+
+func TestTabletServerWireBytesSent(t *testing.T) {
+	db, tsv := setupTabletServerTest(t)
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "pk", Type: sqltypes.Int32}},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewInt32(1)},
+			{sqltypes.NewInt32(2)},
+		},
+		RowsAffected: 2,
+	})
+	target := querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+
+	ch := tabletenv.StatsLogger.Subscribe("test wire bytes")
+	defer tabletenv.StatsLogger.Unsubscribe(ch)
+
+	result, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	stats := (<-ch).(*tabletenv.LogStats)
+	assert.Equal(t, int64(proto.Size(sqltypes.ResultToProto3(result))), stats.WireBytesSent)
+
+	// Streamed results are accumulated.
+	var want int64
+	err = tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, nil, func(result *sqltypes.Result) error {
+		want += int64(proto.Size(sqltypes.ResultToProto3(result)))
+		return nil
+	})
+	require.NoError(t, err)
+	stats = (<-ch).(*tabletenv.LogStats)
+	assert.NotZero(t, want)
+	assert.Equal(t, want, stats.WireBytesSent)
+}
+
+func TestTabletServerLogTransactionMode(t *testing.T) {
+	db, tsv := setupTabletServerTest(t)
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{})
+	target := querypb.Target{TabletType: topodatapb.TabletType_MASTER}
+
+	ch := tabletenv.StatsLogger.Subscribe("test transaction mode")
+	defer tabletenv.StatsLogger.Unsubscribe(ch)
+
+	_, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	stats := (<-ch).(*tabletenv.LogStats)
+	assert.Equal(t, vtgatepb.TransactionMode_SINGLE, stats.TransactionMode)
+
+	// Begin, the statements of the transaction, and Commit or
+	// Rollback all log the same mode.
+	for _, end := range []string{"Commit", "Rollback"} {
+		txid, _, err := tsv.Begin(ctx, &target, nil)
+		require.NoError(t, err)
+		_, err = tsv.Execute(ctx, &target, executeSQL, nil, txid, 0, nil)
+		require.NoError(t, err)
+		if end == "Commit" {
+			_, err = tsv.Commit(ctx, &target, txid)
+		} else {
+			_, err = tsv.Rollback(ctx, &target, txid)
+		}
+		require.NoError(t, err)
+		for _, method := range []string{"Begin", "Execute", end} {
+			stats := (<-ch).(*tabletenv.LogStats)
+			assert.Equal(t, method, stats.Method)
+			assert.Equal(t, vtgatepb.TransactionMode_UNSPECIFIED, stats.TransactionMode, method)
+		}
+	}
+}
+
 func TestTabletServerExecuteBatch(t *testing.T) {
 	db, tsv := setupTabletServerTest(t)
 	defer tsv.StopService()
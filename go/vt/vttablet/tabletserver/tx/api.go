@@ -56,6 +56,12 @@ type (
 		Autocommit      bool
 		Conclusion      string
 		LogToFile       bool
+		// IsolationLevel is the isolation level that was set when the
+		// transaction was opened, e.g. READ COMMITTED. It's empty if
+		// the session default was used, and for autocommit.
+		IsolationLevel string
+		// Savepoints are the names of the active savepoints, oldest first.
+		Savepoints []string
 
 		Stats *servenv.TimingsWrapper
 	}
@@ -121,6 +127,59 @@ func (p *Properties) RecordQuery(query string) {
 	p.Queries = append(p.Queries, query)
 }
 
+// RecordSavepoint records a savepoint set in this transaction. As in
+// MySQL, an existing savepoint with the same name is replaced.
+func (p *Properties) RecordSavepoint(name string) {
+	if p == nil {
+		return
+	}
+	if i := p.findSavepoint(name); i >= 0 {
+		p.Savepoints = append(p.Savepoints[:i], p.Savepoints[i+1:]...)
+	}
+	p.Savepoints = append(p.Savepoints, name)
+}
+
+// ReleaseSavepoint records the release of the named savepoint, which
+// also removes the savepoints set after it.
+func (p *Properties) ReleaseSavepoint(name string) {
+	if p == nil {
+		return
+	}
+	if i := p.findSavepoint(name); i >= 0 {
+		p.Savepoints = p.Savepoints[:i]
+	}
+}
+
+// RollbackToSavepoint records a rollback to the named savepoint, which
+// removes the savepoints set after it, but keeps the savepoint itself.
+func (p *Properties) RollbackToSavepoint(name string) {
+	if p == nil {
+		return
+	}
+	if i := p.findSavepoint(name); i >= 0 {
+		p.Savepoints = p.Savepoints[:i+1]
+	}
+}
+
+// SavepointDepth returns the number of active savepoints.
+func (p *Properties) SavepointDepth() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.Savepoints)
+}
+
+// findSavepoint returns the index of the named savepoint, or -1.
+// Savepoint names are case insensitive.
+func (p *Properties) findSavepoint(name string) int {
+	for i, savepoint := range p.Savepoints {
+		if strings.EqualFold(savepoint, name) {
+			return i
+		}
+	}
+	return -1
+}
+
 // InTransaction returns true as soon as this struct is not nil
 func (p *Properties) InTransaction() bool { return p != nil }
 
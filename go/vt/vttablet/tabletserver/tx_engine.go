@@ -40,6 +40,7 @@ import (
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/txlimiter"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 )
 
 type txEngineState int
@@ -654,3 +655,24 @@ func (te *TxEngine) Release(connID int64) error {
 
 	return nil
 }
+
+// transactionMode returns the vtgate transaction mode under which a
+// statement executing on conn runs. vtgate doesn't send its mode to
+// the tablet, so a transaction is reported as UNSPECIFIED. The
+// two-phase commit operations are reported as TWOPC by the TxExecutor.
+func (te *TxEngine) transactionMode(conn *StatefulConnection) vtgatepb.TransactionMode {
+	if !conn.IsInTransaction() {
+		return vtgatepb.TransactionMode_SINGLE
+	}
+	return vtgatepb.TransactionMode_UNSPECIFIED
+}
+
+// isolationLevel returns the isolation level of the transaction on
+// conn, as set when it was opened. It's empty if conn isn't in a
+// transaction, or if the transaction uses the session default.
+func (te *TxEngine) isolationLevel(conn *StatefulConnection) string {
+	if !conn.IsInTransaction() {
+		return ""
+	}
+	return conn.TxProperties().IsolationLevel
+}
@@ -27,6 +27,7 @@ import (
 	"vitess.io/vitess/go/vt/log"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
@@ -46,6 +47,7 @@ type TxExecutor struct {
 // A subsequent call to RollbackPrepared, which is required by the 2PC
 // protocol, will perform all the cleanup.
 func (txe *TxExecutor) Prepare(transactionID int64, dtid string) error {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
@@ -79,6 +81,7 @@ func (txe *TxExecutor) Prepare(transactionID int64, dtid string) error {
 // fails, an error counter is incremented and the transaction is
 // marked as failed in the redo log.
 func (txe *TxExecutor) CommitPrepared(dtid string) error {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
@@ -154,6 +157,7 @@ func (txe *TxExecutor) markFailed(ctx context.Context, dtid string) {
 // step. If the original transaction is still alive, the transaction
 // killer will be the one to eventually roll it back.
 func (txe *TxExecutor) RollbackPrepared(dtid string, originalID int64) error {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
@@ -173,6 +177,7 @@ func (txe *TxExecutor) RollbackPrepared(dtid string, originalID int64) error {
 
 // CreateTransaction creates the metadata for a 2PC transaction.
 func (txe *TxExecutor) CreateTransaction(dtid string, participants []*querypb.Target) error {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
@@ -185,6 +190,7 @@ func (txe *TxExecutor) CreateTransaction(dtid string, participants []*querypb.Ta
 // StartCommit atomically commits the transaction along with the
 // decision to commit the associated 2pc transaction.
 func (txe *TxExecutor) StartCommit(transactionID int64, dtid string) error {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
@@ -208,6 +214,7 @@ func (txe *TxExecutor) StartCommit(transactionID int64, dtid string) error {
 // SetRollback transitions the 2pc transaction to the Rollback state.
 // If a transaction id is provided, that transaction is also rolled back.
 func (txe *TxExecutor) SetRollback(dtid string, transactionID int64) error {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
@@ -226,6 +233,7 @@ func (txe *TxExecutor) SetRollback(dtid string, transactionID int64) error {
 // ConcludeTransaction deletes the 2pc transaction metadata
 // essentially resolving it.
 func (txe *TxExecutor) ConcludeTransaction(dtid string) error {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
@@ -238,6 +246,7 @@ func (txe *TxExecutor) ConcludeTransaction(dtid string) error {
 
 // ReadTransaction returns the metadata for the sepcified dtid.
 func (txe *TxExecutor) ReadTransaction(dtid string) (*querypb.TransactionMetadata, error) {
+	txe.logStats.TransactionMode = vtgatepb.TransactionMode_TWOPC
 	if !txe.te.twopcEnabled {
 		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
 	}
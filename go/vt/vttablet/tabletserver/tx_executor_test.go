@@ -37,6 +37,7 @@ import (
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
 )
 
 func TestTxExecutorEmptyPrepare(t *testing.T) {
@@ -57,6 +58,7 @@ func TestTxExecutorPrepare(t *testing.T) {
 	txid := newTxForPrep(tsv)
 	err := txe.Prepare(txid, "aa")
 	require.NoError(t, err)
+	require.Equal(t, vtgatepb.TransactionMode_TWOPC, txe.logStats.TransactionMode)
 	err = txe.RollbackPrepared("aa", 1)
 	require.NoError(t, err)
 	// A retry should still succeed.
@@ -250,6 +250,7 @@ func (tp *TxPool) begin(ctx context.Context, options *querypb.ExecuteOptions, re
 	}
 
 	conn.txProps = tp.NewTxProps(immediateCaller, effectiveCaller, autocommit)
+	conn.txProps.IsolationLevel = txIsolations[options.GetTransactionIsolation()].setIsolationLevel
 
 	return beginQueries, nil
 }